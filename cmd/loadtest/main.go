@@ -0,0 +1,135 @@
+// Command loadtest measures a running planning poker server under many
+// concurrent sessions. It connects N sessions of M simulated participants
+// over WebSockets and plays rounds in each: the moderator sets a story,
+// everyone votes at a random moment within the vote interval and the
+// moderator reveals. It then reports latency percentiles and error counts.
+//
+//	go run ./cmd/loadtest -server http://localhost:8080 -sessions 50 -participants 20
+//
+// It exits with status 1 if any errors occurred.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"planning-poker/pkg/client"
+)
+
+// options holds the flags
+type options struct {
+	server       string
+	sessions     int
+	participants int
+	rounds       int
+	interval     time.Duration // Votes are spread over this after each story
+	ramp         time.Duration // Connections are spread over this
+	timeout      time.Duration // Longest wait for any one step
+	prefix       string        // Of session IDs
+	jsonOutput   bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts, err := parseFlags(os.Args[1:], os.Stderr)
+	if err != nil {
+		os.Exit(2)
+	}
+
+	report := run(ctx, opts)
+	if opts.jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		report.print(os.Stdout)
+	}
+	if len(report.Errors) > 0 {
+		os.Exit(1)
+	}
+}
+
+func parseFlags(args []string, output io.Writer) (options, error) {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	var opts options
+	fs.StringVar(&opts.server, "server", "http://localhost:8080", "Server URL")
+	fs.IntVar(&opts.sessions, "sessions", 10, "Number of sessions")
+	fs.IntVar(&opts.participants, "participants", 10, "Participants per session, including the moderator")
+	fs.IntVar(&opts.rounds, "rounds", 5, "Rounds per session")
+	fs.DurationVar(&opts.interval, "vote-interval", 2*time.Second, "Participants vote at a random moment within this after each story")
+	fs.DurationVar(&opts.ramp, "ramp", 5*time.Second, "Spread connecting every participant over this")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Second, "Longest wait for connections, votes or broadcasts")
+	fs.StringVar(&opts.prefix, "prefix", "", "Prefix of session IDs (default: load- and a random suffix)")
+	fs.BoolVar(&opts.jsonOutput, "json", false, "Print the report as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	invalid := opts.sessions < 1 || opts.participants < 1 || opts.rounds < 0 ||
+		opts.interval < 0 || opts.ramp < 0 || opts.timeout <= 0
+	if fs.NArg() > 0 || invalid {
+		fmt.Fprintln(output, "-sessions, -participants and -timeout must be positive and the other counts and durations not negative")
+		fs.Usage()
+		return opts, errors.New("invalid flags")
+	}
+	if opts.prefix == "" {
+		suffix := make([]byte, 3)
+		rand.Read(suffix)
+		opts.prefix = "load-" + hex.EncodeToString(suffix)
+	}
+	return opts, nil
+}
+
+// run plays every session concurrently and reports what it measured
+func run(ctx context.Context, opts options) Report {
+	c := client.New(opts.server)
+	r := &results{}
+	start := time.Now()
+
+	// Participants connect at even intervals over the ramp, session by
+	// session in turn, so that every session fills up at the same pace
+	total := opts.sessions * opts.participants
+	step := opts.ramp / time.Duration(max(total-1, 1))
+
+	var wg sync.WaitGroup
+	for i := range opts.sessions {
+		s := newSimSession(fmt.Sprintf("%s-%d", opts.prefix, i+1), opts, r)
+		offsets := make([]time.Duration, opts.participants)
+		for j := range offsets {
+			offsets[j] = step * time.Duration(j*opts.sessions+i)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.run(ctx, c, start, offsets)
+		}()
+	}
+	wg.Wait()
+
+	return Report{
+		Sessions:     opts.sessions,
+		Participants: opts.participants,
+		Rounds:       int(r.rounds.Load()),
+		Duration:     time.Since(start),
+		Connect:      r.connect.summary(),
+		Messages:     r.messages.summary(),
+		Delivery:     r.delivery.summary(),
+		Fanout:       r.fanout.summary(),
+		Errors:       r.errors.snapshot(),
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"planning-poker/internal/server"
+)
+
+func TestRun(t *testing.T) {
+	srv := server.New()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", srv.HandleWebSocket)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	opts, err := parseFlags([]string{
+		"-server", ts.URL,
+		"-sessions", "3",
+		"-participants", "4",
+		"-rounds", "2",
+		"-vote-interval", "20ms",
+		"-ramp", "50ms",
+		"-timeout", "5s",
+	}, io.Discard)
+	if err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+
+	report := run(context.Background(), opts)
+	if len(report.Errors) != 0 {
+		t.Fatalf("Unexpected errors %v", report.Errors)
+	}
+
+	// Each session broadcasts start_session and, every round, set_story
+	// and reveal; every participant votes once a round
+	broadcasts := 3 * (1 + 2*2)
+	votes := 3 * 4 * 2
+	tests := map[string]struct{ got, want int }{
+		"rounds":   {report.Rounds, 3 * 2},
+		"connect":  {report.Connect.Count, 3 * 4},
+		"fan-out":  {report.Fanout.Count, broadcasts},
+		"delivery": {report.Delivery.Count, broadcasts * 4},
+		"messages": {report.Messages.Count, votes + broadcasts},
+	}
+	for name, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("Expected %d %s, got %d", tt.want, name, tt.got)
+		}
+	}
+	if report.Fanout.Max <= 0 || report.Fanout.P50 > report.Fanout.Max {
+		t.Errorf("Unexpected fan-out summary %+v", report.Fanout)
+	}
+}
+
+func TestRunCountsConnectErrors(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	opts, _ := parseFlags([]string{"-server", ts.URL, "-sessions", "2", "-participants", "2", "-ramp", "0", "-timeout", "1s"}, io.Discard)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	report := run(ctx, opts)
+	if report.Errors["connect"] != 2 || report.Rounds != 0 {
+		t.Errorf("Expected each session to fail to connect, got %+v", report)
+	}
+}
+
+func TestParseFlagsRejectsInvalidCounts(t *testing.T) {
+	for _, args := range [][]string{{"-sessions", "0"}, {"-participants", "-1"}, {"-timeout", "0"}, {"extra"}} {
+		if _, err := parseFlags(args, io.Discard); err == nil {
+			t.Errorf("Expected %v to be rejected", args)
+		}
+	}
+}
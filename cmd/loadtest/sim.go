@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"planning-poker/pkg/client"
+)
+
+// votes are the cards simulated participants choose from
+var votes = []string{"1", "2", "3", "5", "8", "13"}
+
+// results collects measurements from every simulated session
+type results struct {
+	connect, messages, delivery, fanout latencies
+	errors                              counters
+	rounds                              atomic.Int64
+}
+
+// simSession is one session: participants[0] creates and moderates it and
+// everyone, the moderator included, votes in every round
+type simSession struct {
+	id           string
+	cfg          options
+	results      *results
+	participants []*participant
+
+	mu      sync.Mutex
+	expect  *expectation  // The moderator broadcast being timed
+	votes   int           // Votes the moderator has seen this round
+	votesIn chan struct{} // Signaled when every participant has voted
+}
+
+// expectation times one moderator message until every participant has
+// received the patch it causes
+type expectation struct {
+	op   client.MessageType
+	sent time.Time
+	seen map[*participant]bool
+	done chan struct{}
+}
+
+// participant is one simulated user
+type participant struct {
+	name      string
+	moderator bool
+	conn      *client.Conn
+	id        string // Learned from the first snapshot
+	ready     chan struct{}
+
+	mu       sync.Mutex
+	voteSent time.Time
+}
+
+func newSimSession(id string, cfg options, r *results) *simSession {
+	s := &simSession{id: id, cfg: cfg, results: r, votesIn: make(chan struct{}, 1)}
+	for i := range cfg.participants {
+		s.participants = append(s.participants, &participant{
+			name:      fmt.Sprintf("%s-%d", id, i+1),
+			moderator: i == 0,
+			ready:     make(chan struct{}),
+		})
+	}
+	return s
+}
+
+// run connects each participant at its offset from start and then plays
+// the rounds. It returns when the rounds are done or ctx is canceled.
+func (s *simSession) run(ctx context.Context, c *client.Client, start time.Time, offsets []time.Duration) {
+	var wg sync.WaitGroup
+	defer func() {
+		for _, p := range s.participants {
+			if p.conn != nil {
+				p.conn.Close()
+			}
+		}
+		wg.Wait()
+	}()
+
+	// The moderator connects first so that the session exists and is
+	// waiting for them to start it
+	for i, p := range s.participants {
+		if !sleep(ctx, time.Until(start.Add(offsets[i]))) {
+			return
+		}
+		if !s.connect(ctx, c, p, &wg) {
+			return
+		}
+	}
+
+	for _, p := range s.participants {
+		if !s.wait(ctx, p.ready, "connect_timeout") {
+			return
+		}
+	}
+
+	moderator := s.participants[0]
+	if !s.broadcast(ctx, client.MessageTypeStartSession, moderator.conn.StartSession) {
+		return
+	}
+	for round := 1; round <= s.cfg.rounds; round++ {
+		story := fmt.Sprintf("Story %d", round)
+		if !s.broadcast(ctx, client.MessageTypeSetStory, func() error { return moderator.conn.SetStory(story) }) {
+			return
+		}
+		if !s.wait(ctx, s.votesIn, "vote_timeout") {
+			return
+		}
+		if !s.broadcast(ctx, client.MessageTypeReveal, moderator.conn.Reveal) {
+			return
+		}
+		s.results.rounds.Add(1)
+	}
+}
+
+// connect dials for p and starts handling its events
+func (s *simSession) connect(ctx context.Context, c *client.Client, p *participant, wg *sync.WaitGroup) bool {
+	start := time.Now()
+	conn, err := c.Dial(ctx, client.DialOptions{
+		Session: s.id,
+		Name:    p.name,
+		Creator: p.moderator,
+		Logger:  slog.New(slog.DiscardHandler), // Disconnects are counted instead
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			s.results.errors.inc("connect")
+		}
+		return false
+	}
+	p.conn = conn
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.handleEvents(ctx, p, start)
+	}()
+	return true
+}
+
+// handleEvents records what p sees until its connection is closed
+func (s *simSession) handleEvents(ctx context.Context, p *participant, start time.Time) {
+	for event := range p.conn.Events() {
+		now := time.Now()
+		switch event.Kind {
+		case client.EventDisconnected:
+			s.results.errors.inc("disconnect")
+		case client.EventState:
+			if p.id == "" {
+				for _, user := range event.State.Users {
+					if user.Name == p.name {
+						p.id = user.ID
+						s.results.connect.add(now.Sub(start))
+						close(p.ready)
+						break
+					}
+				}
+			}
+			if event.Patch != nil {
+				s.observe(ctx, p, *event.Patch, now)
+			}
+		}
+	}
+}
+
+// observe records the timing of a patch p received
+func (s *simSession) observe(ctx context.Context, p *participant, patch client.StatePatch, now time.Time) {
+	if patch.Op == client.MessageTypeVote && patch.UserID == p.id {
+		p.mu.Lock()
+		s.results.messages.add(now.Sub(p.voteSent))
+		p.mu.Unlock()
+	}
+	if patch.Op == client.MessageTypeSetStory {
+		go s.vote(ctx, p)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.expect; e != nil && patch.Op == e.op && !e.seen[p] {
+		e.seen[p] = true
+		latency := now.Sub(e.sent)
+		s.results.delivery.add(latency)
+		if p.moderator {
+			s.results.messages.add(latency)
+		}
+		if len(e.seen) == len(s.participants) {
+			s.results.fanout.add(latency)
+			close(e.done)
+			s.expect = nil
+		}
+	}
+
+	// Patches arrive in order, so the moderator sees every vote of a round
+	// after its set_story patch
+	if p.moderator {
+		switch patch.Op {
+		case client.MessageTypeSetStory:
+			s.votes = 0
+		case client.MessageTypeVote:
+			s.votes++
+			if s.votes == len(s.participants) {
+				select {
+				case s.votesIn <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// vote votes after a random part of the vote interval, as people do
+func (s *simSession) vote(ctx context.Context, p *participant) {
+	if !sleep(ctx, rand.N(s.cfg.interval+1)) {
+		return
+	}
+
+	p.mu.Lock()
+	p.voteSent = time.Now()
+	p.mu.Unlock()
+	err := p.conn.Vote(votes[rand.N(len(votes))])
+	if err != nil && !errors.Is(err, client.ErrClosed) && ctx.Err() == nil {
+		s.results.errors.inc("send")
+	}
+}
+
+// broadcast sends a moderator message and waits until every participant
+// has received its patch
+func (s *simSession) broadcast(ctx context.Context, op client.MessageType, send func() error) bool {
+	e := &expectation{op: op, sent: time.Now(), seen: map[*participant]bool{}, done: make(chan struct{})}
+	s.mu.Lock()
+	s.expect = e
+	s.mu.Unlock()
+
+	if err := send(); err != nil {
+		if ctx.Err() == nil {
+			s.results.errors.inc("send")
+		}
+		return false
+	}
+	return s.wait(ctx, e.done, string(op)+"_timeout")
+}
+
+// wait waits for ch, counting a timeout error of kind if it takes longer
+// than the configured timeout
+func (s *simSession) wait(ctx context.Context, ch <-chan struct{}, kind string) bool {
+	timer := time.NewTimer(s.cfg.timeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return true
+	case <-timer.C:
+		s.results.errors.inc(kind)
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sleep waits for d, returning false if ctx is canceled first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
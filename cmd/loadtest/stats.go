@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencies collects durations and summarizes them as percentiles
+type latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+}
+
+func (l *latencies) add(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples = append(l.samples, d)
+}
+
+// Summary is the distribution of one kind of latency
+type Summary struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+func (l *latencies) summary() Summary {
+	l.mu.Lock()
+	samples := slices.Clone(l.samples)
+	l.mu.Unlock()
+
+	if len(samples) == 0 {
+		return Summary{}
+	}
+	slices.Sort(samples)
+	return Summary{
+		Count: len(samples),
+		P50:   percentile(samples, 50),
+		P90:   percentile(samples, 90),
+		P99:   percentile(samples, 99),
+		Max:   samples[len(samples)-1],
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted samples
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	return sorted[max(rank, 1)-1]
+}
+
+// counters counts errors by kind
+type counters struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *counters) inc(kind string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = map[string]int{}
+	}
+	c.counts[kind]++
+}
+
+func (c *counters) snapshot() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.counts)
+}
+
+// Report is the result of a load test
+type Report struct {
+	Sessions     int            `json:"sessions"`
+	Participants int            `json:"participants"` // Per session
+	Rounds       int            `json:"rounds"`       // Completed, over all sessions
+	Duration     time.Duration  `json:"duration"`
+	Connect      Summary        `json:"connect"`  // Dial until the first snapshot
+	Messages     Summary        `json:"messages"` // Send until the sender sees the resulting patch
+	Delivery     Summary        `json:"delivery"` // Moderator send until each participant sees the patch
+	Fanout       Summary        `json:"fanout"`   // Moderator send until the last participant sees the patch
+	Errors       map[string]int `json:"errors"`
+}
+
+// print writes the report as a table
+func (r Report) print(w io.Writer) {
+	fmt.Fprintf(w, "%d sessions × %d participants, %d rounds in %s\n\n",
+		r.Sessions, r.Participants, r.Rounds, r.Duration.Round(time.Millisecond))
+
+	fmt.Fprintf(w, "%-10s %8s %10s %10s %10s %10s\n", "", "count", "p50", "p90", "p99", "max")
+	for _, row := range []struct {
+		name string
+		s    Summary
+	}{
+		{"connect", r.Connect},
+		{"message", r.Messages},
+		{"delivery", r.Delivery},
+		{"fan-out", r.Fanout},
+	} {
+		fmt.Fprintf(w, "%-10s %8d %10s %10s %10s %10s\n", row.name, row.s.Count,
+			roundDuration(row.s.P50), roundDuration(row.s.P90), roundDuration(row.s.P99), roundDuration(row.s.Max))
+	}
+
+	fmt.Fprintln(w)
+	if len(r.Errors) == 0 {
+		fmt.Fprintln(w, "No errors")
+		return
+	}
+	fmt.Fprintln(w, "Errors:")
+	for _, kind := range slices.Sorted(maps.Keys(r.Errors)) {
+		fmt.Fprintf(w, "  %-24s %d\n", kind, r.Errors[kind])
+	}
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencySummary(t *testing.T) {
+	var l latencies
+	if got := l.summary(); got != (Summary{}) {
+		t.Errorf("Expected an empty summary, got %+v", got)
+	}
+
+	// 100 samples of 1ms to 100ms, added out of order
+	for i := 100; i >= 1; i-- {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+	want := Summary{Count: 100, P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P99: 99 * time.Millisecond, Max: 100 * time.Millisecond}
+	if got := l.summary(); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	var one latencies
+	one.add(7 * time.Millisecond)
+	if got := one.summary(); got.P50 != 7*time.Millisecond || got.P99 != 7*time.Millisecond {
+		t.Errorf("Expected every percentile of one sample to be it, got %+v", got)
+	}
+}
+
+func TestReportPrint(t *testing.T) {
+	var out strings.Builder
+	Report{
+		Sessions:     2,
+		Participants: 3,
+		Rounds:       4,
+		Duration:     1500 * time.Millisecond,
+		Fanout:       Summary{Count: 10, P50: 1234567 * time.Nanosecond, Max: 2 * time.Second},
+		Errors:       map[string]int{"vote_timeout": 1, "connect": 2},
+	}.print(&out)
+
+	for _, want := range []string{
+		"2 sessions × 3 participants, 4 rounds in 1.5s",
+		"fan-out          10     1.23ms",
+		"2s",
+		"  connect                  2\n  vote_timeout             1",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in\n%s", want, out.String())
+		}
+	}
+}
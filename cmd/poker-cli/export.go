@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"planning-poker/internal/poker"
+)
+
+// export writes estimated stories in format
+func export(w io.Writer, format string, stories []poker.Story) error {
+	if stories == nil {
+		stories = []poker.Story{}
+	}
+
+	switch format {
+	case "csv":
+		out := csv.NewWriter(w)
+		out.Write([]string{"key", "title", "estimate", "url"})
+		for _, story := range stories {
+			out.Write([]string{story.Key, story.Title, story.Estimate, story.URL})
+		}
+		out.Flush()
+		return out.Error()
+
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stories)
+
+	case "markdown":
+		fmt.Fprintln(w, "| Key | Story | Estimate |")
+		fmt.Fprintln(w, "| --- | --- | --- |")
+		for _, story := range stories {
+			title := markdownCell(story.Title)
+			if story.URL != "" {
+				title = "[" + title + "](" + story.URL + ")"
+			}
+			fmt.Fprintf(w, "| %s | %s | %s |\n", markdownCell(story.Key), title, markdownCell(story.Estimate))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown export format %q; use csv, json or markdown", format)
+}
+
+// markdownCell escapes the characters that would end a table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
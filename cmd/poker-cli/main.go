@@ -0,0 +1,224 @@
+// Command poker-cli votes in and moderates planning poker sessions from a
+// terminal. The one-shot subcommands use the REST API so that scripts can
+// chain them; tui joins over a WebSocket and shows the session live.
+//
+//	TOKEN=$(poker-cli create -session SPRINT42 -moderator alice)
+//	poker-cli vote -session SPRINT42 -token "$TOKEN" 5
+//	poker-cli tui -session SPRINT42 -name bob
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"planning-poker/pkg/client"
+)
+
+const usage = `Usage: poker-cli <command> [flags] [args]
+
+Commands:
+  create   Create a session, printing the moderator token if -moderator is set
+  join     Join a session over REST, printing the participant token
+  vote     Vote in the current round: vote [flags] <value>
+  reveal   Reveal the votes (moderator only)
+  next     Move to the next queued story (moderator only)
+  export   Print the estimated stories as csv, json or markdown
+  tui      Join over a WebSocket and vote and moderate interactively
+
+Flags common to every command:
+  -server   Server URL (default $POKER_SERVER or http://localhost:8080)
+  -session  Session ID (default $POKER_SESSION)
+  -token    Participant token for vote, reveal and next (default $POKER_TOKEN)
+
+Run "poker-cli <command> -h" for the flags of a command.
+`
+
+// errUsage is returned for invalid arguments; the flag package has already
+// explained what is wrong
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "poker-cli:", err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command in args. Results and the TUI go to stdout and
+// progress messages to stderr, so that scripts can capture tokens.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		fmt.Fprint(stderr, usage)
+		if len(args) == 0 {
+			return errUsage
+		}
+		return nil
+	}
+
+	name, args := args[0], args[1:]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%s", name, usage)
+		return errUsage
+	}
+
+	fs := flag.NewFlagSet("poker-cli "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts := &options{
+		server:  envOr("POKER_SERVER", "http://localhost:8080"),
+		session: os.Getenv("POKER_SESSION"),
+		token:   os.Getenv("POKER_TOKEN"),
+	}
+	fs.StringVar(&opts.server, "server", opts.server, "Server URL")
+	fs.StringVar(&opts.session, "session", opts.session, "Session ID")
+	fs.StringVar(&opts.token, "token", opts.token, "Participant token")
+	if cmd.flags != nil {
+		cmd.flags(fs, opts)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if fs.NArg() != cmd.args {
+		fmt.Fprintf(stderr, "poker-cli %s takes %d argument(s), got %d\n", name, cmd.args, fs.NArg())
+		fs.Usage()
+		return errUsage
+	}
+	if opts.session == "" {
+		fmt.Fprintln(stderr, "-session is required")
+		return errUsage
+	}
+	if cmd.needsToken && opts.token == "" {
+		fmt.Fprintf(stderr, "-token is required; get one from \"poker-cli create -moderator\" or \"poker-cli join\"\n")
+		return errUsage
+	}
+
+	opts.client = client.New(opts.server).WithToken(opts.token)
+	opts.stdin, opts.stdout, opts.stderr = stdin, stdout, stderr
+	return cmd.run(ctx, opts, fs.Args())
+}
+
+// options holds the flags of a command
+type options struct {
+	server, session, token string
+
+	name    string // create -moderator, join and tui -name
+	creator bool   // join and tui
+	format  string // export
+
+	client         *client.Client
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+// command is a poker-cli subcommand
+type command struct {
+	flags      func(fs *flag.FlagSet, opts *options)
+	args       int  // Number of positional arguments
+	needsToken bool // Whether the command acts as a participant
+	run        func(ctx context.Context, opts *options, args []string) error
+}
+
+var commands = map[string]command{
+	"create": {
+		flags: func(fs *flag.FlagSet, opts *options) {
+			fs.StringVar(&opts.name, "moderator", "", "Join as the creator with this name and print the moderator token")
+		},
+		run: runCreate,
+	},
+	"join": {
+		flags: func(fs *flag.FlagSet, opts *options) {
+			fs.StringVar(&opts.name, "name", os.Getenv("USER"), "Name shown to other participants")
+			fs.BoolVar(&opts.creator, "creator", false, "Join as the session creator")
+		},
+		run: runJoin,
+	},
+	"vote":   {args: 1, needsToken: true, run: runVote},
+	"reveal": {needsToken: true, run: runReveal},
+	"next":   {needsToken: true, run: runNext},
+	"export": {
+		flags: func(fs *flag.FlagSet, opts *options) {
+			fs.StringVar(&opts.format, "format", "csv", "Output format: csv, json or markdown")
+		},
+		run: runExport,
+	},
+	"tui": {
+		flags: func(fs *flag.FlagSet, opts *options) {
+			fs.StringVar(&opts.name, "name", os.Getenv("USER"), "Name shown to other participants")
+			fs.BoolVar(&opts.creator, "creator", false, "Join as the session creator, who starts the session and moderates it")
+		},
+		run: runTUI,
+	},
+}
+
+func runCreate(ctx context.Context, opts *options, args []string) error {
+	created, err := opts.client.CreateSession(ctx, opts.session, opts.name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(opts.stderr, "Session %s created\n", created.SessionID)
+	if created.ModeratorToken != "" {
+		fmt.Fprintln(opts.stdout, created.ModeratorToken)
+	}
+	return nil
+}
+
+func runJoin(ctx context.Context, opts *options, args []string) error {
+	if opts.name == "" {
+		return errors.New("-name is required")
+	}
+	joined, err := opts.client.Join(ctx, opts.session, client.JoinRequest{
+		Name:      opts.name,
+		Creator:   opts.creator,
+		Transport: client.TransportREST,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(opts.stderr, "Joined session %s as %s\n", opts.session, opts.name)
+	fmt.Fprintln(opts.stdout, joined.Token)
+	return nil
+}
+
+func runVote(ctx context.Context, opts *options, args []string) error {
+	return opts.client.Vote(ctx, opts.session, args[0])
+}
+
+func runReveal(ctx context.Context, opts *options, args []string) error {
+	return opts.client.Reveal(ctx, opts.session)
+}
+
+func runNext(ctx context.Context, opts *options, args []string) error {
+	return opts.client.NextStory(ctx, opts.session)
+}
+
+func runExport(ctx context.Context, opts *options, args []string) error {
+	state, err := opts.client.State(ctx, opts.session)
+	if err != nil {
+		return err
+	}
+	return export(opts.stdout, opts.format, state.Estimated)
+}
+
+func envOr(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"planning-poker/internal/poker"
+	"planning-poker/internal/server"
+	"planning-poker/pkg/client"
+)
+
+// newTestServer serves the routes the CLI uses from a real server
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := server.New()
+	srv.AddTracker(testTracker{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", srv.HandleWebSocket)
+	mux.HandleFunc("/api/sessions", srv.HandleSessions)
+	mux.HandleFunc("/api/sessions/", srv.HandleSession)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// testTracker imports a single issue, whose key exports carry
+type testTracker struct{}
+
+func (testTracker) Name() string { return "test" }
+
+func (testTracker) Import(ctx context.Context, query string) ([]poker.Story, error) {
+	return []poker.Story{{Key: "PP-1", Title: query}}, nil
+}
+
+func (testTracker) WriteEstimate(ctx context.Context, story poker.Story) error { return nil }
+
+// cli runs poker-cli with args and returns its stdout
+func cli(t *testing.T, ts *httptest.Server, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append(args[:1:1], append([]string{"-server", ts.URL, "-session", "CLI1"}, args[1:]...)...)
+	err := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr)
+	return strings.TrimSpace(stdout.String()), err
+}
+
+func sessionState(t *testing.T, ts *httptest.Server) poker.SessionState {
+	t.Helper()
+	state, err := client.New(ts.URL).State(context.Background(), "CLI1")
+	if err != nil {
+		t.Fatalf("Failed to get state: %v", err)
+	}
+	return state
+}
+
+func TestCommands(t *testing.T) {
+	ts := newTestServer(t)
+
+	modToken, err := cli(t, ts, "create", "-moderator", "alice")
+	if err != nil || modToken == "" {
+		t.Fatalf("create failed: %q, %v", modToken, err)
+	}
+	if err := client.New(ts.URL).WithToken(modToken).StartSession(context.Background(), "CLI1"); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	token, err := cli(t, ts, "join", "-name", "bob")
+	if err != nil || token == "" {
+		t.Fatalf("join failed: %q, %v", token, err)
+	}
+
+	if _, err := cli(t, ts, "vote", "-token", token, "8"); err != nil {
+		t.Fatalf("vote failed: %v", err)
+	}
+	if _, err := cli(t, ts, "reveal", "-token", token); err == nil || !strings.Contains(err.Error(), "only the moderator") {
+		t.Errorf("Expected a participant's reveal to be refused, got %v", err)
+	}
+	if _, err := cli(t, ts, "reveal", "-token", modToken); err != nil {
+		t.Fatalf("reveal failed: %v", err)
+	}
+
+	state := sessionState(t, ts)
+	if !state.VotesRevealed {
+		t.Fatal("Expected votes to be revealed")
+	}
+	for _, user := range state.Users {
+		if user.Name == "bob" && (user.Vote == nil || *user.Vote != "8") {
+			t.Errorf("Expected bob's vote of 8, got %v", user.Vote)
+		}
+	}
+
+	if _, err := cli(t, ts, "next", "-token", modToken); err == nil || !strings.Contains(err.Error(), "queue is empty") {
+		t.Errorf("Expected next to fail on an empty queue, got %v", err)
+	}
+	if _, err := cli(t, ts, "vote", "8"); err == nil {
+		t.Error("Expected vote without a token to fail")
+	}
+}
+
+func TestExport(t *testing.T) {
+	ts := newTestServer(t)
+	modToken, _ := cli(t, ts, "create", "-moderator", "alice")
+	moderator := client.New(ts.URL).WithToken(modToken)
+	ctx := context.Background()
+	moderator.StartSession(ctx, "CLI1")
+	if _, err := moderator.Import(ctx, "CLI1", "test", "Login, with SSO"); err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	moderator.NextStory(ctx, "CLI1")
+	moderator.Vote(ctx, "CLI1", "5")
+	moderator.Reveal(ctx, "CLI1")
+	if err := moderator.SetEstimate(ctx, "CLI1", "5"); err != nil {
+		t.Fatalf("Failed to set estimate: %v", err)
+	}
+
+	tests := map[string]string{
+		"csv":      "key,title,estimate,url\nPP-1,\"Login, with SSO\",5,",
+		"markdown": "| Key | Story | Estimate |\n| --- | --- | --- |\n| PP-1 | Login, with SSO | 5 |",
+	}
+	for format, want := range tests {
+		got, err := cli(t, ts, "export", "-format", format)
+		if err != nil || got != want {
+			t.Errorf("%s: expected\n%s\ngot\n%s (%v)", format, want, got, err)
+		}
+	}
+
+	got, _ := cli(t, ts, "export", "-format", "json")
+	var stories []poker.Story
+	if json.Unmarshal([]byte(got), &stories) != nil || len(stories) != 1 || stories[0].Estimate != "5" {
+		t.Errorf("Unexpected JSON export %s", got)
+	}
+}
+
+func TestTUI(t *testing.T) {
+	ts := newTestServer(t)
+	stdin, input := io.Pipe()
+	var stdout bytes.Buffer
+
+	done := make(chan error, 1)
+	go func() {
+		args := []string{"tui", "-server", ts.URL, "-session", "CLI1", "-name", "alice", "-creator"}
+		done <- run(context.Background(), args, stdin, &stdout, io.Discard)
+	}()
+
+	waitFor := func(what string, ok func(poker.SessionState) bool) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			// The session does not exist until the TUI has connected
+			state, err := client.New(ts.URL).State(context.Background(), "CLI1")
+			if err == nil && ok(state) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("alice to join", func(s poker.SessionState) bool { return len(s.Users) == 1 })
+
+	io.WriteString(input, "start\nstory Login page\n5\nreveal\n")
+	waitFor("votes to be revealed", func(s poker.SessionState) bool { return s.VotesRevealed })
+
+	io.WriteString(input, "quit\n")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("tui failed: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("tui did not quit")
+	}
+
+	if state := sessionState(t, ts); state.CurrentStory != "Login page" || state.Status != poker.SessionStatusActive {
+		t.Errorf("Unexpected state %+v", state)
+	}
+}
+
+func TestBoardRender(t *testing.T) {
+	hidden := "?"
+	b := &board{name: "alice", myVote: "5"}
+	b.apply(client.Event{Kind: client.EventState, State: client.SessionState{
+		ID:           "CLI1",
+		Status:       client.StatusActive,
+		CurrentStory: "Login",
+		Users: map[string]*client.ParticipantView{
+			"a": {ID: "a", Name: "alice", Vote: &hidden, IsModerator: true},
+			"b": {ID: "b", Name: "bob", Vote: &hidden},
+			"c": {ID: "c", Name: "carol"},
+		},
+	}})
+	b.apply(client.Event{Kind: client.EventShutdown})
+
+	var out strings.Builder
+	b.render(&out)
+	for _, want := range []string{
+		"session CLI1 · active",
+		"Story: Login",
+		"alice (moderator)                voted (5)",
+		"bob                              voted",
+		"carol                            -",
+		"The server is restarting. Reconnecting...",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in\n%s", want, out.String())
+		}
+	}
+
+	b.apply(client.Event{Kind: client.EventReconnected})
+	b.apply(client.Event{Kind: client.EventState, Patch: &client.StatePatch{Op: client.MessageTypeNewRound}, State: client.SessionState{Status: client.StatusActive}})
+	if b.connection != "" || b.myVote != "" {
+		t.Errorf("Expected the reconnect and new round to clear the board, got %+v", b)
+	}
+}
+
+func TestParseInput(t *testing.T) {
+	tests := []struct {
+		line string
+		want string // Message JSON, "quit" or "error"
+	}{
+		{"5", `{"type":"vote","data":{"vote":"5"}}`},
+		{"vote ?", `{"type":"vote","data":{"vote":"?"}}`},
+		{"story Login page", `{"type":"set_story","data":{"story":"Login page"}}`},
+		{"add Logout", `{"type":"add_stories","data":{"stories":[{"title":"Logout"}]}}`},
+		{"Reveal", `{"type":"reveal"}`},
+		{"estimate 8", `{"type":"set_estimate","data":{"estimate":"8"}}`},
+		{"q", "quit"},
+		{"story", "error"},
+		{"frobnicate the board", "error"},
+	}
+
+	for _, tt := range tests {
+		msg, quit, err := parseInput(tt.line)
+		var got string
+		switch {
+		case quit:
+			got = "quit"
+		case err != nil:
+			got = "error"
+		default:
+			got = string(mustJSON(msg))
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.line, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"planning-poker/internal/poker"
+	"planning-poker/pkg/client"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+const tuiHelp = "<value> or vote <value>, reveal, new, next, story <text>, add <title>, estimate <value>, start, end, quit"
+
+// runTUI joins over a WebSocket, redraws the session on every change and
+// turns typed lines into messages
+func runTUI(ctx context.Context, opts *options, args []string) error {
+	if opts.name == "" {
+		return errors.New("-name is required")
+	}
+
+	conn, err := opts.client.Dial(ctx, client.DialOptions{
+		Session: opts.session,
+		Name:    opts.name,
+		Creator: opts.creator,
+		Logger:  slog.New(slog.DiscardHandler), // The board shows connection problems
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(opts.stdin)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	b := &board{name: opts.name}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-conn.Events():
+			if !ok {
+				return errors.New("disconnected")
+			}
+			b.apply(event)
+			b.render(opts.stdout)
+
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			msg, quit, err := parseInput(line)
+			switch {
+			case quit:
+				return nil
+			case err != nil:
+				b.notice = err.Error()
+			case msg != nil:
+				if err := conn.Send(*msg); err != nil {
+					b.notice = err.Error()
+					break
+				}
+				if msg.Type == poker.MessageTypeVote {
+					b.myVote = voteOf(*msg)
+				}
+				b.notice = ""
+			}
+			b.render(opts.stdout)
+		}
+	}
+}
+
+// board is the session as the TUI shows it
+type board struct {
+	name       string
+	state      *client.SessionState
+	waiting    bool   // Whether the session has not started yet
+	myVote     string // Votes read "?" until revealed, even our own
+	notice     string // Shown above the prompt
+	connection string // Connection problems, while reconnecting
+}
+
+// apply updates the board with a connection event
+func (b *board) apply(event client.Event) {
+	switch event.Kind {
+	case client.EventState:
+		b.state = &event.State
+		b.waiting = event.State.Status == client.StatusWaiting
+		if event.Patch != nil {
+			switch event.Patch.Op {
+			case client.MessageTypeSetStory, client.MessageTypeNewRound, client.MessageTypeNextStory:
+				b.myVote = ""
+			}
+		}
+	case client.EventWaitingRoom:
+		b.waiting = true
+	case client.EventShutdown:
+		b.connection = "The server is restarting. Reconnecting..."
+	case client.EventDisconnected:
+		if b.connection == "" {
+			b.connection = "Disconnected (" + event.Err.Error() + "). Reconnecting..."
+		}
+	case client.EventReconnected:
+		b.connection = ""
+	}
+}
+
+// render redraws the whole board
+func (b *board) render(w io.Writer) {
+	var sb strings.Builder
+	sb.WriteString(clearScreen)
+
+	if b.state == nil {
+		sb.WriteString("Connecting...\n")
+		io.WriteString(w, sb.String())
+		return
+	}
+	s := b.state
+
+	fmt.Fprintf(&sb, "Planning poker · session %s · %s\n\n", s.ID, s.Status)
+	switch {
+	case s.Status == client.StatusEnded:
+		sb.WriteString("The session has ended.\n")
+	case b.waiting:
+		sb.WriteString("Waiting for the session creator to start the session...\n")
+	default:
+		story := s.CurrentStory
+		if story == "" {
+			story = "(none)"
+		}
+		fmt.Fprintf(&sb, "Story: %s\n", story)
+	}
+	fmt.Fprintf(&sb, "Queue: %d · Estimated: %d\n\n", len(s.Queue), len(s.Estimated))
+
+	users := make([]*client.ParticipantView, 0, len(s.Users))
+	for _, user := range s.Users {
+		users = append(users, user)
+	}
+	slices.SortFunc(users, func(a, b *client.ParticipantView) int { return strings.Compare(a.Name, b.Name) })
+
+	for _, user := range users {
+		name := user.Name
+		if user.IsModerator {
+			name += " (moderator)"
+		}
+		vote := "-"
+		switch {
+		case user.Vote == nil:
+		case s.VotesRevealed:
+			vote = *user.Vote
+		case user.Name == b.name && b.myVote != "":
+			vote = "voted (" + b.myVote + ")"
+		default:
+			vote = "voted"
+		}
+		fmt.Fprintf(&sb, "  %-32s %s\n", name, vote)
+	}
+
+	if len(s.Estimated) > 0 {
+		sb.WriteString("\nEstimated:\n")
+		for _, story := range s.Estimated {
+			fmt.Fprintf(&sb, "  %-32s %s\n", strings.TrimSpace(story.Key+" "+story.Title), story.Estimate)
+		}
+	}
+
+	sb.WriteString("\n")
+	if b.connection != "" {
+		sb.WriteString(b.connection + "\n")
+	}
+	if b.notice != "" {
+		sb.WriteString(b.notice + "\n")
+	}
+	sb.WriteString(tuiHelp + "\n> ")
+	io.WriteString(w, sb.String())
+}
+
+// parseInput turns a typed line into a message. A single word that is not
+// a command is a vote.
+func parseInput(line string) (msg *poker.Message, quit bool, err error) {
+	word, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	rest = strings.TrimSpace(rest)
+
+	message := func(t poker.MessageType, data any) *poker.Message {
+		msg := &poker.Message{Type: t}
+		if data != nil {
+			msg.Data = mustJSON(data)
+		}
+		return msg
+	}
+	requireArg := func(usage string) error {
+		if rest == "" {
+			return errors.New("Usage: " + usage)
+		}
+		return nil
+	}
+
+	switch strings.ToLower(word) {
+	case "":
+		return nil, false, nil
+	case "quit", "q", "exit":
+		return nil, true, nil
+	case "help", "?":
+		return nil, false, errors.New("Commands: " + tuiHelp)
+	case "start":
+		return message(poker.MessageTypeStartSession, nil), false, nil
+	case "reveal":
+		return message(poker.MessageTypeReveal, nil), false, nil
+	case "new":
+		return message(poker.MessageTypeNewRound, nil), false, nil
+	case "next":
+		return message(poker.MessageTypeNextStory, nil), false, nil
+	case "end":
+		return message(poker.MessageTypeEndSession, nil), false, nil
+	case "vote", "v":
+		if err := requireArg("vote <value>"); err != nil {
+			return nil, false, err
+		}
+		return message(poker.MessageTypeVote, client.VoteRequest{Vote: rest}), false, nil
+	case "story":
+		if err := requireArg("story <text>"); err != nil {
+			return nil, false, err
+		}
+		return message(poker.MessageTypeSetStory, client.StoryRequest{Story: rest}), false, nil
+	case "add":
+		if err := requireArg("add <title>"); err != nil {
+			return nil, false, err
+		}
+		return message(poker.MessageTypeAddStories, client.AddStoriesRequest{Stories: []poker.Story{{Title: rest}}}), false, nil
+	case "estimate":
+		if err := requireArg("estimate <value>"); err != nil {
+			return nil, false, err
+		}
+		return message(poker.MessageTypeSetEstimate, client.EstimateRequest{Estimate: rest}), false, nil
+	}
+
+	if rest != "" {
+		return nil, false, fmt.Errorf("Unknown command %q; type help for the commands", word)
+	}
+	return message(poker.MessageTypeVote, client.VoteRequest{Vote: word}), false, nil
+}
+
+// voteOf returns the value of a vote message
+func voteOf(msg poker.Message) string {
+	var req client.VoteRequest
+	json.Unmarshal(msg.Data, &req)
+	return req.Vote
+}
+
+func mustJSON(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -86,7 +86,7 @@ if docker ps -q -f name="$CONTAINER_NAME" | grep -q .; then
     log "Container ID: $(docker ps -q -f name="$CONTAINER_NAME")"
     
     # Test the application
-    if curl -f -s "http://localhost:$PORT/" > /dev/null; then
+    if curl -f -s "http://localhost:$PORT/readyz" > /dev/null; then
         log "✅ Application health check passed"
     else
         log "⚠️  Application health check failed, but container is running"
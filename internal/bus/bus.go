@@ -0,0 +1,33 @@
+// Package bus distributes session events between server instances.
+package bus
+
+// Handler receives a payload published on a topic. A nil payload means that
+// payloads may have been lost before this point, such as while a connection
+// was down, and the subscriber should recover its state.
+type Handler func(payload []byte)
+
+// Bus is a topic based publish/subscribe transport. Payloads published on a
+// topic are delivered to every subscriber of that topic, including
+// subscribers in the publishing process, in the same order for everyone.
+type Bus interface {
+	// Publish sends payload to all subscribers of topic.
+	Publish(topic string, payload []byte) error
+
+	// Subscribe registers handler for topic. Handlers for one topic are
+	// called sequentially; the returned function removes the subscription.
+	Subscribe(topic string, handler Handler) (func(), error)
+
+	// Ping reports whether the bus can currently deliver events.
+	Ping() error
+
+	// Close releases any resources held by the bus.
+	Close() error
+}
+
+// Counter is implemented by buses that know exactly how many subscribers a
+// topic has, such as Local. Buses spanning several processes cannot tell
+// reliably and do not implement it.
+type Counter interface {
+	// Subscribers returns the number of subscriptions to topic.
+	Subscribers(topic string) int
+}
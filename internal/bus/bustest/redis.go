@@ -0,0 +1,265 @@
+// Package bustest provides an in-memory Redis pub/sub server for tests.
+package bustest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// RedisServer speaks enough of the Redis protocol (AUTH, PING, PUBLISH,
+// SUBSCRIBE, UNSUBSCRIBE) to exercise a pub/sub client over real sockets.
+// Other commands, such as the HELLO a client sends to negotiate RESP3, get
+// an error reply, which clients take as a server speaking only RESP2.
+type RedisServer struct {
+	Addr     string
+	Password string
+
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[*redisConn]bool
+	channels map[string]map[*redisConn]bool
+}
+
+type redisConn struct {
+	net.Conn
+	writeMu sync.Mutex
+	authed  bool
+}
+
+// NewRedisServer starts a server on a random local port that is shut down
+// when the test finishes
+func NewRedisServer(t testing.TB) *RedisServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start fake Redis: %v", err)
+	}
+
+	srv := &RedisServer{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		conns:    make(map[*redisConn]bool),
+		channels: make(map[string]map[*redisConn]bool),
+	}
+	go srv.serve()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// Close stops accepting connections and drops all clients
+func (srv *RedisServer) Close() {
+	srv.listener.Close()
+	srv.DropConnections()
+}
+
+// DropConnections closes every client connection, simulating a restart
+func (srv *RedisServer) DropConnections() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	srv.conns = make(map[*redisConn]bool)
+	srv.channels = make(map[string]map[*redisConn]bool)
+}
+
+// Subscribers returns the number of connections subscribed to channel
+func (srv *RedisServer) Subscribers(channel string) int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.channels[channel])
+}
+
+func (srv *RedisServer) serve() {
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		rc := &redisConn{Conn: conn}
+		srv.mu.Lock()
+		srv.conns[rc] = true
+		srv.mu.Unlock()
+
+		go srv.handle(rc)
+	}
+}
+
+func (srv *RedisServer) handle(conn *redisConn) {
+	defer srv.disconnect(conn)
+
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		args[0] = strings.ToUpper(args[0])
+
+		if srv.Password != "" && !conn.authed && args[0] != "AUTH" {
+			conn.write("-NOAUTH Authentication required.\r\n")
+			continue
+		}
+
+		switch args[0] {
+		case "AUTH":
+			if len(args) == 2 && args[1] == srv.Password {
+				conn.authed = true
+				conn.write("+OK\r\n")
+			} else {
+				conn.write("-WRONGPASS invalid password\r\n")
+			}
+
+		case "PING":
+			conn.write("+PONG\r\n")
+
+		case "SUBSCRIBE":
+			for _, channel := range args[1:] {
+				count := srv.subscribe(conn, channel, true)
+				conn.write(array("subscribe", channel) + ":" + strconv.Itoa(count) + "\r\n")
+			}
+
+		case "UNSUBSCRIBE":
+			for _, channel := range args[1:] {
+				count := srv.subscribe(conn, channel, false)
+				conn.write(array("unsubscribe", channel) + ":" + strconv.Itoa(count) + "\r\n")
+			}
+
+		case "PUBLISH":
+			if len(args) != 3 {
+				conn.write("-ERR wrong number of arguments\r\n")
+				continue
+			}
+			conn.write(":" + strconv.Itoa(srv.publish(args[1], args[2])) + "\r\n")
+
+		default:
+			conn.write(fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0]))
+		}
+	}
+}
+
+// subscribe adds or removes conn from channel and returns how many
+// channels conn is subscribed to afterwards
+func (srv *RedisServer) subscribe(conn *redisConn, channel string, add bool) int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if add {
+		if srv.channels[channel] == nil {
+			srv.channels[channel] = make(map[*redisConn]bool)
+		}
+		srv.channels[channel][conn] = true
+	} else {
+		delete(srv.channels[channel], conn)
+	}
+
+	count := 0
+	for _, subscribers := range srv.channels {
+		if subscribers[conn] {
+			count++
+		}
+	}
+	return count
+}
+
+// publish delivers payload while holding the server lock, so that all
+// subscribers observe publishes in the same order
+func (srv *RedisServer) publish(channel, payload string) int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	message := "*3\r\n" + bulk("message") + bulk(channel) + bulk(payload)
+	for conn := range srv.channels[channel] {
+		conn.write(message)
+	}
+	return len(srv.channels[channel])
+}
+
+func (srv *RedisServer) disconnect(conn *redisConn) {
+	conn.Close()
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	delete(srv.conns, conn)
+	for _, subscribers := range srv.channels {
+		delete(subscribers, conn)
+	}
+}
+
+func (c *redisConn) write(data string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	io.WriteString(c.Conn, data)
+}
+
+// array encodes the first two elements of a three element reply
+func array(kind, channel string) string {
+	return "*3\r\n" + bulk(kind) + bulk(channel)
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+// readCommand reads a RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("expected array, got %q", line)
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(header) == 0 || header[0] != '$' {
+			return nil, fmt.Errorf("expected bulk string, got %q", header)
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:size]))
+	}
+
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 {
+		return "", fmt.Errorf("short line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
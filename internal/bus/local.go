@@ -0,0 +1,123 @@
+package bus
+
+import "sync"
+
+// Local is an in-process Bus for single instance deployments and tests.
+// Delivery is synchronous: Publish returns after every handler has run,
+// unless another goroutine is already delivering on the same topic, in which
+// case the payload is queued behind it so ordering is preserved.
+type Local struct {
+	mu     sync.Mutex
+	topics map[string]*localTopic
+	nextID int
+}
+
+type localTopic struct {
+	handlers   map[int]Handler
+	order      []int
+	queue      [][]byte
+	delivering bool
+}
+
+// NewLocal creates an empty in-process bus
+func NewLocal() *Local {
+	return &Local{
+		topics: make(map[string]*localTopic),
+	}
+}
+
+func (b *Local) Publish(topic string, payload []byte) error {
+	b.mu.Lock()
+	t, exists := b.topics[topic]
+	if !exists || len(t.handlers) == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+
+	t.queue = append(t.queue, payload)
+	if t.delivering {
+		// The goroutine already delivering will pick this up
+		b.mu.Unlock()
+		return nil
+	}
+
+	t.delivering = true
+	for len(t.queue) > 0 {
+		next := t.queue[0]
+		t.queue = t.queue[1:]
+
+		handlers := make([]Handler, 0, len(t.order))
+		for _, id := range t.order {
+			handlers = append(handlers, t.handlers[id])
+		}
+
+		b.mu.Unlock()
+		for _, handler := range handlers {
+			handler(next)
+		}
+		b.mu.Lock()
+	}
+	t.delivering = false
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Subscribers returns the number of handlers subscribed to topic
+func (b *Local) Subscribers(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if t, exists := b.topics[topic]; exists {
+		return len(t.handlers)
+	}
+	return 0
+}
+
+func (b *Local) Subscribe(topic string, handler Handler) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, exists := b.topics[topic]
+	if !exists {
+		t = &localTopic{handlers: make(map[int]Handler)}
+		b.topics[topic] = t
+	}
+
+	id := b.nextID
+	b.nextID++
+	t.handlers[id] = handler
+	t.order = append(t.order, id)
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, exists := t.handlers[id]; !exists {
+			return
+		}
+		delete(t.handlers, id)
+		for i, existing := range t.order {
+			if existing == id {
+				t.order = append(t.order[:i], t.order[i+1:]...)
+				break
+			}
+		}
+		if len(t.handlers) == 0 && !t.delivering {
+			delete(b.topics, topic)
+		}
+	}, nil
+}
+
+func (b *Local) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.topics = make(map[string]*localTopic)
+	return nil
+}
+
+// Ping always succeeds; an in-process bus cannot become unreachable
+func (b *Local) Ping() error {
+	return nil
+}
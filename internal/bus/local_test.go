@@ -0,0 +1,79 @@
+package bus
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLocalPublishSubscribe(t *testing.T) {
+	b := NewLocal()
+
+	var first, second []string
+	b.Subscribe("topic", func(payload []byte) { first = append(first, string(payload)) })
+	b.Subscribe("topic", func(payload []byte) { second = append(second, string(payload)) })
+	b.Subscribe("other", func(payload []byte) { t.Errorf("Unexpected delivery on other topic: %s", payload) })
+
+	if err := b.Publish("topic", []byte("a")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if subscribers := b.Subscribers("topic"); subscribers != 2 {
+		t.Errorf("Expected 2 subscribers, got %d", subscribers)
+	}
+	b.Publish("topic", []byte("b"))
+
+	for _, got := range [][]string{first, second} {
+		if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+			t.Errorf("Expected [a b], got %v", got)
+		}
+	}
+}
+
+func TestLocalUnsubscribe(t *testing.T) {
+	b := NewLocal()
+
+	count := 0
+	unsubscribe, _ := b.Subscribe("topic", func([]byte) { count++ })
+
+	b.Publish("topic", []byte("a"))
+	unsubscribe()
+	b.Publish("topic", []byte("b"))
+
+	if count != 1 {
+		t.Errorf("Expected 1 delivery, got %d", count)
+	}
+	if subscribers := b.Subscribers("topic"); subscribers != 0 {
+		t.Errorf("Expected 0 subscribers after unsubscribe, got %d", subscribers)
+	}
+}
+
+func TestLocalNestedPublishKeepsOrder(t *testing.T) {
+	b := NewLocal()
+
+	var mu sync.Mutex
+	var first, second []string
+	record := func(list *[]string) Handler {
+		return func(payload []byte) {
+			mu.Lock()
+			*list = append(*list, string(payload))
+			mu.Unlock()
+		}
+	}
+
+	// The first handler publishes a reply while "request" is being delivered
+	b.Subscribe("topic", func(payload []byte) {
+		record(&first)(payload)
+		if string(payload) == "request" {
+			b.Publish("topic", []byte("reply"))
+		}
+	})
+	b.Subscribe("topic", record(&second))
+
+	b.Publish("topic", []byte("request"))
+
+	// Both handlers must see request before reply
+	for _, got := range [][]string{first, second} {
+		if len(got) != 2 || got[0] != "request" || got[1] != "reply" {
+			t.Errorf("Expected [request reply], got %v", got)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrClosed is returned by operations on a closed bus
+var ErrClosed = errors.New("bus: closed")
+
+const (
+	redisDialTimeout    = 5 * time.Second
+	redisMaxRetryDelay  = 10 * time.Second
+	redisMinRetryDelay  = 100 * time.Millisecond
+	redisCommandTimeout = 5 * time.Second
+)
+
+// RedisOptions configures a Redis bus
+type RedisOptions struct {
+	Addr     string // host:port of the Redis server
+	Password string // Optional AUTH password
+}
+
+// Redis is a Bus backed by Redis PUBLISH/SUBSCRIBE, so that every server
+// instance connected to the same Redis sees the same ordered event stream.
+// Publishing goes through a go-redis connection pool and subscriptions
+// through a single PubSub connection. Each topic is delivered on its own
+// goroutine, so a slow handler only holds up its own topic.
+//
+// The subscriber connection is re-established with backoff if it drops.
+// Redis does not keep messages for disconnected subscribers, so once a
+// topic is subscribed again its handlers receive a nil payload to recover
+// what they missed. Publish waits for the subscriber connection to be back,
+// so that publishers do not miss their own messages.
+type Redis struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+
+	subMu    sync.Mutex
+	handlers map[string]map[int]Handler
+	order    map[string][]int
+	pending  map[string]chan struct{} // Subscriptions awaiting confirmation
+	nextID   int
+	subErr   error         // Why the subscriber connection is down, nil while connected
+	subReady chan struct{} // Closed once every topic is subscribed after a reconnect
+
+	restoring  map[string]bool     // Topics resubscribed but not yet confirmed
+	queued     map[string][][]byte // Payloads awaiting delivery, by topic
+	delivering map[string]bool     // Topics with a delivery goroutine running
+
+	closed chan struct{}
+	done   chan struct{}
+}
+
+// NewRedis connects to Redis and starts the subscription reader
+func NewRedis(opts RedisOptions) (*Redis, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:         opts.Addr,
+		Password:     opts.Password,
+		DialTimeout:  redisDialTimeout,
+		ReadTimeout:  redisCommandTimeout,
+		WriteTimeout: redisCommandTimeout,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis %s: %w", opts.Addr, err)
+	}
+
+	b := &Redis{
+		client:   client,
+		pubsub:   client.Subscribe(ctx),
+		handlers: make(map[string]map[int]Handler),
+		order:    make(map[string][]int),
+		pending:  make(map[string]chan struct{}),
+		subReady: make(chan struct{}),
+
+		restoring:  make(map[string]bool),
+		queued:     make(map[string][][]byte),
+		delivering: make(map[string]bool),
+
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	close(b.subReady)
+
+	go b.readLoop()
+
+	return b, nil
+}
+
+func (b *Redis) Publish(topic string, payload []byte) error {
+	if err := b.waitSubscribed(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+	defer cancel()
+	if err := b.client.Publish(ctx, topic, payload).Err(); err != nil {
+		if errors.Is(err, redis.ErrClosed) {
+			return ErrClosed
+		}
+		return fmt.Errorf("redis publish: %w", err)
+	}
+	return nil
+}
+
+// waitSubscribed waits until the subscriber connection is up, for at most
+// the command timeout. Messages published while it is down would not reach
+// this instance's own handlers.
+func (b *Redis) waitSubscribed() error {
+	b.subMu.Lock()
+	ready := b.subReady
+	b.subMu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-b.closed:
+		return ErrClosed
+	case <-time.After(redisCommandTimeout):
+		b.subMu.Lock()
+		defer b.subMu.Unlock()
+		return fmt.Errorf("redis publish: subscription lost: %w", b.subErr)
+	}
+}
+
+// Ping fails while the subscriber connection is down, and otherwise sends
+// PING to Redis
+func (b *Redis) Ping() error {
+	b.subMu.Lock()
+	subErr := b.subErr
+	b.subMu.Unlock()
+	if subErr != nil {
+		return fmt.Errorf("redis subscription lost: %w", subErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+	defer cancel()
+	if err := b.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis ping: %w", err)
+	}
+	return nil
+}
+
+func (b *Redis) Subscribe(topic string, handler Handler) (func(), error) {
+	b.subMu.Lock()
+
+	select {
+	case <-b.closed:
+		b.subMu.Unlock()
+		return nil, ErrClosed
+	default:
+	}
+
+	if len(b.handlers[topic]) == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+		err := b.pubsub.Subscribe(ctx, topic)
+		cancel()
+		if err != nil {
+			// The PubSub keeps the channel to resubscribe; drop it again
+			ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+			b.pubsub.Unsubscribe(ctx, topic)
+			cancel()
+			b.subMu.Unlock()
+			return nil, fmt.Errorf("redis subscribe: %w", err)
+		}
+		b.handlers[topic] = make(map[int]Handler)
+		b.pending[topic] = make(chan struct{})
+	}
+	confirmed := b.pending[topic]
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[topic][id] = handler
+	b.order[topic] = append(b.order[topic], id)
+	b.subMu.Unlock()
+
+	unsubscribe := b.unsubscribeFunc(topic, id)
+
+	// Publishing before Redis has processed SUBSCRIBE would miss this
+	// subscriber, so wait until the reader sees the confirmation
+	if confirmed != nil {
+		select {
+		case <-confirmed:
+		case <-b.closed:
+			return nil, ErrClosed
+		case <-time.After(redisCommandTimeout):
+			unsubscribe()
+			return nil, fmt.Errorf("redis subscribe %s: confirmation timed out", topic)
+		}
+	}
+
+	return unsubscribe, nil
+}
+
+// unsubscribeFunc returns a function removing handler id from topic
+func (b *Redis) unsubscribeFunc(topic string, id int) func() {
+	return func() {
+		b.subMu.Lock()
+		defer b.subMu.Unlock()
+
+		handlers, exists := b.handlers[topic]
+		if !exists {
+			return
+		}
+		if _, exists := handlers[id]; !exists {
+			return
+		}
+		delete(handlers, id)
+		for i, existing := range b.order[topic] {
+			if existing == id {
+				b.order[topic] = append(b.order[topic][:i], b.order[topic][i+1:]...)
+				break
+			}
+		}
+
+		if len(handlers) == 0 {
+			delete(b.handlers, topic)
+			delete(b.order, topic)
+			delete(b.pending, topic)
+			b.restoredUnsafe(topic)
+
+			ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+			defer cancel()
+			if err := b.pubsub.Unsubscribe(ctx, topic); err != nil {
+				slog.Warn("Redis unsubscribe failed", "topic", topic, "error", err)
+			}
+		}
+	}
+}
+
+func (b *Redis) Close() error {
+	select {
+	case <-b.closed:
+		return nil
+	default:
+	}
+	close(b.closed)
+
+	b.pubsub.Close()
+	<-b.done
+	return b.client.Close()
+}
+
+// readLoop dispatches subscription messages until the bus is closed. When
+// the subscriber connection drops, go-redis dials again and resubscribes
+// every topic on the next read; readLoop backs off between attempts.
+func (b *Redis) readLoop() {
+	defer close(b.done)
+
+	for {
+		msg, err := b.pubsub.Receive(context.Background())
+		if err != nil {
+			select {
+			case <-b.closed:
+				return
+			default:
+			}
+			slog.Warn("Redis subscription lost", "error", err)
+			b.lost(err)
+			if !b.reconnect() {
+				return
+			}
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind == "subscribe" {
+				b.confirm(msg.Channel)
+			}
+		case *redis.Message:
+			b.subMu.Lock()
+			b.enqueueUnsafe(msg.Channel, []byte(msg.Payload))
+			b.subMu.Unlock()
+		}
+	}
+}
+
+// lost records that the subscriber connection dropped. Publishing waits
+// until every topic is confirmed again, and topics that were subscribed
+// get a nil payload once they are.
+func (b *Redis) lost(err error) {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	b.subErr = err
+	select {
+	case <-b.subReady:
+		b.subReady = make(chan struct{})
+	default: // Still waiting since an earlier reconnect
+	}
+
+	clear(b.restoring)
+	for topic := range b.handlers {
+		if _, confirming := b.pending[topic]; !confirming {
+			b.restoring[topic] = true
+		}
+	}
+}
+
+// reconnect pings Redis over the subscriber connection with backoff until
+// it is back, which makes go-redis dial and resubscribe if it has not yet.
+// It returns false if the bus was closed first.
+func (b *Redis) reconnect() bool {
+	delay := redisMinRetryDelay
+	for {
+		select {
+		case <-b.closed:
+			return false
+		case <-time.After(delay):
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+		err := b.pubsub.Ping(ctx)
+		cancel()
+		if err == nil {
+			break
+		}
+
+		slog.Warn("Redis reconnect failed", "error", err)
+		delay = min(delay*2, redisMaxRetryDelay)
+	}
+
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	b.subErr = nil
+	if len(b.restoring) == 0 {
+		select {
+		case <-b.subReady:
+		default:
+			close(b.subReady)
+		}
+	}
+	return true
+}
+
+// enqueueUnsafe queues payload for the handlers of topic and starts a
+// delivery goroutine for the topic unless one is running
+func (b *Redis) enqueueUnsafe(topic string, payload []byte) {
+	b.queued[topic] = append(b.queued[topic], payload)
+	if !b.delivering[topic] {
+		b.delivering[topic] = true
+		go b.deliver(topic)
+	}
+}
+
+// deliver calls the handlers of topic with each queued payload in order,
+// until the queue is empty
+func (b *Redis) deliver(topic string) {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	for len(b.queued[topic]) > 0 {
+		payload := b.queued[topic][0]
+		b.queued[topic] = b.queued[topic][1:]
+
+		handlers := make([]Handler, 0, len(b.order[topic]))
+		for _, id := range b.order[topic] {
+			handlers = append(handlers, b.handlers[topic][id])
+		}
+
+		b.subMu.Unlock()
+		for _, handler := range handlers {
+			handler(payload)
+		}
+		b.subMu.Lock()
+	}
+	delete(b.queued, topic)
+	delete(b.delivering, topic)
+}
+
+// confirm releases Subscribe calls waiting for topic. A topic subscribed
+// again after a reconnect instead gets a nil payload, in order with its
+// messages, telling the handlers that they may have missed some.
+func (b *Redis) confirm(topic string) {
+	b.subMu.Lock()
+	defer b.subMu.Unlock()
+
+	if ch, exists := b.pending[topic]; exists {
+		close(ch)
+		delete(b.pending, topic)
+	}
+	if b.restoring[topic] {
+		b.enqueueUnsafe(topic, nil)
+		b.restoredUnsafe(topic)
+	}
+}
+
+// restoredUnsafe marks topic as no longer being resubscribed, and the
+// subscriber connection as ready once no topic is
+func (b *Redis) restoredUnsafe(topic string) {
+	if !b.restoring[topic] {
+		return
+	}
+	delete(b.restoring, topic)
+	if len(b.restoring) == 0 && b.subErr == nil {
+		close(b.subReady)
+	}
+}
@@ -0,0 +1,193 @@
+package bus
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"planning-poker/internal/bus/bustest"
+)
+
+// redisAddr returns REDIS_ADDR when set, so the tests can run against a real
+// local Redis, and otherwise starts an in-memory stand-in
+func redisAddr(t *testing.T) string {
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		return addr
+	}
+	return bustest.NewRedisServer(t).Addr
+}
+
+func newTestRedis(t *testing.T, opts RedisOptions) *Redis {
+	t.Helper()
+
+	b, err := NewRedis(opts)
+	if err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func receive(t *testing.T, ch <-chan string) string {
+	t.Helper()
+
+	select {
+	case payload := <-ch:
+		return payload
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for message")
+		return ""
+	}
+}
+
+func TestRedisPublishSubscribe(t *testing.T) {
+	addr := redisAddr(t)
+	instanceA := newTestRedis(t, RedisOptions{Addr: addr})
+	instanceB := newTestRedis(t, RedisOptions{Addr: addr})
+
+	receivedA := make(chan string, 10)
+	receivedB := make(chan string, 10)
+	if _, err := instanceA.Subscribe("test:pubsub", func(p []byte) { receivedA <- string(p) }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if _, err := instanceB.Subscribe("test:pubsub", func(p []byte) { receivedB <- string(p) }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if err := instanceA.Publish("test:pubsub", []byte("hello\r\nworld")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	instanceB.Publish("test:pubsub", []byte("second"))
+
+	for _, ch := range []chan string{receivedA, receivedB} {
+		if got := receive(t, ch); got != "hello\r\nworld" {
+			t.Errorf("Expected first payload 'hello\\r\\nworld', got %q", got)
+		}
+		if got := receive(t, ch); got != "second" {
+			t.Errorf("Expected second payload 'second', got %q", got)
+		}
+	}
+}
+
+func TestRedisUnsubscribe(t *testing.T) {
+	srv := bustest.NewRedisServer(t)
+	b := newTestRedis(t, RedisOptions{Addr: srv.Addr})
+
+	unsubscribe, err := b.Subscribe("test:unsubscribe", func([]byte) {})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if srv.Subscribers("test:unsubscribe") != 1 {
+		t.Errorf("Expected 1 subscriber, got %d", srv.Subscribers("test:unsubscribe"))
+	}
+	unsubscribe()
+
+	// UNSUBSCRIBE is asynchronous, so allow the server a moment
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if srv.Subscribers("test:unsubscribe") == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected no subscribers after unsubscribe")
+}
+
+func TestRedisAuth(t *testing.T) {
+	srv := bustest.NewRedisServer(t)
+	srv.Password = "secret"
+
+	if _, err := NewRedis(RedisOptions{Addr: srv.Addr, Password: "wrong"}); err == nil {
+		t.Error("Expected wrong password to fail")
+	}
+
+	b := newTestRedis(t, RedisOptions{Addr: srv.Addr, Password: "secret"})
+	if err := b.Publish("test:auth", []byte("x")); err != nil {
+		t.Errorf("Expected publish to succeed after AUTH, got %v", err)
+	}
+}
+
+func TestRedisReconnectsAfterConnectionLoss(t *testing.T) {
+	srv := bustest.NewRedisServer(t)
+	b := newTestRedis(t, RedisOptions{Addr: srv.Addr})
+
+	received := make(chan string, 10)
+	handler := func(p []byte) {
+		if p == nil {
+			p = []byte("<lost>")
+		}
+		received <- string(p)
+	}
+	if _, err := b.Subscribe("test:reconnect", handler); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	srv.DropConnections()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b.subMu.Lock()
+		lost := b.subErr != nil
+		b.subMu.Unlock()
+		if lost {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Subscription loss not noticed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Publishing waits for the subscriber to come back, so the message
+	// is not lost to this instance
+	if err := b.Publish("test:reconnect", []byte("after")); err != nil {
+		t.Fatalf("Publish after reconnect failed: %v", err)
+	}
+	if got := receive(t, received); got != "<lost>" {
+		t.Errorf("Expected a nil payload after resubscribing, got %q", got)
+	}
+	if got := receive(t, received); got != "after" {
+		t.Errorf("Expected 'after', got %q", got)
+	}
+	if srv.Subscribers("test:reconnect") != 1 {
+		t.Errorf("Expected the subscription to be restored, got %d subscribers", srv.Subscribers("test:reconnect"))
+	}
+}
+
+func TestRedisSlowHandlerOnlyDelaysItsTopic(t *testing.T) {
+	b := newTestRedis(t, RedisOptions{Addr: redisAddr(t)})
+
+	release := make(chan struct{})
+	defer close(release)
+	if _, err := b.Subscribe("test:slow", func([]byte) { <-release }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	received := make(chan string, 10)
+	if _, err := b.Subscribe("test:fast", func(p []byte) { received <- string(p) }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	b.Publish("test:slow", []byte("stuck"))
+	b.Publish("test:fast", []byte("through"))
+	if got := receive(t, received); got != "through" {
+		t.Errorf("Expected 'through', got %q", got)
+	}
+}
+
+func TestRedisPing(t *testing.T) {
+	srv := bustest.NewRedisServer(t)
+	b := newTestRedis(t, RedisOptions{Addr: srv.Addr})
+
+	if err := b.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
+	srv.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Ping() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Ping kept succeeding after Redis went away")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,307 @@
+// Package chat posts session links and round results to a Slack or
+// Mattermost channel through an incoming webhook, and parses the /poker
+// slash command both send.
+package chat
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+	maxCommandBody = 64 << 10        // Bytes
+	maxClockSkew   = 5 * time.Minute // Age of a Slack request timestamp still accepted
+)
+
+// Slack request signing headers
+const (
+	headerSignature = "X-Slack-Signature"
+	headerTimestamp = "X-Slack-Request-Timestamp"
+)
+
+// ErrUnauthorized is returned for slash command requests that carry neither
+// a valid Slack signature nor the Mattermost token
+var ErrUnauthorized = errors.New("chat: request is not signed by the configured workspace")
+
+// Config configures a Notifier
+type Config struct {
+	WebhookURL string // Incoming webhook of the channel
+	PublicURL  string // Where the web UI is served, for join links
+
+	// HTTPClient is used for requests if set
+	HTTPClient *http.Client
+}
+
+// Notifier posts the events of every session to one channel, whichever
+// channel a session was created from. Slack and Mattermost accept the same
+// incoming webhook payload.
+type Notifier struct {
+	config Config
+	http   *http.Client
+}
+
+// NewNotifier returns a notifier for the channel in cfg
+func NewNotifier(cfg Config) *Notifier {
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	return &Notifier{config: cfg, http: client}
+}
+
+// Notify posts a message describing event
+func (n *Notifier) Notify(ctx context.Context, event poker.Event) error {
+	text := EventText(n.config.PublicURL, event)
+	if text == "" {
+		return nil
+	}
+	return n.Post(ctx, text)
+}
+
+// Post sends text, in Slack markup, to the channel
+func (n *Notifier) Post(ctx context.Context, text string) error {
+	return postJSON(ctx, n.http, n.config.WebhookURL, map[string]string{"text": text})
+}
+
+// Reply posts resp to the response URL of a slash command, which lets a
+// command send more than the one reply to its request. A nil client uses
+// one with the package's request timeout.
+func Reply(ctx context.Context, client *http.Client, responseURL string, resp Response) error {
+	if u, err := url.Parse(responseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("chat: invalid response URL %q", responseURL)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	return postJSON(ctx, client, responseURL, resp)
+}
+
+// postJSON posts payload to an incoming webhook or response URL
+func postJSON(ctx context.Context, client *http.Client, u string, payload any) error {
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("chat: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	// Slack explains failures in a short plain-text body
+	reason, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("chat: %s: %s", resp.Status, strings.TrimSpace(string(reason)))
+}
+
+// EventText formats an event for a channel, or returns "" for events that
+// are not posted
+func EventText(publicURL string, event poker.Event) string {
+	switch event.Type {
+	case poker.EventSessionStarted:
+		return fmt.Sprintf("Planning poker session *%s* has started: %s",
+			escape(event.SessionID), link(JoinURL(publicURL, event.SessionID), "join the session"))
+
+	case poker.EventVotesRevealed:
+		var votes []string
+		for _, v := range event.Votes {
+			vote := "no vote"
+			if v.Vote != nil {
+				vote = *v.Vote
+			}
+			votes = append(votes, fmt.Sprintf("%s: %s", escape(v.Name), escape(vote)))
+		}
+		if len(votes) == 0 {
+			votes = append(votes, "no participants")
+		}
+		return fmt.Sprintf("Votes revealed for %s in *%s*\n%s",
+			storyText(event.Story), escape(event.SessionID), strings.Join(votes, ", "))
+
+	case poker.EventEstimateSet:
+		if event.Story == nil {
+			return ""
+		}
+		return fmt.Sprintf("%s estimated at *%s* in *%s*",
+			storyText(event.Story), escape(event.Story.Estimate), escape(event.SessionID))
+
+	case poker.EventSessionEnded:
+		if len(event.Estimated) == 0 {
+			return fmt.Sprintf("Planning poker session *%s* ended without estimates", escape(event.SessionID))
+		}
+		lines := []string{fmt.Sprintf("Planning poker session *%s* ended with %d estimated:", escape(event.SessionID), len(event.Estimated))}
+		for _, story := range event.Estimated {
+			lines = append(lines, fmt.Sprintf("• %s: *%s*", storyText(&story), escape(story.Estimate)))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return ""
+}
+
+// SessionCreatedText announces a session created with the slash command
+// to the channel. It only has the join link, so that nobody else in the
+// channel can take over as moderator.
+func SessionCreatedText(publicURL, sessionID, story, user string) string {
+	return fmt.Sprintf("%s started planning poker for *%s*\n%s",
+		escape(user), escape(story),
+		link(JoinURL(publicURL, sessionID), "Join session "+sessionID))
+}
+
+// ModeratorText gives the user who ran the slash command the moderator
+// link of the session it created
+func ModeratorText(publicURL, sessionID, story string) string {
+	return fmt.Sprintf("Planning poker for *%s* is ready: %s to start it. Only you can see this link; others use %s.",
+		escape(story),
+		link(HostURL(publicURL, sessionID), "open session "+sessionID+" as moderator"),
+		link(JoinURL(publicURL, sessionID), "the join link"))
+}
+
+// JoinURL links to the web UI with the session filled in
+func JoinURL(publicURL, sessionID string) string {
+	return strings.TrimRight(publicURL, "/") + "/?session=" + url.QueryEscape(sessionID)
+}
+
+// HostURL links to the web UI as the session's creator, who starts the
+// session and moderates it
+func HostURL(publicURL, sessionID string) string {
+	return JoinURL(publicURL, sessionID) + "&creator=true"
+}
+
+// storyText names a story, linking it to its issue when it has one
+func storyText(story *poker.Story) string {
+	if story == nil || story.Title == "" {
+		return "the current round"
+	}
+	title := "*" + escape(story.Title) + "*"
+	if story.Key != "" {
+		title = escape(story.Key) + " " + title
+	}
+	if story.URL != "" {
+		return link(story.URL, strings.TrimSpace(story.Key+" "+story.Title))
+	}
+	return title
+}
+
+func link(u, text string) string {
+	return "<" + u + "|" + escape(text) + ">"
+}
+
+// escape encodes the characters Slack markup reserves
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// Command is a slash command invocation
+type Command struct {
+	Command     string // Such as /poker
+	Text        string // Everything after the command
+	UserName    string
+	ChannelID   string
+	ResponseURL string // Accepts further replies to the command
+}
+
+// Verifier authenticates slash command requests. Slack signs requests
+// with the app's signing secret; Mattermost sends a fixed token instead.
+type Verifier struct {
+	SigningSecret string // Slack
+	Token         string // Mattermost
+
+	// Now returns the current time; time.Now if nil
+	Now func() time.Time
+}
+
+// ParseCommand reads and authenticates a slash command request, which
+// Slack and Mattermost both send as a form
+func ParseCommand(r *http.Request, v Verifier) (Command, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCommandBody))
+	if err != nil {
+		return Command{}, err
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return Command{}, fmt.Errorf("chat: invalid form: %w", err)
+	}
+
+	switch {
+	case v.SigningSecret != "" && r.Header.Get(headerSignature) != "":
+		if !v.validSignature(r.Header.Get(headerTimestamp), body, r.Header.Get(headerSignature)) {
+			return Command{}, ErrUnauthorized
+		}
+	case v.Token != "" && form.Get("token") != "":
+		if subtle.ConstantTimeCompare([]byte(form.Get("token")), []byte(v.Token)) != 1 {
+			return Command{}, ErrUnauthorized
+		}
+	default:
+		return Command{}, ErrUnauthorized
+	}
+
+	return Command{
+		Command:     form.Get("command"),
+		Text:        strings.TrimSpace(form.Get("text")),
+		UserName:    form.Get("user_name"),
+		ChannelID:   form.Get("channel_id"),
+		ResponseURL: form.Get("response_url"),
+	}, nil
+}
+
+// validSignature checks a Slack v0 signature, rejecting stale timestamps so
+// that captured requests cannot be replayed
+func (v Verifier) validSignature(timestamp string, body []byte, signature string) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	if age := now().Sub(time.Unix(seconds, 0)); age > maxClockSkew || age < -maxClockSkew {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(v.SigningSecret, timestamp, body)), []byte(signature))
+}
+
+// Sign returns the Slack v0 signature of a request body sent at timestamp
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Response is the reply to a slash command
+type Response struct {
+	ResponseType string `json:"response_type"` // in_channel or ephemeral
+	Text         string `json:"text"`
+}
+
+// InChannel returns a reply everyone in the channel sees
+func InChannel(text string) Response {
+	return Response{ResponseType: "in_channel", Text: text}
+}
+
+// Ephemeral returns a reply only the user who ran the command sees
+func Ephemeral(text string) Response {
+	return Response{ResponseType: "ephemeral", Text: text}
+}
@@ -0,0 +1,170 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// fixtureRequest builds a slash command request from a form body captured
+// from the chat service
+func fixtureRequest(t *testing.T, name string) (*http.Request, []byte) {
+	t.Helper()
+
+	body, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	r := httptest.NewRequest("POST", "/api/chat/command", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r, body
+}
+
+func TestParseSlackCommand(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	verifier := Verifier{SigningSecret: "8f742231b10e8888abcd99yyyzzz85a5", Now: func() time.Time { return now }}
+
+	r, body := fixtureRequest(t, "slack_command.txt")
+	r.Header.Set(headerTimestamp, timestamp)
+	r.Header.Set(headerSignature, Sign(verifier.SigningSecret, timestamp, body))
+
+	cmd, err := ParseCommand(r, verifier)
+	if err != nil {
+		t.Fatalf("ParseCommand failed: %v", err)
+	}
+	want := Command{Command: "/poker", Text: "PP-12 Login page & SSO", UserName: "alice", ChannelID: "C2147483705",
+		ResponseURL: "https://hooks.slack.com/commands/1234/5678"}
+	if cmd != want {
+		t.Errorf("Expected %+v, got %+v", want, cmd)
+	}
+
+	tests := map[string]func(r *http.Request){
+		"wrong secret": func(r *http.Request) { r.Header.Set(headerSignature, Sign("other", timestamp, body)) },
+		"stale": func(r *http.Request) {
+			r.Header.Set(headerTimestamp, strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10))
+		},
+		"unsigned": func(r *http.Request) { r.Header.Del(headerSignature) },
+	}
+	for name, tamper := range tests {
+		r, _ := fixtureRequest(t, "slack_command.txt")
+		r.Header.Set(headerTimestamp, timestamp)
+		r.Header.Set(headerSignature, Sign(verifier.SigningSecret, timestamp, body))
+		tamper(r)
+		if _, err := ParseCommand(r, verifier); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("%s: expected %v, got %v", name, ErrUnauthorized, err)
+		}
+	}
+}
+
+func TestParseMattermostCommand(t *testing.T) {
+	r, _ := fixtureRequest(t, "mattermost_command.txt")
+	cmd, err := ParseCommand(r, Verifier{Token: "xr3j5x3p4pfq7ns3ntxmbuw3ky"})
+	if err != nil {
+		t.Fatalf("ParseCommand failed: %v", err)
+	}
+	if cmd.Text != "Logout button" || cmd.UserName != "bob" {
+		t.Errorf("Unexpected command %+v", cmd)
+	}
+
+	r, _ = fixtureRequest(t, "mattermost_command.txt")
+	if _, err := ParseCommand(r, Verifier{Token: "another-token"}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected a wrong token to be rejected, got %v", err)
+	}
+}
+
+func TestEventText(t *testing.T) {
+	five, eight := "5", "8"
+	story := &poker.Story{Key: "PP-1", Title: "Login <page>", Estimate: "5"}
+	tests := []struct {
+		event poker.Event
+		want  string
+	}{
+		{
+			poker.Event{Type: poker.EventSessionStarted, SessionID: "ABC123"},
+			"Planning poker session *ABC123* has started: <https://poker.example.com/?session=ABC123|join the session>",
+		},
+		{
+			poker.Event{Type: poker.EventVotesRevealed, SessionID: "ABC123", Story: story, Votes: []poker.EventVote{
+				{Name: "Alice", Vote: &five}, {Name: "Bob", Vote: &eight}, {Name: "Carol"},
+			}},
+			"Votes revealed for PP-1 *Login &lt;page&gt;* in *ABC123*\nAlice: 5, Bob: 8, Carol: no vote",
+		},
+		{
+			poker.Event{Type: poker.EventEstimateSet, SessionID: "ABC123", Story: &poker.Story{Key: "PP-1", Title: "Login", URL: "https://jira.example.com/browse/PP-1", Estimate: "5"}},
+			"<https://jira.example.com/browse/PP-1|PP-1 Login> estimated at *5* in *ABC123*",
+		},
+		{
+			poker.Event{Type: poker.EventSessionEnded, SessionID: "ABC123", Estimated: []poker.Story{{Title: "Login", Estimate: "5"}, {Title: "Logout", Estimate: "2"}}},
+			"Planning poker session *ABC123* ended with 2 estimated:\n• *Login*: *5*\n• *Logout*: *2*",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := EventText("https://poker.example.com/", tt.event); got != tt.want {
+			t.Errorf("%s:\nexpected %q\n     got %q", tt.event.Type, tt.want, got)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	received := make(chan map[string]string, 1)
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+		w.Write([]byte("ok"))
+	}))
+	defer channel.Close()
+
+	n := NewNotifier(Config{WebhookURL: channel.URL, PublicURL: "https://poker.example.com"})
+	if err := n.Notify(context.Background(), poker.Event{Type: poker.EventSessionEnded, SessionID: "ABC123"}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if payload := <-received; payload["text"] != "Planning poker session *ABC123* ended without estimates" {
+		t.Errorf("Unexpected payload %v", payload)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no_service", http.StatusNotFound)
+	}))
+	defer failing.Close()
+	n = NewNotifier(Config{WebhookURL: failing.URL})
+	if err := n.Post(context.Background(), "hi"); err == nil || !strings.Contains(err.Error(), "no_service") {
+		t.Errorf("Expected the service's reason in the error, got %v", err)
+	}
+}
+
+func TestReply(t *testing.T) {
+	received := make(chan Response, 1)
+	responseURL := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp Response
+		json.NewDecoder(r.Body).Decode(&resp)
+		received <- resp
+	}))
+	defer responseURL.Close()
+
+	text := SessionCreatedText("https://poker.example.com", "ABC123", "Login", "bob")
+	if err := Reply(context.Background(), nil, responseURL.URL, InChannel(text)); err != nil {
+		t.Fatalf("Reply failed: %v", err)
+	}
+	if resp := <-received; resp.ResponseType != "in_channel" || resp.Text != text {
+		t.Errorf("Unexpected reply %+v", resp)
+	}
+	if strings.Contains(text, "creator=true") {
+		t.Errorf("Expected the channel announcement to leave out the moderator link, got %q", text)
+	}
+
+	if err := Reply(context.Background(), nil, "file:///etc/passwd", InChannel(text)); err == nil {
+		t.Error("Expected a non-HTTP response URL to be rejected")
+	}
+}
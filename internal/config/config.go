@@ -1,7 +1,16 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/netip"
+	"net/url"
 	"os"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +25,16 @@ type Config struct {
 	WriteTimeout    time.Duration `json:"writeTimeout"`
 	IdleTimeout     time.Duration `json:"idleTimeout"`
 	ShutdownTimeout time.Duration `json:"shutdownTimeout"`
+	DrainDelay      time.Duration `json:"drainDelay"` // Time between failing readiness and disconnecting clients
+
+	// TLS configuration: certificate files or ACME, not both
+	TLSCertFile      string   `json:"tlsCertFile"`
+	TLSKeyFile       string   `json:"tlsKeyFile"`
+	ACMEDomains      []string `json:"acmeDomains"`
+	ACMEEmail        string   `json:"acmeEmail"`
+	ACMECacheDir     string   `json:"acmeCacheDir"`
+	ACMEDirectoryURL string   `json:"acmeDirectoryUrl"`
+	HTTPRedirectPort string   `json:"httpRedirectPort"` // Plain HTTP port redirecting to HTTPS
 
 	// WebSocket configuration
 	AllowedOrigins []string `json:"allowedOrigins"`
@@ -24,104 +43,538 @@ type Config struct {
 	// Session configuration
 	SessionTimeout     time.Duration `json:"sessionTimeout"`
 	MaxSessionsPerUser int           `json:"maxSessionsPerUser"`
+	MessageRateLimit   int           `json:"messageRateLimit"` // Messages per second per participant, 0 for no limit
+
+	// Event bus configuration (shares sessions between instances)
+	BusBackend    string `json:"busBackend"`
+	RedisAddr     string `json:"redisAddr"`
+	RedisPassword string `json:"-"`
 
 	// Logging configuration
 	LogLevel  string `json:"logLevel"`
 	LogFormat string `json:"logFormat"`
 
+	// Metrics configuration
+	MetricsEnabled bool `json:"metricsEnabled"`
+
+	// Admin listener (metrics, expvar, pprof, detailed health)
+	AdminHost string `json:"adminHost"`
+	AdminPort string `json:"adminPort"`
+
+	// Jira integration (story import and estimate write-back)
+	JiraURL              string   `json:"jiraUrl"`
+	JiraEmail            string   `json:"jiraEmail"` // Empty for a Data Center personal access token
+	JiraAPIToken         string   `json:"-"`
+	JiraStoryPointsField string   `json:"jiraStoryPointsField"`
+	JiraProjects         []string `json:"jiraProjects"` // Keys of the projects stories may come from
+
+	// GitHub integration (issue import and estimate write-back)
+	GitHubToken          string   `json:"-"`
+	GitHubAPIURL         string   `json:"githubApiUrl"`
+	GitHubEstimateTarget string   `json:"githubEstimateTarget"` // label or project
+	GitHubLabelPrefix    string   `json:"githubLabelPrefix"`
+	GitHubProjectNumber  int      `json:"githubProjectNumber"`
+	GitHubProjectField   string   `json:"githubProjectField"`
+	GitHubRepos          []string `json:"githubRepos"` // owner/repo names issues may come from
+
+	// Chat integration (Slack or Mattermost)
+	PublicURL         string `json:"publicUrl"` // Where users reach the web UI, for links
+	ChatWebhookURL    string `json:"-"`         // Incoming webhook that notifications are posted to
+	ChatSigningSecret string `json:"-"`         // Slack app signing secret for /poker
+	ChatCommandToken  string `json:"-"`         // Mattermost slash command token for /poker
+
+	// Global webhooks, which receive the events of every session
+	WebhookURLs        []string `json:"-"` // May embed credentials
+	WebhookSecret      string   `json:"-"`
+	WebhookEvents      []string `json:"webhookEvents"` // Empty for every event
+	WebhookMaxAttempts int      `json:"webhookMaxAttempts"`
+
+	// Private networks session webhooks may reach, in CIDR notation
+	WebhookAllowedNetworks []string `json:"webhookAllowedNetworks"`
+
 	// Development settings
-	IsDevelopment bool `json:"isDevelopment"`
-	EnablePprof   bool `json:"enablePprof"`
-}
-
-// Load loads configuration from environment variables with defaults
-func Load() *Config {
-	config := &Config{
-		// Default values
-		Port:               getEnv("PORT", "8080"),
-		Host:               getEnv("HOST", ""),
-		ReadTimeout:        getDurationEnv("READ_TIMEOUT", 15*time.Second),
-		WriteTimeout:       getDurationEnv("WRITE_TIMEOUT", 15*time.Second),
-		IdleTimeout:        getDurationEnv("IDLE_TIMEOUT", 60*time.Second),
-		ShutdownTimeout:    getDurationEnv("SHUTDOWN_TIMEOUT", 10*time.Second),
-		AllowedOrigins:     getStringSliceEnv("ALLOWED_ORIGINS", []string{"*"}),
-		MaxMessageSize:     getInt64Env("MAX_MESSAGE_SIZE", 1024),
-		SessionTimeout:     getDurationEnv("SESSION_TIMEOUT", 24*time.Hour),
-		MaxSessionsPerUser: getIntEnv("MAX_SESSIONS_PER_USER", 10),
-		LogLevel:           getEnv("LOG_LEVEL", "info"),
-		LogFormat:          getEnv("LOG_FORMAT", "text"),
-		IsDevelopment:      getBoolEnv("DEVELOPMENT", false),
-		EnablePprof:        getBoolEnv("ENABLE_PPROF", false),
-	}
-
-	// In development mode, be more permissive
+	WebDir        string `json:"webDir"` // Serve the web UI from here instead of the embedded copy
+	IsDevelopment bool   `json:"isDevelopment"`
+	EnablePprof   bool   `json:"enablePprof"`
+}
+
+// Load builds the configuration from, lowest precedence first: built-in
+// defaults, the config file (-config or CONFIG_FILE), the .env file
+// (-env-file, default .env), environment variables and command-line flags.
+// Invalid values are not replaced by defaults: every parse and validation
+// error is collected into the returned error.
+func Load(args []string) (*Config, error) {
+	flags, err := parseFlags(args)
+	if err != nil {
+		return nil, err
+	}
+
+	config := defaults()
+	var errs []error
+
+	configFile := flags.configFile
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG_FILE")
+	}
+	if configFile != "" {
+		settings, err := readConfigFile(configFile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		errs = append(errs, config.applyFile(settings, configFile)...)
+	}
+
+	envFile := flags.envFile
+	if envFile == "" {
+		envFile = ".env"
+	}
+	settings, err := readDotEnv(envFile)
+	if err != nil && (flags.envFile != "" || !errors.Is(err, fs.ErrNotExist)) {
+		errs = append(errs, err)
+	}
+	errs = append(errs, config.apply(settings, envFile)...)
+
+	errs = append(errs, config.apply(environment(), "environment")...)
+	errs = append(errs, config.apply(flags.settings, "flag")...)
+
+	// In development mode, be more permissive. ALLOWED_ORIGINS already
+	// defaults to every origin and is applied as configured.
 	if config.IsDevelopment {
-		config.AllowedOrigins = []string{"*"}
 		config.LogLevel = "debug"
 		config.EnablePprof = true
 	}
 
-	return config
+	if err := config.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
-// Address returns the server address (host:port)
-func (c *Config) Address() string {
-	return c.Host + ":" + c.Port
+// defaults returns the built-in configuration
+func defaults() *Config {
+	return &Config{
+		Port:                 "8080",
+		Host:                 "",
+		ReadTimeout:          15 * time.Second,
+		WriteTimeout:         15 * time.Second,
+		IdleTimeout:          60 * time.Second,
+		ShutdownTimeout:      10 * time.Second,
+		DrainDelay:           5 * time.Second,
+		ACMECacheDir:         "certs",
+		AllowedOrigins:       []string{"*"},
+		MaxMessageSize:       1024,
+		SessionTimeout:       24 * time.Hour,
+		MaxSessionsPerUser:   10,
+		MessageRateLimit:     20,
+		BusBackend:           "local",
+		RedisAddr:            "localhost:6379",
+		RedisPassword:        "",
+		LogLevel:             "info",
+		LogFormat:            "text",
+		MetricsEnabled:       true,
+		AdminHost:            "127.0.0.1",
+		AdminPort:            "9090",
+		JiraStoryPointsField: "customfield_10016",
+		GitHubAPIURL:         "https://api.github.com",
+		GitHubEstimateTarget: "label",
+		GitHubLabelPrefix:    "estimate: ",
+		GitHubProjectField:   "Estimate",
+		WebhookMaxAttempts:   5,
+		IsDevelopment:        false,
+		EnablePprof:          false,
+	}
 }
 
-// IsProductionMode returns true if not in development mode
-func (c *Config) IsProductionMode() bool {
-	return !c.IsDevelopment
+// apply sets each setting, keyed by environment variable name, reporting
+// values that do not parse along with where they came from
+func (c *Config) apply(settings map[string]string, source string) []error {
+	var errs []error
+	for _, f := range fields {
+		value, exists := settings[f.env]
+		if !exists {
+			continue
+		}
+		if err := f.set(c, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", f.env, source, err))
+		}
+	}
+	return errs
 }
 
-// Helper functions for environment variable parsing
+// applyFile sets each setting read from a config file. Lists are set item
+// by item rather than split on commas, so items may contain commas.
+func (c *Config) applyFile(settings map[string]any, source string) []error {
+	var errs []error
+	for _, f := range fields {
+		var err error
+		switch value := settings[f.env].(type) {
+		case nil:
+			continue
+		case string:
+			err = f.set(c, value)
+		case []string:
+			if f.setList == nil {
+				err = errors.New("expected a single value, got a list")
+			} else {
+				f.setList(c, value)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", f.env, source, err))
+		}
+	}
+	return errs
+}
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// environment returns the non-empty environment variables naming a setting
+func environment() map[string]string {
+	settings := make(map[string]string)
+	for _, f := range fields {
+		if value := os.Getenv(f.env); value != "" {
+			settings[f.env] = value
+		}
 	}
-	return defaultValue
+	return settings
 }
 
-func getIntEnv(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+// Validate reports every setting that is out of range or inconsistent
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	ports := []struct {
+		key, value string
+	}{{"PORT", c.Port}, {"ADMIN_PORT", c.AdminPort}}
+	for _, port := range ports {
+		if n, err := strconv.Atoi(port.value); err != nil || n < 1 || n > 65535 {
+			invalid(port.key, "must be a port number between 1 and 65535, got %q", port.value)
+		}
+	}
+	if c.AdminPort == c.Port && (c.AdminHost == c.Host || wildcardHost(c.Host) || wildcardHost(c.AdminHost)) {
+		invalid("ADMIN_PORT", "admin listener must not use the server address %s", c.Address())
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		invalid("TLS_CERT_FILE", "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	for _, file := range []struct{ key, path string }{{"TLS_CERT_FILE", c.TLSCertFile}, {"TLS_KEY_FILE", c.TLSKeyFile}} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			invalid(file.key, "%v", err)
+		}
+	}
+	if c.TLSCertFile != "" && len(c.ACMEDomains) > 0 {
+		invalid("ACME_DOMAINS", "cannot be used with TLS_CERT_FILE; choose certificate files or ACME")
+	}
+	if len(c.ACMEDomains) > 0 && c.ACMECacheDir == "" {
+		invalid("ACME_CACHE_DIR", "is required when ACME_DOMAINS is set, so certificates survive restarts")
+	}
+	if c.HTTPRedirectPort != "" {
+		if !c.TLSEnabled() {
+			invalid("HTTP_REDIRECT_PORT", "requires TLS_CERT_FILE or ACME_DOMAINS")
+		}
+		if n, err := strconv.Atoi(c.HTTPRedirectPort); err != nil || n < 1 || n > 65535 {
+			invalid("HTTP_REDIRECT_PORT", "must be a port number between 1 and 65535, got %q", c.HTTPRedirectPort)
+		}
+		if c.HTTPRedirectPort == c.Port {
+			invalid("HTTP_REDIRECT_PORT", "must differ from PORT")
+		}
+	}
+
+	timeouts := []struct {
+		key   string
+		value time.Duration
+	}{{"READ_TIMEOUT", c.ReadTimeout}, {"WRITE_TIMEOUT", c.WriteTimeout}, {"IDLE_TIMEOUT", c.IdleTimeout}, {"DRAIN_DELAY", c.DrainDelay}}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			invalid(timeout.key, "must not be negative, got %v", timeout.value)
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		invalid("SHUTDOWN_TIMEOUT", "must be positive, got %v", c.ShutdownTimeout)
+	} else if c.DrainDelay >= c.ShutdownTimeout {
+		invalid("DRAIN_DELAY", "must be shorter than SHUTDOWN_TIMEOUT (%v), got %v", c.ShutdownTimeout, c.DrainDelay)
+	}
+	if c.SessionTimeout <= 0 {
+		invalid("SESSION_TIMEOUT", "must be positive, got %v", c.SessionTimeout)
+	}
+
+	if len(c.AllowedOrigins) == 0 {
+		invalid("ALLOWED_ORIGINS", "must list at least one origin or *")
+	}
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			invalid("ALLOWED_ORIGINS", "%q is not an origin of the form scheme://host[:port]", origin)
+			continue
+		}
+		// A wildcard stands for the subdomains of a registered domain
+		if strings.Contains(u.Hostname(), "*") {
+			domain, wildcard := strings.CutPrefix(u.Hostname(), "*.")
+			if !wildcard || strings.Contains(domain, "*") || !strings.Contains(domain, ".") {
+				invalid("ALLOWED_ORIGINS", "%q may only use a wildcard as in https://*.example.com", origin)
+			}
+		}
+	}
+	if c.MaxMessageSize <= 0 {
+		invalid("MAX_MESSAGE_SIZE", "must be positive, got %d", c.MaxMessageSize)
+	}
+	if c.MaxSessionsPerUser <= 0 {
+		invalid("MAX_SESSIONS_PER_USER", "must be positive, got %d", c.MaxSessionsPerUser)
+	}
+	if c.MessageRateLimit < 0 {
+		invalid("MESSAGE_RATE_LIMIT", "must not be negative, got %d", c.MessageRateLimit)
+	}
+
+	if c.WebDir != "" {
+		if info, err := os.Stat(c.WebDir); err != nil {
+			invalid("WEB_DIR", "%v", err)
+		} else if !info.IsDir() {
+			invalid("WEB_DIR", "%s is not a directory", c.WebDir)
+		}
+	}
+
+	if c.JiraURL != "" {
+		if u, err := url.Parse(c.JiraURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("JIRA_URL", "must be an http or https URL, got %q", c.JiraURL)
+		}
+		if c.JiraAPIToken == "" {
+			invalid("JIRA_API_TOKEN", "is required when JIRA_URL is set")
+		}
+		if c.JiraStoryPointsField == "" {
+			invalid("JIRA_STORY_POINTS_FIELD", "is required when JIRA_URL is set")
+		}
+		if len(c.JiraProjects) == 0 {
+			invalid("JIRA_PROJECTS", "is required when JIRA_URL is set")
+		}
+		for _, project := range c.JiraProjects {
+			if !validJiraProject(project) {
+				invalid("JIRA_PROJECTS", "%q is not a project key such as PP", project)
+			}
+		}
+	}
+
+	if c.GitHubToken != "" {
+		if len(c.GitHubRepos) == 0 {
+			invalid("GITHUB_REPOS", "is required when GITHUB_TOKEN is set")
+		}
+		for _, repo := range c.GitHubRepos {
+			if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+				invalid("GITHUB_REPOS", "%q is not a repository such as acme/web", repo)
+			}
+		}
+		if u, err := url.Parse(c.GitHubAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("GITHUB_API_URL", "must be an http or https URL, got %q", c.GitHubAPIURL)
+		}
+		switch c.GitHubEstimateTarget {
+		case "label":
+			if c.GitHubLabelPrefix == "" {
+				invalid("GITHUB_LABEL_PREFIX", "is required when GITHUB_ESTIMATE_TARGET is label")
+			}
+		case "project":
+			if c.GitHubProjectNumber <= 0 {
+				invalid("GITHUB_PROJECT_NUMBER", "must be positive when GITHUB_ESTIMATE_TARGET is project, got %d", c.GitHubProjectNumber)
+			}
+			if c.GitHubProjectField == "" {
+				invalid("GITHUB_PROJECT_FIELD", "is required when GITHUB_ESTIMATE_TARGET is project")
+			}
+		default:
+			invalid("GITHUB_ESTIMATE_TARGET", "must be label or project, got %q", c.GitHubEstimateTarget)
+		}
+	}
+
+	if c.ChatWebhookURL != "" {
+		if u, err := url.Parse(c.ChatWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("CHAT_WEBHOOK_URL", "must be an http or https URL")
+		}
+	}
+	if c.ChatWebhookURL != "" || c.ChatCommandEnabled() {
+		if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("PUBLIC_URL", "must be the http or https URL of the web UI when chat is enabled, got %q", c.PublicURL)
+		}
+	}
+
+	for _, u := range c.WebhookURLs {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			invalid("WEBHOOK_URLS", "%q is not an http or https URL", u)
+		}
+	}
+	for _, event := range c.WebhookEvents {
+		if !slices.Contains(webhookEvents, event) {
+			invalid("WEBHOOK_EVENTS", "%q is not one of %s", event, strings.Join(webhookEvents, ", "))
 		}
 	}
-	return defaultValue
+	if c.WebhookMaxAttempts <= 0 {
+		invalid("WEBHOOK_MAX_ATTEMPTS", "must be positive, got %d", c.WebhookMaxAttempts)
+	}
+	for _, network := range c.WebhookAllowedNetworks {
+		if _, err := netip.ParsePrefix(network); err != nil {
+			invalid("WEBHOOK_ALLOWED_NETWORKS", "%q is not a network such as 10.0.0.0/8", network)
+		}
+	}
+
+	switch c.BusBackend {
+	case "local":
+	case "redis":
+		if c.RedisAddr == "" {
+			invalid("REDIS_ADDR", "is required when BUS_BACKEND is redis")
+		}
+	default:
+		invalid("BUS_BACKEND", "must be local or redis, got %q", c.BusBackend)
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		invalid("LOG_LEVEL", "must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	switch strings.ToLower(c.LogFormat) {
+	case "text", "json":
+	default:
+		invalid("LOG_FORMAT", "must be text or json, got %q", c.LogFormat)
+	}
+
+	return errors.Join(errs...)
 }
 
-func getInt64Env(key string, defaultValue int64) int64 {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
-			return intValue
+// reloadable names the settings Reload applies to a running server. The
+// others are read once at startup, by the listeners, the bus or the log
+// handler, and need a restart.
+var reloadable = map[string]bool{
+	"ALLOWED_ORIGINS":    true,
+	"SESSION_TIMEOUT":    true,
+	"MESSAGE_RATE_LIMIT": true,
+	"LOG_LEVEL":          true,
+}
+
+// Reload returns a copy of c with the reloadable settings taken from next.
+// It also returns the settings that changed and those that differ in next
+// but only take effect after a restart.
+func (c *Config) Reload(next *Config) (reloaded *Config, changed, restart []string) {
+	updated := *c
+	for _, f := range fields {
+		current := reflect.ValueOf(f.value(&updated)).Elem()
+		incoming := reflect.ValueOf(f.value(next)).Elem()
+		if reflect.DeepEqual(current.Interface(), incoming.Interface()) {
+			continue
+		}
+		if !reloadable[f.env] {
+			restart = append(restart, f.env)
+			continue
 		}
+		current.Set(incoming)
+		changed = append(changed, f.env)
 	}
-	return defaultValue
+	return &updated, changed, restart
+}
+
+// Address returns the server address (host:port)
+func (c *Config) Address() string {
+	return c.Host + ":" + c.Port
+}
+
+// wildcardHost reports whether a listener on host accepts connections on
+// every interface, and so conflicts with any other host on the same port
+func wildcardHost(host string) bool {
+	switch host {
+	case "", "0.0.0.0", "::", "[::]":
+		return true
+	}
+	return false
+}
+
+// AdminAddress returns the admin listener address (host:port)
+func (c *Config) AdminAddress() string {
+	return c.AdminHost + ":" + c.AdminPort
+}
+
+// TLSEnabled returns true if the public listener serves HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || len(c.ACMEDomains) > 0
 }
 
-func getBoolEnv(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
+// JiraEnabled returns true if stories can be imported from Jira
+func (c *Config) JiraEnabled() bool {
+	return c.JiraURL != ""
+}
+
+// ChatCommandEnabled returns true if the /poker slash command is served
+func (c *Config) ChatCommandEnabled() bool {
+	return c.ChatSigningSecret != "" || c.ChatCommandToken != ""
+}
+
+// validJiraProject reports whether key is a Jira project key: an upper-case
+// letter followed by upper-case letters, digits or underscores
+func validJiraProject(key string) bool {
+	if key == "" || key[0] < 'A' || key[0] > 'Z' {
+		return false
+	}
+	for _, r := range key {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' {
+			return false
 		}
 	}
-	return defaultValue
+	return true
 }
 
-func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
+// webhookEvents are the session events webhooks can subscribe to
+var webhookEvents = []string{"session_started", "votes_revealed", "estimate_set", "session_ended"}
+
+// WebhookNetworks returns the networks in WebhookAllowedNetworks. Invalid
+// entries are skipped; Validate reports them.
+func (c *Config) WebhookNetworks() []netip.Prefix {
+	var networks []netip.Prefix
+	for _, network := range c.WebhookAllowedNetworks {
+		if prefix, err := netip.ParsePrefix(network); err == nil {
+			networks = append(networks, prefix.Masked())
 		}
 	}
-	return defaultValue
+	return networks
+}
+
+// GitHubEnabled returns true if issues can be imported from GitHub
+func (c *Config) GitHubEnabled() bool {
+	return c.GitHubToken != ""
+}
+
+// IsProductionMode returns true if not in development mode
+func (c *Config) IsProductionMode() bool {
+	return !c.IsDevelopment
+}
+
+// Level returns the slog level named by LogLevel, defaulting to info
+func (c *Config) Level() slog.Level {
+	switch strings.ToLower(c.LogLevel) {
+	case "debug":
+		return slog.LevelDebug
+	case "info", "":
+		return slog.LevelInfo
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		slog.Warn("Unknown log level, using info", "level", c.LogLevel)
+		return slog.LevelInfo
+	}
 }
 
-func getStringSliceEnv(key string, defaultValue []string) []string {
-	if value := os.Getenv(key); value != "" {
-		return strings.Split(value, ",")
+// NewLogger returns a logger writing to w in LogFormat ("json" or "text")
+// and filtering by level
+func (c *Config) NewLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if strings.ToLower(c.LogFormat) == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
 	}
-	return defaultValue
+	return slog.New(slog.NewTextHandler(w, opts))
 }
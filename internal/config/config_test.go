@@ -1,16 +1,31 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
 
+func mustLoad(t *testing.T, args ...string) *Config {
+	t.Helper()
+
+	config, err := Load(args)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return config
+}
+
 func TestLoad_Defaults(t *testing.T) {
 	// Clear environment variables
 	os.Clearenv()
 
-	config := Load()
+	config := mustLoad(t)
 
 	// Test default values
 	if config.Port != "8080" {
@@ -52,7 +67,7 @@ func TestLoad_EnvironmentOverrides(t *testing.T) {
 		os.Clearenv()
 	}()
 
-	config := Load()
+	config := mustLoad(t)
 
 	if config.Port != "9000" {
 		t.Errorf("Expected port '9000', got %s", config.Port)
@@ -88,15 +103,15 @@ func TestLoad_DevelopmentMode(t *testing.T) {
 	os.Setenv("DEVELOPMENT", "true")
 	defer os.Clearenv()
 
-	config := Load()
+	config := mustLoad(t)
 
 	if !config.IsDevelopment {
 		t.Error("Expected development mode to be true")
 	}
 
-	// In development mode, allowed origins should be permissive
+	// Without ALLOWED_ORIGINS, allowed origins should be permissive
 	if len(config.AllowedOrigins) != 1 || config.AllowedOrigins[0] != "*" {
-		t.Errorf("Expected development mode to set allowed origins to ['*'], got %v", config.AllowedOrigins)
+		t.Errorf("Expected development mode to allow every origin by default, got %v", config.AllowedOrigins)
 	}
 
 	if config.LogLevel != "debug" {
@@ -108,6 +123,18 @@ func TestLoad_DevelopmentMode(t *testing.T) {
 	}
 }
 
+func TestLoad_DevelopmentModeKeepsAllowedOrigins(t *testing.T) {
+	os.Setenv("DEVELOPMENT", "true")
+	os.Setenv("ALLOWED_ORIGINS", "http://localhost:3000")
+	defer os.Clearenv()
+
+	config := mustLoad(t)
+
+	if len(config.AllowedOrigins) != 1 || config.AllowedOrigins[0] != "http://localhost:3000" {
+		t.Errorf("Expected configured origins in development mode, got %v", config.AllowedOrigins)
+	}
+}
+
 func TestAddress(t *testing.T) {
 	config := &Config{
 		Host: "localhost",
@@ -127,6 +154,27 @@ func TestAddress(t *testing.T) {
 	}
 }
 
+func TestAdminAddress(t *testing.T) {
+	os.Clearenv()
+	config := mustLoad(t)
+
+	// The admin listener is only reachable locally by default
+	expected := "127.0.0.1:9090"
+	if address := config.AdminAddress(); address != expected {
+		t.Errorf("Expected admin address '%s', got '%s'", expected, address)
+	}
+
+	os.Setenv("ADMIN_HOST", "0.0.0.0")
+	os.Setenv("ADMIN_PORT", "9191")
+	config = mustLoad(t)
+	expected = "0.0.0.0:9191"
+	if address := config.AdminAddress(); address != expected {
+		t.Errorf("Expected admin address '%s', got '%s'", expected, address)
+	}
+
+	os.Clearenv()
+}
+
 func TestIsProductionMode(t *testing.T) {
 	config := &Config{IsDevelopment: false}
 	if !config.IsProductionMode() {
@@ -139,82 +187,216 @@ func TestIsProductionMode(t *testing.T) {
 	}
 }
 
-func TestGetEnvHelpers(t *testing.T) {
-	// Test getEnv
-	os.Setenv("TEST_STRING", "test_value")
-	if value := getEnv("TEST_STRING", "default"); value != "test_value" {
-		t.Errorf("Expected 'test_value', got '%s'", value)
-	}
+func TestLoad_Precedence(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
 
-	if value := getEnv("NONEXISTENT", "default"); value != "default" {
-		t.Errorf("Expected 'default', got '%s'", value)
-	}
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	os.WriteFile(configFile, []byte("port: 7000\nhost: file.example\nlog_level: warn\nread_timeout: 20s\n"), 0o644)
+	envFile := filepath.Join(dir, ".env")
+	os.WriteFile(envFile, []byte("PORT=7100\nHOST=dotenv.example\nLOG_LEVEL=error\n"), 0o644)
+	os.Setenv("PORT", "7200")
+	os.Setenv("HOST", "env.example")
 
-	// Test getIntEnv
-	os.Setenv("TEST_INT", "42")
-	if value := getIntEnv("TEST_INT", 0); value != 42 {
-		t.Errorf("Expected 42, got %d", value)
-	}
+	config := mustLoad(t, "-config", configFile, "-env-file", envFile, "-port", "7300")
 
-	if value := getIntEnv("NONEXISTENT_INT", 10); value != 10 {
-		t.Errorf("Expected 10, got %d", value)
+	// Each source overrides the ones before it
+	if config.Port != "7300" {
+		t.Errorf("Expected flag to win with port 7300, got %s", config.Port)
+	}
+	if config.Host != "env.example" {
+		t.Errorf("Expected environment to override .env, got %s", config.Host)
+	}
+	if config.LogLevel != "error" {
+		t.Errorf("Expected .env to override the config file, got %s", config.LogLevel)
 	}
+	if config.ReadTimeout != 20*time.Second {
+		t.Errorf("Expected config file to override the default, got %v", config.ReadTimeout)
+	}
+}
 
-	// Test getBoolEnv
-	os.Setenv("TEST_BOOL", "true")
-	if value := getBoolEnv("TEST_BOOL", false); value != true {
-		t.Errorf("Expected true, got %v", value)
+func TestLoad_ConfigFileFromEnvironment(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(configFile, []byte("port = 7000\nenable_pprof = true\n"), 0o644)
+	os.Setenv("CONFIG_FILE", configFile)
+
+	config := mustLoad(t)
+	if config.Port != "7000" || !config.EnablePprof {
+		t.Errorf("Expected settings from CONFIG_FILE, got port %s pprof %v", config.Port, config.EnablePprof)
 	}
+}
 
-	if value := getBoolEnv("NONEXISTENT_BOOL", false); value != false {
-		t.Errorf("Expected false, got %v", value)
+func TestLoad_BoolFlag(t *testing.T) {
+	os.Clearenv()
+
+	config := mustLoad(t, "-metrics-enabled=false", "-development")
+	if config.MetricsEnabled || !config.IsDevelopment {
+		t.Errorf("Expected flags to set metrics %v and development %v", config.MetricsEnabled, config.IsDevelopment)
 	}
+}
+
+func TestLoad_ReportsAllErrors(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
 
-	// Test getDurationEnv
-	os.Setenv("TEST_DURATION", "5m")
-	if value := getDurationEnv("TEST_DURATION", time.Second); value != 5*time.Minute {
-		t.Errorf("Expected 5m, got %v", value)
+	os.Setenv("READ_TIMEOUT", "abc")
+	os.Setenv("MAX_SESSIONS_PER_USER", "many")
+	os.Setenv("LOG_FORMAT", "xml")
+
+	_, err := Load([]string{"-port", "http"})
+	if err == nil {
+		t.Fatal("Expected invalid configuration to fail")
+	}
+	for _, expected := range []string{
+		`READ_TIMEOUT (environment): invalid duration "abc"`,
+		`MAX_SESSIONS_PER_USER (environment): invalid integer "many"`,
+		`LOG_FORMAT: must be text or json, got "xml"`,
+		`PORT: must be a port number between 1 and 65535, got "http"`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got:\n%v", expected, err)
+		}
 	}
+}
+
+func TestLoad_MissingFiles(t *testing.T) {
+	os.Clearenv()
 
-	if value := getDurationEnv("NONEXISTENT_DURATION", time.Second); value != time.Second {
-		t.Errorf("Expected 1s, got %v", value)
+	// The default .env is optional, but files named explicitly must exist
+	if _, err := Load([]string{"-env-file", "missing.env"}); err == nil {
+		t.Error("Expected a missing -env-file to fail")
 	}
+	if _, err := Load([]string{"-config", "missing.yaml"}); err == nil {
+		t.Error("Expected a missing -config file to fail")
+	}
+	if _, err := Load([]string{"extra"}); err == nil {
+		t.Error("Expected unexpected arguments to fail")
+	}
+}
 
-	// Test getStringSliceEnv
-	os.Setenv("TEST_SLICE", "a,b,c")
-	expected := []string{"a", "b", "c"}
-	if value := getStringSliceEnv("TEST_SLICE", []string{"default"}); !equalStringSlices(value, expected) {
-		t.Errorf("Expected %v, got %v", expected, value)
+func TestValidate(t *testing.T) {
+	config := defaults()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected defaults to be valid, got %v", err)
+	}
+
+	config.AllowedOrigins = []string{"https://example.com", "example.com", "https://example.com/", "https://*.example.com", "https://*.com", "https://a*.example.com"}
+	config.BusBackend = "kafka"
+	config.ShutdownTimeout = 0
+	config.AdminPort = config.Port
+	config.AdminHost = config.Host
+	config.MessageRateLimit = -1
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Expected invalid configuration to fail validation")
+	}
+	for _, expected := range []string{
+		`ALLOWED_ORIGINS: "example.com" is not an origin`,
+		`ALLOWED_ORIGINS: "https://example.com/" is not an origin`,
+		`ALLOWED_ORIGINS: "https://*.com" may only use a wildcard`,
+		`ALLOWED_ORIGINS: "https://a*.example.com" may only use a wildcard`,
+		`BUS_BACKEND: must be local or redis`,
+		`SHUTDOWN_TIMEOUT: must be positive`,
+		`ADMIN_PORT: admin listener must not use the server address`,
+		`MESSAGE_RATE_LIMIT: must not be negative`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got:\n%v", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), `"https://example.com" is not`) || strings.Contains(err.Error(), `"https://*.example.com"`) {
+		t.Errorf("Expected https://example.com and https://*.example.com to be valid origins, got:\n%v", err)
 	}
 
-	defaultSlice := []string{"default"}
-	if value := getStringSliceEnv("NONEXISTENT_SLICE", defaultSlice); !equalStringSlices(value, defaultSlice) {
-		t.Errorf("Expected %v, got %v", defaultSlice, value)
+	config = defaults()
+	config.DrainDelay = config.ShutdownTimeout
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "DRAIN_DELAY: must be shorter than SHUTDOWN_TIMEOUT") {
+		t.Errorf("Expected a drain delay filling the shutdown timeout to be rejected, got %v", err)
 	}
+}
 
-	os.Clearenv()
+func TestValidate_AdminAddress(t *testing.T) {
+	tests := []struct {
+		host, adminHost string
+		conflict        bool
+	}{
+		{"localhost", "127.0.0.1", false},
+		{"10.0.0.1", "10.0.0.1", true},
+		{"", "127.0.0.1", true},
+		{"0.0.0.0", "127.0.0.1", true},
+		{"::", "127.0.0.1", true},
+		{"10.0.0.1", "0.0.0.0", true},
+		{"10.0.0.1", "", true},
+	}
+	for _, tt := range tests {
+		config := defaults()
+		config.Host, config.AdminHost = tt.host, tt.adminHost
+		config.AdminPort = config.Port
+
+		err := config.Validate()
+		conflict := err != nil && strings.Contains(err.Error(), "admin listener must not use the server address")
+		if conflict != tt.conflict {
+			t.Errorf("Host %q and ADMIN_HOST %q on one port: expected conflict %v, got %v", tt.host, tt.adminHost, tt.conflict, err)
+		}
+
+		config.AdminPort = "9191"
+		if err := config.Validate(); err != nil {
+			t.Errorf("Host %q and ADMIN_HOST %q on different ports: expected no error, got %v", tt.host, tt.adminHost, err)
+		}
+	}
 }
 
-func TestInvalidEnvironmentValues(t *testing.T) {
-	// Test invalid int
-	os.Setenv("INVALID_INT", "not_a_number")
-	if value := getIntEnv("INVALID_INT", 42); value != 42 {
-		t.Errorf("Expected default value 42 for invalid int, got %d", value)
+func TestLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"DEBUG":   slog.LevelDebug,
+		"info":    slog.LevelInfo,
+		"warn":    slog.LevelWarn,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+		"verbose": slog.LevelInfo,
+	}
+
+	for name, expected := range tests {
+		config := &Config{LogLevel: name}
+		if level := config.Level(); level != expected {
+			t.Errorf("Expected level %v for %q, got %v", expected, name, level)
+		}
 	}
+}
 
-	// Test invalid bool
-	os.Setenv("INVALID_BOOL", "not_a_bool")
-	if value := getBoolEnv("INVALID_BOOL", true); value != true {
-		t.Errorf("Expected default value true for invalid bool, got %v", value)
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	config := &Config{LogFormat: "json"}
+	logger := config.NewLogger(&buf, slog.LevelInfo)
+	logger.Debug("Skipping offline user")
+	logger.Info("User joined", "session_id", "ABC")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the info line, got %q", buf.String())
 	}
 
-	// Test invalid duration
-	os.Setenv("INVALID_DURATION", "not_a_duration")
-	if value := getDurationEnv("INVALID_DURATION", time.Minute); value != time.Minute {
-		t.Errorf("Expected default value 1m for invalid duration, got %v", value)
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Expected a JSON log line, got %q", lines[0])
+	}
+	if entry["msg"] != "User joined" || entry["session_id"] != "ABC" {
+		t.Errorf("Unexpected log entry %v", entry)
 	}
 
-	os.Clearenv()
+	buf.Reset()
+	config.LogFormat = "text"
+	config.NewLogger(&buf, slog.LevelInfo).Info("User joined", "session_id", "ABC")
+	if !strings.Contains(buf.String(), "msg=\"User joined\" session_id=ABC") {
+		t.Errorf("Expected a text log line, got %q", buf.String())
+	}
 }
 
 // Helper function to compare string slices
@@ -229,3 +411,185 @@ func equalStringSlices(a, b []string) bool {
 	}
 	return true
 }
+
+func TestReload(t *testing.T) {
+	current := defaults()
+	next := defaults()
+	next.AllowedOrigins = []string{"https://poker.example.com"}
+	next.LogLevel = "debug"
+	next.Port = "9999"
+	next.SessionTimeout = time.Hour
+	next.MessageRateLimit = 5
+	next.MaxMessageSize = 4096
+
+	reloaded, changed, restart := current.Reload(next)
+
+	if !equalStringSlices(reloaded.AllowedOrigins, next.AllowedOrigins) || reloaded.LogLevel != "debug" {
+		t.Errorf("Expected origins and log level to reload, got %v %s", reloaded.AllowedOrigins, reloaded.LogLevel)
+	}
+	if reloaded.SessionTimeout != time.Hour || reloaded.MessageRateLimit != 5 {
+		t.Errorf("Expected session timeout and rate limit to reload, got %v %d", reloaded.SessionTimeout, reloaded.MessageRateLimit)
+	}
+	if reloaded.Port != "8080" || reloaded.MaxMessageSize != 1024 {
+		t.Errorf("Expected port and message size to keep their startup values, got %s %d", reloaded.Port, reloaded.MaxMessageSize)
+	}
+	expected := []string{"ALLOWED_ORIGINS", "SESSION_TIMEOUT", "MESSAGE_RATE_LIMIT", "LOG_LEVEL"}
+	if !equalStringSlices(changed, expected) {
+		t.Errorf("Expected %v to change, got %v", expected, changed)
+	}
+	if !equalStringSlices(restart, []string{"PORT", "MAX_MESSAGE_SIZE"}) {
+		t.Errorf("Expected PORT and MAX_MESSAGE_SIZE to need a restart, got %v", restart)
+	}
+
+	// The running configuration is never modified in place
+	if current.LogLevel != "info" || len(current.AllowedOrigins) != 1 || current.AllowedOrigins[0] != "*" {
+		t.Errorf("Expected current configuration to be unchanged, got %+v", current)
+	}
+}
+
+func TestValidate_TLS(t *testing.T) {
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	os.WriteFile(certFile, []byte("certificate"), 0o600)
+
+	config := defaults()
+	config.TLSCertFile = certFile
+	config.TLSKeyFile = certFile
+	config.HTTPRedirectPort = "80"
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected TLS with a redirect port to be valid, got %v", err)
+	}
+
+	config.TLSKeyFile = ""
+	config.ACMEDomains = []string{"poker.example.com"}
+	config.HTTPRedirectPort = config.Port
+	err := config.Validate()
+	for _, expected := range []string{
+		"TLS_CERT_FILE and TLS_KEY_FILE must be set together",
+		"ACME_DOMAINS: cannot be used with TLS_CERT_FILE",
+		"HTTP_REDIRECT_PORT: must differ from PORT",
+	} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got %v", expected, err)
+		}
+	}
+
+	config = defaults()
+	config.TLSCertFile = filepath.Join(t.TempDir(), "missing.pem")
+	config.TLSKeyFile = config.TLSCertFile
+	config.HTTPRedirectPort = "80"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "TLS_KEY_FILE: stat") {
+		t.Errorf("Expected missing key file to be reported, got %v", err)
+	}
+
+	config = defaults()
+	config.HTTPRedirectPort = "80"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "HTTP_REDIRECT_PORT: requires TLS_CERT_FILE or ACME_DOMAINS") {
+		t.Errorf("Expected redirect without TLS to be rejected, got %v", err)
+	}
+}
+
+func TestValidate_Jira(t *testing.T) {
+	config := defaults()
+	config.JiraURL = "https://example.atlassian.net"
+	config.JiraAPIToken = "token"
+	config.JiraProjects = []string{"PP", "WEB_2"}
+	if err := config.Validate(); err != nil || !config.JiraEnabled() {
+		t.Fatalf("Expected Jira with a token and projects to be valid, got %v", err)
+	}
+
+	config.JiraURL = "example.atlassian.net"
+	config.JiraAPIToken = ""
+	config.JiraStoryPointsField = ""
+	config.JiraProjects = []string{"pp"}
+	err := config.Validate()
+	for _, expected := range []string{
+		`JIRA_URL: must be an http or https URL, got "example.atlassian.net"`,
+		"JIRA_API_TOKEN: is required when JIRA_URL is set",
+		"JIRA_STORY_POINTS_FIELD: is required when JIRA_URL is set",
+		`JIRA_PROJECTS: "pp" is not a project key such as PP`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestValidate_GitHub(t *testing.T) {
+	config := defaults()
+	config.GitHubToken = "ghp_test"
+	config.GitHubRepos = []string{"acme/web", "acme/api"}
+	if err := config.Validate(); err != nil || !config.GitHubEnabled() {
+		t.Fatalf("Expected GitHub with label estimates to be valid, got %v", err)
+	}
+
+	config.GitHubEstimateTarget = "project"
+	config.GitHubAPIURL = "api.github.com"
+	config.GitHubRepos = []string{"acme"}
+	err := config.Validate()
+	for _, expected := range []string{
+		`GITHUB_REPOS: "acme" is not a repository such as acme/web`,
+		`GITHUB_API_URL: must be an http or https URL, got "api.github.com"`,
+		"GITHUB_PROJECT_NUMBER: must be positive when GITHUB_ESTIMATE_TARGET is project",
+	} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got %v", expected, err)
+		}
+	}
+
+	config.GitHubEstimateTarget = "comment"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "GITHUB_ESTIMATE_TARGET: must be label or project") {
+		t.Errorf("Expected an unknown target to be rejected, got %v", err)
+	}
+
+	config.GitHubRepos = nil
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "GITHUB_REPOS: is required when GITHUB_TOKEN is set") {
+		t.Errorf("Expected repositories to be required, got %v", err)
+	}
+}
+
+func TestValidate_Webhooks(t *testing.T) {
+	config := defaults()
+	config.WebhookURLs = []string{"https://ci.example.com/poker"}
+	config.WebhookEvents = []string{"estimate_set", "session_ended"}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected webhooks to be valid, got %v", err)
+	}
+
+	config.WebhookURLs = append(config.WebhookURLs, "ci.example.com")
+	config.WebhookEvents = append(config.WebhookEvents, "vote")
+	config.WebhookMaxAttempts = 0
+	config.WebhookAllowedNetworks = []string{"10.1.2.3/16", "10.0.0.1"}
+	err := config.Validate()
+	for _, expected := range []string{
+		`WEBHOOK_URLS: "ci.example.com" is not an http or https URL`,
+		`WEBHOOK_EVENTS: "vote" is not one of`,
+		"WEBHOOK_MAX_ATTEMPTS: must be positive",
+		`WEBHOOK_ALLOWED_NETWORKS: "10.0.0.1" is not a network such as 10.0.0.0/8`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got %v", expected, err)
+		}
+	}
+	if networks := config.WebhookNetworks(); len(networks) != 1 || networks[0].String() != "10.1.0.0/16" {
+		t.Errorf("Expected the valid network, masked, got %v", networks)
+	}
+}
+
+func TestValidate_Chat(t *testing.T) {
+	config := defaults()
+	config.ChatCommandToken = "xr3j5x3p4pfq7ns3ntxmbuw3ky"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "PUBLIC_URL: must be the http or https URL of the web UI") {
+		t.Errorf("Expected the slash command to require PUBLIC_URL, got %v", err)
+	}
+
+	config.PublicURL = "https://poker.example.com"
+	config.ChatWebhookURL = "hooks.slack.com/services/T0/B0/x"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "CHAT_WEBHOOK_URL: must be an http or https URL") {
+		t.Errorf("Expected an invalid webhook URL to be rejected, got %v", err)
+	}
+
+	config.ChatWebhookURL = "https://hooks.slack.com/services/T0/B0/x"
+	if err := config.Validate(); err != nil || !config.ChatCommandEnabled() {
+		t.Errorf("Expected chat settings to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is one setting. It is named by its environment variable; config
+// files use the lower-case name (read_timeout) and flags the lower-case
+// name with dashes (-read-timeout).
+type field struct {
+	env     string
+	usage   string
+	isBool  bool
+	set     func(c *Config, value string) error
+	setList func(c *Config, items []string) // Set for list settings only
+	value   func(c *Config) interface{}     // Pointer to the setting in c
+}
+
+// fields lists every setting that can be configured
+var fields = []field{
+	stringField("PORT", "Server port", func(c *Config) *string { return &c.Port }),
+	stringField("HOST", "Server host", func(c *Config) *string { return &c.Host }),
+	durationField("READ_TIMEOUT", "Request read timeout", func(c *Config) *time.Duration { return &c.ReadTimeout }),
+	durationField("WRITE_TIMEOUT", "Response write timeout", func(c *Config) *time.Duration { return &c.WriteTimeout }),
+	durationField("IDLE_TIMEOUT", "Connection idle timeout", func(c *Config) *time.Duration { return &c.IdleTimeout }),
+	durationField("SHUTDOWN_TIMEOUT", "Graceful shutdown timeout", func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+	durationField("DRAIN_DELAY", "How long shutdown fails readiness checks before disconnecting clients", func(c *Config) *time.Duration { return &c.DrainDelay }),
+	stringField("TLS_CERT_FILE", "TLS certificate file (PEM); enables HTTPS with TLS_KEY_FILE", func(c *Config) *string { return &c.TLSCertFile }),
+	stringField("TLS_KEY_FILE", "TLS private key file (PEM)", func(c *Config) *string { return &c.TLSKeyFile }),
+	listField("ACME_DOMAINS", "Comma-separated domains to obtain certificates for with ACME; enables HTTPS", func(c *Config) *[]string { return &c.ACMEDomains }),
+	stringField("ACME_EMAIL", "Contact email for the ACME account", func(c *Config) *string { return &c.ACMEEmail }),
+	stringField("ACME_CACHE_DIR", "Directory storing ACME certificates and account keys", func(c *Config) *string { return &c.ACMECacheDir }),
+	stringField("ACME_DIRECTORY_URL", "ACME directory URL (default Let's Encrypt production)", func(c *Config) *string { return &c.ACMEDirectoryURL }),
+	stringField("HTTP_REDIRECT_PORT", "Plain HTTP port redirecting to HTTPS and answering ACME challenges", func(c *Config) *string { return &c.HTTPRedirectPort }),
+	listField("ALLOWED_ORIGINS", "Comma-separated list of allowed origins", func(c *Config) *[]string { return &c.AllowedOrigins }),
+	int64Field("MAX_MESSAGE_SIZE", "Maximum WebSocket message size in bytes", func(c *Config) *int64 { return &c.MaxMessageSize }),
+	durationField("SESSION_TIMEOUT", "Age after which sessions with nobody connected are removed", func(c *Config) *time.Duration { return &c.SessionTimeout }),
+	intField("MAX_SESSIONS_PER_USER", "Maximum sessions per user", func(c *Config) *int { return &c.MaxSessionsPerUser }),
+	intField("MESSAGE_RATE_LIMIT", "Messages per second each participant may send; 0 disables the limit", func(c *Config) *int { return &c.MessageRateLimit }),
+	stringField("BUS_BACKEND", "Event bus used to share sessions: local or redis", func(c *Config) *string { return &c.BusBackend }),
+	stringField("REDIS_ADDR", "Redis address when the bus backend is redis", func(c *Config) *string { return &c.RedisAddr }),
+	stringField("REDIS_PASSWORD", "Optional Redis AUTH password", func(c *Config) *string { return &c.RedisPassword }),
+	stringField("LOG_LEVEL", "Log level: debug, info, warn or error", func(c *Config) *string { return &c.LogLevel }),
+	stringField("LOG_FORMAT", "Log format: text or json", func(c *Config) *string { return &c.LogFormat }),
+	boolField("METRICS_ENABLED", "Serve Prometheus metrics on the admin listener", func(c *Config) *bool { return &c.MetricsEnabled }),
+	stringField("ADMIN_HOST", "Admin listener host", func(c *Config) *string { return &c.AdminHost }),
+	stringField("ADMIN_PORT", "Admin listener port", func(c *Config) *string { return &c.AdminPort }),
+	stringField("JIRA_URL", "Jira site to import stories from, such as https://example.atlassian.net", func(c *Config) *string { return &c.JiraURL }),
+	stringField("JIRA_EMAIL", "Jira Cloud account email; leave empty to use JIRA_API_TOKEN as a personal access token", func(c *Config) *string { return &c.JiraEmail }),
+	stringField("JIRA_API_TOKEN", "Jira API token or personal access token", func(c *Config) *string { return &c.JiraAPIToken }),
+	stringField("JIRA_STORY_POINTS_FIELD", "Jira field that agreed estimates are written to", func(c *Config) *string { return &c.JiraStoryPointsField }),
+	listField("JIRA_PROJECTS", "Comma-separated keys of the Jira projects stories may be imported from and estimates written to", func(c *Config) *[]string { return &c.JiraProjects }),
+	stringField("GITHUB_TOKEN", "GitHub token to import issues with; enables the GitHub integration", func(c *Config) *string { return &c.GitHubToken }),
+	stringField("GITHUB_API_URL", "GitHub API URL; https://HOST/api/v3 for GitHub Enterprise Server", func(c *Config) *string { return &c.GitHubAPIURL }),
+	stringField("GITHUB_ESTIMATE_TARGET", "Where agreed estimates are written: label or project", func(c *Config) *string { return &c.GitHubEstimateTarget }),
+	stringField("GITHUB_LABEL_PREFIX", "Prefix of estimate labels", func(c *Config) *string { return &c.GitHubLabelPrefix }),
+	intField("GITHUB_PROJECT_NUMBER", "Project whose number field estimates are written to", func(c *Config) *int { return &c.GitHubProjectNumber }),
+	stringField("GITHUB_PROJECT_FIELD", "Name of the project's number field for estimates", func(c *Config) *string { return &c.GitHubProjectField }),
+	listField("GITHUB_REPOS", "Comma-separated owner/repo names issues may be imported from and estimates written to", func(c *Config) *[]string { return &c.GitHubRepos }),
+	stringField("PUBLIC_URL", "URL users reach the web UI at, used in chat links", func(c *Config) *string { return &c.PublicURL }),
+	stringField("CHAT_WEBHOOK_URL", "Slack or Mattermost incoming webhook that every session's events are posted to", func(c *Config) *string { return &c.ChatWebhookURL }),
+	stringField("CHAT_SIGNING_SECRET", "Slack signing secret; enables the /poker slash command", func(c *Config) *string { return &c.ChatSigningSecret }),
+	stringField("CHAT_COMMAND_TOKEN", "Mattermost slash command token; enables the /poker slash command", func(c *Config) *string { return &c.ChatCommandToken }),
+	listField("WEBHOOK_URLS", "Comma-separated URLs that receive the events of every session", func(c *Config) *[]string { return &c.WebhookURLs }),
+	stringField("WEBHOOK_SECRET", "Secret that signs global webhook payloads", func(c *Config) *string { return &c.WebhookSecret }),
+	listField("WEBHOOK_EVENTS", "Comma-separated events sent to global webhooks; empty for all", func(c *Config) *[]string { return &c.WebhookEvents }),
+	intField("WEBHOOK_MAX_ATTEMPTS", "Delivery attempts per webhook event, including the first", func(c *Config) *int { return &c.WebhookMaxAttempts }),
+	listField("WEBHOOK_ALLOWED_NETWORKS", "Comma-separated private networks that session webhooks may reach", func(c *Config) *[]string { return &c.WebhookAllowedNetworks }),
+	stringField("WEB_DIR", "Serve the web UI from this directory instead of the embedded copy", func(c *Config) *string { return &c.WebDir }),
+	boolField("DEVELOPMENT", "Enable development mode", func(c *Config) *bool { return &c.IsDevelopment }),
+	boolField("ENABLE_PPROF", "Serve pprof on the admin listener", func(c *Config) *bool { return &c.EnablePprof }),
+}
+
+// fieldByKey returns the field named by a config file key
+func fieldByKey(key string) (field, bool) {
+	for _, f := range fields {
+		if strings.ToLower(f.env) == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// newField builds a field that parses values with parse and stores them in
+// the setting returned by target
+func newField[T any](env, usage string, parse func(string) (T, error), target func(*Config) *T) field {
+	return field{
+		env:   env,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			parsed, err := parse(value)
+			if err != nil {
+				return err
+			}
+			*target(c) = parsed
+			return nil
+		},
+		value: func(c *Config) interface{} { return target(c) },
+	}
+}
+
+func stringField(env, usage string, target func(*Config) *string) field {
+	return newField(env, usage, func(value string) (string, error) {
+		return value, nil
+	}, target)
+}
+
+func intField(env, usage string, target func(*Config) *int) field {
+	return newField(env, usage, func(value string) (int, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer %q", value)
+		}
+		return n, nil
+	}, target)
+}
+
+func int64Field(env, usage string, target func(*Config) *int64) field {
+	return newField(env, usage, func(value string) (int64, error) {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer %q", value)
+		}
+		return n, nil
+	}, target)
+}
+
+func boolField(env, usage string, target func(*Config) *bool) field {
+	f := newField(env, usage, func(value string) (bool, error) {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid boolean %q", value)
+		}
+		return b, nil
+	}, target)
+	f.isBool = true
+	return f
+}
+
+func durationField(env, usage string, target func(*Config) *time.Duration) field {
+	return newField(env, usage, func(value string) (time.Duration, error) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return d, nil
+	}, target)
+}
+
+// listField builds a list setting. A single value is split on commas; a
+// list from a config file is taken as is, apart from trimming.
+func listField(env, usage string, target func(*Config) *[]string) field {
+	f := newField(env, usage, func(value string) ([]string, error) {
+		return trimItems(strings.Split(value, ",")), nil
+	}, target)
+	f.setList = func(c *Config, items []string) {
+		*target(c) = trimItems(items)
+	}
+	return f
+}
+
+// trimItems trims each item and drops empty ones
+func trimItems(items []string) []string {
+	var trimmed []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			trimmed = append(trimmed, item)
+		}
+	}
+	return trimmed
+}
+
+// flagValue records a flag without parsing it, so flags are applied with
+// the other sources and report errors the same way
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *flagValue) String() string     { return v.value }
+func (v *flagValue) Set(s string) error { v.value = s; return nil }
+func (v *flagValue) IsBoolFlag() bool   { return v.isBool }
+
+// commandLine holds the parsed command-line flags
+type commandLine struct {
+	configFile string
+	envFile    string
+	settings   map[string]string // Flags given, keyed by environment variable name
+}
+
+// parseFlags parses a flag for every field plus -config and -env-file
+func parseFlags(args []string) (*commandLine, error) {
+	cmd := &commandLine{settings: make(map[string]string)}
+
+	flags := flag.NewFlagSet("planning-poker", flag.ContinueOnError)
+	flags.StringVar(&cmd.configFile, "config", "", "Path to a YAML or TOML config file (env CONFIG_FILE)")
+	flags.StringVar(&cmd.envFile, "env-file", "", "Path to a .env file (default .env, ignored if missing)")
+	values := make(map[string]*flagValue)
+	for _, f := range fields {
+		name := strings.ReplaceAll(strings.ToLower(f.env), "_", "-")
+		values[name] = &flagValue{isBool: f.isBool}
+		flags.Var(values[name], name, fmt.Sprintf("%s (env %s)", f.usage, f.env))
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		if value, exists := values[f.Name]; exists {
+			env := strings.ReplaceAll(strings.ToUpper(f.Name), "-", "_")
+			cmd.settings[env] = value.value
+		}
+	})
+	return cmd, nil
+}
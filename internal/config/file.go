@@ -0,0 +1,201 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile reads a YAML or TOML config file, chosen by extension.
+// Keys are the lower-case environment variable names and only top-level
+// settings are allowed. The returned settings are keyed by environment
+// variable name and hold a string, or a []string for a list.
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	var document map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	settings := make(map[string]any)
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(document)) {
+		f, exists := fieldByKey(key)
+		if !exists {
+			errs = append(errs, fmt.Errorf("unknown setting %q", key))
+			continue
+		}
+		value, err := settingValue(document[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		if value != nil {
+			settings[f.env] = value
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return settings, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// settingValue converts a decoded value to a string, or a list of scalars
+// to a []string. A null value is returned as nil and leaves the setting
+// unset.
+func settingValue(value any) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if item == nil {
+				continue
+			}
+			s, err := scalarString(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, s)
+		}
+		return items, nil
+	default:
+		return scalarString(v)
+	}
+}
+
+// scalarString formats a decoded string, number or boolean
+func scalarString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case map[string]any:
+		return "", errors.New("nested settings are not supported")
+	case []any:
+		return "", errors.New("nested lists are not supported")
+	default:
+		return "", fmt.Errorf("unsupported value %v; quote it to use it as a string", v)
+	}
+}
+
+// readDotEnv reads KEY=value lines from a .env file. Keys that do not name
+// a setting are ignored, as the file is often shared with docker-compose;
+// empty values are treated as unset, as they are in the environment.
+func readDotEnv(path string) (map[string]string, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]string)
+	var errs []error
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			errs = append(errs, fmt.Errorf("%s:%d: expected KEY=value", path, i+1))
+			continue
+		}
+		value, err := parseScalar(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", path, i+1, err))
+			continue
+		}
+		if value != "" {
+			settings[strings.TrimSpace(key)] = value
+		}
+	}
+	return settings, errors.Join(errs...)
+}
+
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// parseScalar removes the quotes around a string value
+func parseScalar(value string) (string, error) {
+	value = strings.TrimSpace(stripComment(value))
+	switch {
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", value)
+		}
+		return unquoted, nil
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1], nil
+	case strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'"):
+		return "", fmt.Errorf("unterminated string %s", value)
+	default:
+		return value, nil
+	}
+}
+
+// stripComment removes a # comment that starts the line or follows
+// whitespace, outside quotes
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
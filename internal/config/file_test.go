@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadConfigFile_YAML(t *testing.T) {
+	path := writeFile(t, "config.yml", `---
+# Server
+port: 8081
+host: 0.0.0.0 # all interfaces
+read_timeout: 30s
+development: false
+redis_addr:
+allowed_origins:
+  - https://poker.example.com
+  - 'https://admin.example.com'
+webhook_urls: ["https://hooks.example.com/a?ids=1,2", https://hooks.example.com/b]
+log_format: >-
+  json
+`)
+
+	settings, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("readConfigFile failed: %v", err)
+	}
+
+	expected := map[string]any{
+		"PORT":            "8081",
+		"HOST":            "0.0.0.0",
+		"READ_TIMEOUT":    "30s",
+		"DEVELOPMENT":     "false",
+		"ALLOWED_ORIGINS": []string{"https://poker.example.com", "https://admin.example.com"},
+		"WEBHOOK_URLS":    []string{"https://hooks.example.com/a?ids=1,2", "https://hooks.example.com/b"},
+		"LOG_FORMAT":      "json",
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Expected %v, got %v", expected, settings)
+	}
+}
+
+func TestReadConfigFile_TOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+# Server
+port = 8081
+read_timeout = "30s"
+allowed_origins = [
+  "https://a.example.com",
+  'https://b.example.com', # trailing comma
+]
+webhook_urls = ["https://hooks.example.com/a?ids=1,2"]
+development = false
+`)
+
+	settings, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("readConfigFile failed: %v", err)
+	}
+
+	expected := map[string]any{
+		"PORT":            "8081",
+		"READ_TIMEOUT":    "30s",
+		"DEVELOPMENT":     "false",
+		"ALLOWED_ORIGINS": []string{"https://a.example.com", "https://b.example.com"},
+		"WEBHOOK_URLS":    []string{"https://hooks.example.com/a?ids=1,2"},
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Expected %v, got %v", expected, settings)
+	}
+}
+
+func TestReadConfigFile_Errors(t *testing.T) {
+	tests := map[string]struct {
+		name, content, expected string
+	}{
+		"unknown key":    {"config.yaml", "prot: 8080\n", `unknown setting "prot"`},
+		"duplicate yaml": {"config.yaml", "port: 1\nport: 2\n", `"port" already defined`},
+		"duplicate toml": {"config.toml", "port = 1\nport = 2\n", "already been defined"},
+		"nested yaml":    {"config.yaml", "server:\n  port: 8080\n", `unknown setting "server"`},
+		"nested value":   {"config.yaml", "host:\n  name: localhost\n", "host: nested settings are not supported"},
+		"toml table":     {"config.toml", "[host]\nname = \"localhost\"\n", "host: nested settings are not supported"},
+		"unterminated":   {"config.toml", "host = \"localhost\n", "toml: line 1"},
+		"timestamp":      {"config.yaml", "host: 2024-01-01\n", "host: unsupported value"},
+		"unknown format": {"config.json", "{}", "unsupported format"},
+	}
+
+	for name, tt := range tests {
+		_, err := readConfigFile(writeFile(t, tt.name, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tt.expected, err)
+		}
+	}
+}
+
+func TestLoad_ConfigFileLists(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	path := writeFile(t, "config.yaml", `
+webhook_urls:
+  - https://hooks.example.com/a?ids=1,2
+port: [8080]
+`)
+	_, err := Load([]string{"-config", path})
+	if err == nil || !strings.Contains(err.Error(), "PORT ("+path+"): expected a single value, got a list") {
+		t.Errorf("Expected a list for PORT to be rejected, got %v", err)
+	}
+
+	path = writeFile(t, "config.yaml", "webhook_urls:\n  - https://hooks.example.com/a?ids=1,2\n")
+	config := mustLoad(t, "-config", path)
+	if !equalStringSlices(config.WebhookURLs, []string{"https://hooks.example.com/a?ids=1,2"}) {
+		t.Errorf("Expected the URL to keep its comma, got %v", config.WebhookURLs)
+	}
+}
+
+func TestReadDotEnv(t *testing.T) {
+	path := writeFile(t, ".env", `# Planning Poker
+PORT=3000
+export LOG_LEVEL=debug
+HOST=
+REDIS_PASSWORD="s3cret # not a comment"
+ALLOWED_ORIGINS=https://a.example.com # production
+COMPOSE_PROJECT_NAME=poker
+`)
+
+	settings, err := readDotEnv(path)
+	if err != nil {
+		t.Fatalf("readDotEnv failed: %v", err)
+	}
+
+	if settings["PORT"] != "3000" || settings["LOG_LEVEL"] != "debug" {
+		t.Errorf("Unexpected settings %v", settings)
+	}
+	if _, exists := settings["HOST"]; exists {
+		t.Error("Expected empty HOST to be treated as unset")
+	}
+	if settings["REDIS_PASSWORD"] != "s3cret # not a comment" {
+		t.Errorf("Expected quoted value to keep #, got %q", settings["REDIS_PASSWORD"])
+	}
+	if settings["ALLOWED_ORIGINS"] != "https://a.example.com" {
+		t.Errorf("Expected inline comment to be stripped, got %q", settings["ALLOWED_ORIGINS"])
+	}
+}
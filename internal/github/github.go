@@ -0,0 +1,531 @@
+// Package github imports issues from GitHub as stories and writes agreed
+// estimates back as labels or as a Projects field.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// Source is the poker.Story Source of imported issues
+const Source = "github"
+
+const (
+	pageSize       = 100
+	maxIssues      = 200      // Per import; the session queue is bounded too
+	maxErrorBody   = 64 << 10 // Bytes of an error response that are read
+	requestTimeout = 30 * time.Second
+	apiVersion     = "2022-11-28"
+)
+
+// Where estimates are written
+const (
+	TargetLabel   = "label"   // A label such as "estimate: 5"
+	TargetProject = "project" // A number field of a Projects board
+)
+
+var (
+	// ErrNotNumeric is returned when an estimate such as "?" cannot be
+	// stored in a number field
+	ErrNotNumeric = errors.New("estimate is not a number")
+
+	// ErrRepoNotAllowed is returned for issues outside the configured
+	// repositories
+	ErrRepoNotAllowed = errors.New("repository is not configured")
+)
+
+// Config configures a Client
+type Config struct {
+	APIURL string // https://api.github.com, or https://HOST/api/v3 for GitHub Enterprise Server
+	Token  string
+
+	Target        string // TargetLabel or TargetProject
+	LabelPrefix   string // Prefix of estimate labels, such as "estimate: "
+	ProjectNumber int    // Project whose field is written with TargetProject
+	ProjectField  string // Name of the number field, such as "Estimate"
+
+	// Repos are the owner/repo names that imports and write-back are
+	// limited to. Anyone can moderate a session, so the token's own
+	// permissions are not enough.
+	Repos []string
+
+	// HTTPClient is used for requests if set
+	HTTPClient *http.Client
+}
+
+// Client talks to the GitHub API
+type Client struct {
+	config Config
+	http   *http.Client
+}
+
+// New returns a client for the GitHub API in cfg
+func New(cfg Config) *Client {
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	return &Client{config: cfg, http: client}
+}
+
+// Name returns the story source this client handles
+func (c *Client) Name() string {
+	return Source
+}
+
+// Query selects the issues of one repository
+type Query struct {
+	Owner, Repo string
+	Milestone   string // Title or number
+	Labels      []string
+	State       string // open (default), closed or all
+}
+
+// ParseQuery parses an import query such as
+//
+//	acme/web milestone:"Sprint 42" label:ready label:frontend
+//
+// The repository comes first; milestone, label (repeatable) and state
+// filters follow. Values with spaces are quoted.
+func ParseQuery(query string) (Query, error) {
+	fields, err := splitQuery(query)
+	if err != nil {
+		return Query{}, err
+	}
+	if len(fields) == 0 {
+		return Query{}, errors.New("query must start with owner/repo")
+	}
+
+	owner, repo, ok := strings.Cut(fields[0], "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return Query{}, fmt.Errorf("query must start with owner/repo, got %q", fields[0])
+	}
+	q := Query{Owner: owner, Repo: repo, State: "open"}
+
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			return Query{}, fmt.Errorf("expected filter:value, got %q", field)
+		}
+		switch key {
+		case "milestone":
+			q.Milestone = value
+		case "label":
+			q.Labels = append(q.Labels, value)
+		case "state":
+			if value != "open" && value != "closed" && value != "all" {
+				return Query{}, fmt.Errorf("state must be open, closed or all, got %q", value)
+			}
+			q.State = value
+		default:
+			return Query{}, fmt.Errorf("unknown filter %q; use milestone, label or state", key)
+		}
+	}
+	return q, nil
+}
+
+// splitQuery splits a query at spaces outside double quotes, removing the
+// quotes
+func splitQuery(query string) ([]string, error) {
+	var fields []string
+	var current strings.Builder
+	inQuotes, inField := false, false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			inField = true
+		case r == ' ' && !inQuotes:
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(r)
+			inField = true
+		}
+	}
+	if inQuotes {
+		return nil, errors.New("unterminated quote in query")
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields, nil
+}
+
+// issue is the part of a GitHub issue the client reads
+type issue struct {
+	Number      int       `json:"number"`
+	Title       string    `json:"title"`
+	HTMLURL     string    `json:"html_url"`
+	PullRequest *struct{} `json:"pull_request"` // Set on pull requests, which the issues endpoint includes
+}
+
+// Import returns the issues matching a query as stories, in the order
+// GitHub returns them, up to a limit of 200. Pull requests are skipped.
+func (c *Client) Import(ctx context.Context, query string) ([]poker.Story, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if !c.allowed(q.Owner, q.Repo) {
+		return nil, fmt.Errorf("github: %s/%s: %w", q.Owner, q.Repo, ErrRepoNotAllowed)
+	}
+
+	params := url.Values{
+		"state":    {q.State},
+		"per_page": {strconv.Itoa(pageSize)},
+	}
+	if len(q.Labels) > 0 {
+		params.Set("labels", strings.Join(q.Labels, ","))
+	}
+	if q.Milestone != "" {
+		number, err := c.milestoneNumber(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("milestone", number)
+	}
+
+	var stories []poker.Story
+	next := c.repoPath(q.Owner, q.Repo) + "/issues?" + params.Encode()
+	for next != "" && len(stories) < maxIssues {
+		var page []issue
+		resp, err := c.do(ctx, http.MethodGet, next, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range page {
+			if issue.PullRequest != nil {
+				continue
+			}
+			stories = append(stories, poker.Story{
+				Key:    fmt.Sprintf("%s/%s#%d", q.Owner, q.Repo, issue.Number),
+				Title:  issue.Title,
+				URL:    issue.HTMLURL,
+				Source: Source,
+			})
+		}
+
+		// The token is only ever sent to the configured API
+		next = nextLink(resp.Header.Get("Link"))
+		if !strings.HasPrefix(next, c.config.APIURL+"/") {
+			next = ""
+		}
+	}
+
+	if len(stories) > maxIssues {
+		stories = stories[:maxIssues]
+	}
+	return stories, nil
+}
+
+// milestoneNumber resolves a milestone title to the number the issues
+// endpoint filters by
+func (c *Client) milestoneNumber(ctx context.Context, q Query) (string, error) {
+	if _, err := strconv.Atoi(q.Milestone); err == nil {
+		return q.Milestone, nil
+	}
+
+	var milestones []struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+	}
+	path := c.repoPath(q.Owner, q.Repo) + "/milestones?state=all&per_page=100"
+	if _, err := c.do(ctx, http.MethodGet, path, nil, &milestones); err != nil {
+		return "", err
+	}
+	for _, m := range milestones {
+		if strings.EqualFold(m.Title, q.Milestone) {
+			return strconv.Itoa(m.Number), nil
+		}
+	}
+	return "", fmt.Errorf("github: no milestone %q in %s/%s", q.Milestone, q.Owner, q.Repo)
+}
+
+// linkNext matches the next page in a Link header
+var linkNext = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextLink returns the URL of the next page, or "" on the last page
+func nextLink(header string) string {
+	if match := linkNext.FindStringSubmatch(header); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// WriteEstimate records the agreed estimate of an imported issue
+func (c *Client) WriteEstimate(ctx context.Context, story poker.Story) error {
+	owner, repo, number, err := parseKey(story.Key)
+	if err != nil {
+		return err
+	}
+	if !c.allowed(owner, repo) {
+		return fmt.Errorf("github: %s: %w", story.Key, ErrRepoNotAllowed)
+	}
+
+	if c.config.Target == TargetProject {
+		return c.writeProjectField(ctx, owner, repo, number, story.Estimate)
+	}
+	return c.writeLabel(ctx, owner, repo, number, story.Estimate)
+}
+
+// allowed reports whether owner/repo is a configured repository. GitHub
+// names are case-insensitive.
+func (c *Client) allowed(owner, repo string) bool {
+	for _, allowed := range c.config.Repos {
+		if strings.EqualFold(allowed, owner+"/"+repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseKey splits an owner/repo#number story key
+func parseKey(key string) (owner, repo string, number int, err error) {
+	name, num, ok := strings.Cut(key, "#")
+	owner, repo, ok2 := strings.Cut(name, "/")
+	number, convErr := strconv.Atoi(num)
+	if !ok || !ok2 || convErr != nil {
+		return "", "", 0, fmt.Errorf("github: invalid issue key %q", key)
+	}
+	return owner, repo, number, nil
+}
+
+// writeLabel replaces the issue's estimate labels with one for estimate
+func (c *Client) writeLabel(ctx context.Context, owner, repo string, number int, estimate string) error {
+	issuePath := fmt.Sprintf("%s/issues/%d", c.repoPath(owner, repo), number)
+	label := c.config.LabelPrefix + estimate
+
+	var labels []struct {
+		Name string `json:"name"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, issuePath+"/labels?per_page=100", nil, &labels); err != nil {
+		return err
+	}
+	for _, existing := range labels {
+		if existing.Name == label || !strings.HasPrefix(existing.Name, c.config.LabelPrefix) {
+			continue
+		}
+		if _, err := c.do(ctx, http.MethodDelete, issuePath+"/labels/"+url.PathEscape(existing.Name), nil, nil); err != nil {
+			return err
+		}
+	}
+
+	// GitHub creates labels that do not exist yet
+	body := map[string][]string{"labels": {label}}
+	_, err := c.do(ctx, http.MethodPost, issuePath+"/labels", body, nil)
+	return err
+}
+
+// Projects fields can only be written through the GraphQL API
+const (
+	projectItemsQuery = `query($owner: String!, $repo: String!, $number: Int!, $field: String!) {
+  repository(owner: $owner, name: $repo) {
+    issue(number: $number) {
+      projectItems(first: 50) {
+        nodes {
+          id
+          project { id number field(name: $field) { ... on ProjectV2Field { id dataType } } }
+        }
+      }
+    }
+  }
+}`
+	updateFieldMutation = `mutation($project: ID!, $item: ID!, $field: ID!, $value: Float!) {
+  updateProjectV2ItemFieldValue(input: {projectId: $project, itemId: $item, fieldId: $field, value: {number: $value}}) {
+    projectV2Item { id }
+  }
+}`
+)
+
+// writeProjectField sets the number field of the issue's item on the
+// configured project
+func (c *Client) writeProjectField(ctx context.Context, owner, repo string, number int, estimate string) error {
+	value, err := strconv.ParseFloat(estimate, 64)
+	if err != nil {
+		return fmt.Errorf("%s/%s#%d: %w: %q", owner, repo, number, ErrNotNumeric, estimate)
+	}
+
+	var items struct {
+		Repository struct {
+			Issue *struct {
+				ProjectItems struct {
+					Nodes []struct {
+						ID      string `json:"id"`
+						Project struct {
+							ID     string `json:"id"`
+							Number int    `json:"number"`
+							Field  *struct {
+								ID       string `json:"id"`
+								DataType string `json:"dataType"`
+							} `json:"field"`
+						} `json:"project"`
+					} `json:"nodes"`
+				} `json:"projectItems"`
+			} `json:"issue"`
+		} `json:"repository"`
+	}
+	variables := map[string]interface{}{"owner": owner, "repo": repo, "number": number, "field": c.config.ProjectField}
+	if err := c.graphQL(ctx, projectItemsQuery, variables, &items); err != nil {
+		return err
+	}
+	if items.Repository.Issue == nil {
+		return fmt.Errorf("github: no issue %s/%s#%d", owner, repo, number)
+	}
+
+	for _, item := range items.Repository.Issue.ProjectItems.Nodes {
+		if item.Project.Number != c.config.ProjectNumber {
+			continue
+		}
+		field := item.Project.Field
+		if field == nil || field.DataType != "NUMBER" {
+			return fmt.Errorf("github: project %d has no number field %q", c.config.ProjectNumber, c.config.ProjectField)
+		}
+		variables := map[string]interface{}{"project": item.Project.ID, "item": item.ID, "field": field.ID, "value": value}
+		return c.graphQL(ctx, updateFieldMutation, variables, nil)
+	}
+	return fmt.Errorf("github: %s/%s#%d is not in project %d", owner, repo, number, c.config.ProjectNumber)
+}
+
+// graphQL runs a GraphQL query and decodes its data into out unless it is
+// nil
+func (c *Client) graphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	body := map[string]interface{}{"query": query, "variables": variables}
+	if _, err := c.do(ctx, http.MethodPost, c.graphQLURL(), body, &response); err != nil {
+		return err
+	}
+
+	if len(response.Errors) > 0 {
+		apiErr := &Error{Status: http.StatusOK}
+		for _, e := range response.Errors {
+			apiErr.Messages = append(apiErr.Messages, e.Message)
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(response.Data, out); err != nil {
+		return fmt.Errorf("github: invalid response: %w", err)
+	}
+	return nil
+}
+
+// graphQLURL returns the GraphQL endpoint next to the REST API: /graphql on
+// api.github.com and /api/graphql on GitHub Enterprise Server
+func (c *Client) graphQLURL() string {
+	if base, ok := strings.CutSuffix(c.config.APIURL, "/api/v3"); ok {
+		return base + "/api/graphql"
+	}
+	return c.config.APIURL + "/graphql"
+}
+
+func (c *Client) repoPath(owner, repo string) string {
+	return "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(repo)
+}
+
+// Error is a failed request to GitHub
+type Error struct {
+	Status   int
+	Messages []string
+}
+
+func (e *Error) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("github: %s", http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("github: %s: %s", http.StatusText(e.Status), strings.Join(e.Messages, "; "))
+}
+
+// do sends a request with an optional JSON body to a path on the API, or
+// to an absolute URL such as a pagination link, and decodes the response
+// into out unless it is nil
+func (c *Client) do(ctx context.Context, method, target string, body, out interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	if strings.HasPrefix(target, "/") {
+		target = c.config.APIURL + target
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", apiVersion)
+	req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("github: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, responseError(resp)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("github: invalid response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// responseError reads the message of a GitHub error response
+func responseError(resp *http.Response) error {
+	var body struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Field   string `json:"field"`
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&body)
+
+	apiErr := &Error{Status: resp.StatusCode}
+	if body.Message != "" {
+		apiErr.Messages = append(apiErr.Messages, body.Message)
+	}
+	for _, e := range body.Errors {
+		switch {
+		case e.Message != "":
+			apiErr.Messages = append(apiErr.Messages, e.Message)
+		case e.Field != "":
+			apiErr.Messages = append(apiErr.Messages, e.Field+": "+e.Code)
+		}
+	}
+	return apiErr
+}
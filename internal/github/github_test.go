@@ -0,0 +1,280 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"planning-poker/internal/poker"
+)
+
+// mockGitHub is a stand-in for the parts of the GitHub REST and GraphQL
+// APIs the client uses
+type mockGitHub struct {
+	issues []issue // Of acme/web; milestone 7 is "Sprint 42"
+
+	mu      sync.Mutex
+	labels  map[int][]string // Labels by issue number
+	fields  map[string]float64
+	queries []string // Issue list query strings
+	auth    []string
+}
+
+func newMockGitHub(t *testing.T, m *mockGitHub) *httptest.Server {
+	if m.labels == nil {
+		m.labels = make(map[int][]string)
+	}
+	m.fields = make(map[string]float64)
+
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("GET /repos/acme/web/milestones", func(w http.ResponseWriter, r *http.Request) {
+		m.record(r)
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"number": 7, "title": "Sprint 42"}})
+	})
+	mux.HandleFunc("GET /repos/acme/web/issues", func(w http.ResponseWriter, r *http.Request) {
+		m.record(r)
+		m.mu.Lock()
+		m.queries = append(m.queries, r.URL.RawQuery)
+		m.mu.Unlock()
+
+		size, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		end := min(page*size, len(m.issues))
+		if end < len(m.issues) {
+			next := r.URL.Query()
+			next.Set("page", strconv.Itoa(page+1))
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/acme/web/issues?%s>; rel="next"`, server.URL, next.Encode()))
+		}
+		json.NewEncoder(w).Encode(m.issues[(page-1)*size : end])
+	})
+	mux.HandleFunc("GET /repos/acme/web/issues/{number}/labels", func(w http.ResponseWriter, r *http.Request) {
+		m.record(r)
+		number, _ := strconv.Atoi(r.PathValue("number"))
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		var labels []map[string]string
+		for _, name := range m.labels[number] {
+			labels = append(labels, map[string]string{"name": name})
+		}
+		json.NewEncoder(w).Encode(labels)
+	})
+	mux.HandleFunc("POST /repos/acme/web/issues/{number}/labels", func(w http.ResponseWriter, r *http.Request) {
+		m.record(r)
+		number, _ := strconv.Atoi(r.PathValue("number"))
+		var body struct {
+			Labels []string `json:"labels"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, label := range body.Labels {
+			if !slices.Contains(m.labels[number], label) {
+				m.labels[number] = append(m.labels[number], label)
+			}
+		}
+		json.NewEncoder(w).Encode([]interface{}{})
+	})
+	mux.HandleFunc("DELETE /repos/acme/web/issues/{number}/labels/{name}", func(w http.ResponseWriter, r *http.Request) {
+		m.record(r)
+		number, _ := strconv.Atoi(r.PathValue("number"))
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.labels[number] = slices.DeleteFunc(m.labels[number], func(l string) bool { return l == r.PathValue("name") })
+		json.NewEncoder(w).Encode([]interface{}{})
+	})
+	mux.HandleFunc("POST /graphql", func(w http.ResponseWriter, r *http.Request) {
+		m.record(r)
+		var body struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch {
+		case strings.HasPrefix(body.Query, "query") && body.Variables["number"] == 404.0:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data":   map[string]interface{}{"repository": map[string]interface{}{"issue": nil}},
+				"errors": []map[string]string{{"message": "Could not resolve to an Issue with the number of 404."}},
+			})
+		case strings.HasPrefix(body.Query, "query"):
+			var field interface{}
+			if body.Variables["field"] == "Estimate" {
+				field = map[string]string{"id": "FIELD_1", "dataType": "NUMBER"}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+				"repository": map[string]interface{}{"issue": map[string]interface{}{"projectItems": map[string]interface{}{
+					"nodes": []interface{}{
+						map[string]interface{}{"id": "ITEM_OTHER", "project": map[string]interface{}{"id": "PROJECT_2", "number": 2}},
+						map[string]interface{}{"id": "ITEM_1", "project": map[string]interface{}{"id": "PROJECT_1", "number": 1, "field": field}},
+					},
+				}}},
+			}})
+		default:
+			m.mu.Lock()
+			m.fields[fmt.Sprintf("%s/%s/%s", body.Variables["project"], body.Variables["item"], body.Variables["field"])] = body.Variables["value"].(float64)
+			m.mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+				"updateProjectV2ItemFieldValue": map[string]interface{}{"projectV2Item": map[string]string{"id": "ITEM_1"}},
+			}})
+		}
+	})
+
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (m *mockGitHub) record(r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.auth = append(m.auth, r.Header.Get("Authorization"))
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery(`acme/web milestone:"Sprint 42" label:ready label:"good first issue" state:all`)
+	if err != nil {
+		t.Fatalf("ParseQuery failed: %v", err)
+	}
+	want := Query{Owner: "acme", Repo: "web", Milestone: "Sprint 42", Labels: []string{"ready", "good first issue"}, State: "all"}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("Expected %+v, got %+v", want, q)
+	}
+
+	for _, query := range []string{"", "acme", "acme/web/extra", "acme/web assignee:me", "acme/web state:merged", `acme/web label:"open`, "acme/web label:"} {
+		if _, err := ParseQuery(query); err == nil {
+			t.Errorf("Expected %q to be rejected", query)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	m := &mockGitHub{}
+	for i := 1; i <= 150; i++ {
+		m.issues = append(m.issues, issue{Number: i, Title: fmt.Sprintf("Issue %d", i), HTMLURL: fmt.Sprintf("https://github.com/acme/web/issues/%d", i)})
+	}
+	m.issues[1].PullRequest = &struct{}{}
+	server := newMockGitHub(t, m)
+
+	client := New(Config{APIURL: server.URL, Token: "ghp_test", Repos: []string{"acme/web"}})
+	stories, err := client.Import(context.Background(), `acme/web milestone:"sprint 42" label:ready`)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if len(stories) != 149 {
+		t.Fatalf("Expected 149 issues across pages without the pull request, got %d", len(stories))
+	}
+	want := poker.Story{Key: "acme/web#150", Title: "Issue 150", URL: "https://github.com/acme/web/issues/150", Source: Source}
+	if stories[148] != want {
+		t.Errorf("Expected %+v, got %+v", want, stories[148])
+	}
+	if !strings.Contains(m.queries[0], "milestone=7") || !strings.Contains(m.queries[0], "labels=ready") {
+		t.Errorf("Expected milestone and label filters, got %q", m.queries[0])
+	}
+	if m.auth[0] != "Bearer ghp_test" {
+		t.Errorf("Expected bearer token, got %q", m.auth[0])
+	}
+
+	if _, err := client.Import(context.Background(), "acme/web milestone:Backlog"); err == nil {
+		t.Error("Expected an unknown milestone to fail")
+	}
+}
+
+func TestWriteEstimateLabel(t *testing.T) {
+	m := &mockGitHub{labels: map[int][]string{12: {"bug", "estimate: 3"}}}
+	server := newMockGitHub(t, m)
+	client := New(Config{APIURL: server.URL, Token: "ghp_test", Target: TargetLabel, LabelPrefix: "estimate: ", Repos: []string{"acme/web"}})
+
+	if err := client.WriteEstimate(context.Background(), poker.Story{Key: "acme/web#12", Estimate: "XL"}); err != nil {
+		t.Fatalf("WriteEstimate failed: %v", err)
+	}
+	if labels := m.labels[12]; !reflect.DeepEqual(labels, []string{"bug", "estimate: XL"}) {
+		t.Errorf("Expected the old estimate label to be replaced, got %v", labels)
+	}
+
+	if err := client.WriteEstimate(context.Background(), poker.Story{Key: "PP-1", Estimate: "3"}); err == nil {
+		t.Error("Expected a key that is not owner/repo#number to fail")
+	}
+}
+
+func TestWriteEstimateProjectField(t *testing.T) {
+	m := &mockGitHub{}
+	server := newMockGitHub(t, m)
+	client := New(Config{APIURL: server.URL, Token: "ghp_test", Target: TargetProject, ProjectNumber: 1, ProjectField: "Estimate", Repos: []string{"acme/web"}})
+
+	if err := client.WriteEstimate(context.Background(), poker.Story{Key: "acme/web#12", Estimate: "5"}); err != nil {
+		t.Fatalf("WriteEstimate failed: %v", err)
+	}
+	if value := m.fields["PROJECT_1/ITEM_1/FIELD_1"]; value != 5 {
+		t.Errorf("Expected the Estimate field of the project 1 item to be 5, got %v", m.fields)
+	}
+
+	if err := client.WriteEstimate(context.Background(), poker.Story{Key: "acme/web#12", Estimate: "?"}); !errors.Is(err, ErrNotNumeric) {
+		t.Errorf("Expected %v, got %v", ErrNotNumeric, err)
+	}
+
+	var apiErr *Error
+	if err := client.WriteEstimate(context.Background(), poker.Story{Key: "acme/web#404", Estimate: "5"}); !errors.As(err, &apiErr) {
+		t.Errorf("Expected GraphQL errors to be returned, got %v", err)
+	}
+
+	client = New(Config{APIURL: server.URL, Token: "ghp_test", Target: TargetProject, ProjectNumber: 1, ProjectField: "Size", Repos: []string{"acme/web"}})
+	if err := client.WriteEstimate(context.Background(), poker.Story{Key: "acme/web#12", Estimate: "5"}); err == nil || !strings.Contains(err.Error(), `no number field "Size"`) {
+		t.Errorf("Expected a missing field to be reported, got %v", err)
+	}
+}
+
+func TestReposRestriction(t *testing.T) {
+	m := &mockGitHub{labels: map[int][]string{}}
+	server := newMockGitHub(t, m)
+	client := New(Config{APIURL: server.URL, Token: "ghp_test", Target: TargetLabel, LabelPrefix: "estimate: ", Repos: []string{"Acme/Web"}})
+
+	if _, err := client.Import(context.Background(), "acme/web"); err != nil {
+		t.Errorf("Expected repository names to match regardless of case, got %v", err)
+	}
+	if _, err := client.Import(context.Background(), "acme/secrets"); !errors.Is(err, ErrRepoNotAllowed) {
+		t.Errorf("Expected %v for an import, got %v", ErrRepoNotAllowed, err)
+	}
+	if err := client.WriteEstimate(context.Background(), poker.Story{Key: "acme/secrets#1", Estimate: "5"}); !errors.Is(err, ErrRepoNotAllowed) {
+		t.Errorf("Expected %v for a write, got %v", ErrRepoNotAllowed, err)
+	}
+	if len(m.auth) != 1 {
+		t.Errorf("Expected only the allowed import to reach GitHub, got %d requests", len(m.auth))
+	}
+}
+
+func TestGraphQLURL(t *testing.T) {
+	tests := map[string]string{
+		"https://api.github.com":             "https://api.github.com/graphql",
+		"https://github.example.com/api/v3/": "https://github.example.com/api/graphql",
+	}
+	for apiURL, want := range tests {
+		if got := New(Config{APIURL: apiURL}).graphQLURL(); got != want {
+			t.Errorf("%s: expected %s, got %s", apiURL, want, got)
+		}
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"Bad credentials","documentation_url":"https://docs.github.com/rest"}`))
+	}))
+	defer server.Close()
+
+	_, err := New(Config{APIURL: server.URL, Repos: []string{"acme/web"}}).Import(context.Background(), "acme/web")
+	if err == nil || err.Error() != "github: Unauthorized: Bad credentials" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
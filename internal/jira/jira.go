@@ -0,0 +1,280 @@
+// Package jira imports stories from Jira and writes agreed estimates back
+// to them through the Jira REST API.
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// Source is the poker.Story Source of imported issues
+const Source = "jira"
+
+const (
+	pageSize       = 50
+	maxIssues      = 200      // Per import; the session queue is bounded too
+	maxErrorBody   = 64 << 10 // Bytes of an error response that are read
+	requestTimeout = 30 * time.Second
+)
+
+var (
+	// ErrNotNumeric is returned when an estimate such as "?" cannot be
+	// stored in a story points field
+	ErrNotNumeric = errors.New("estimate is not a number")
+
+	// ErrProjectNotAllowed is returned when writing to an issue outside
+	// the configured projects
+	ErrProjectNotAllowed = errors.New("issue is not in a configured project")
+)
+
+// orderBy finds the ORDER BY clause of a JQL query
+var orderBy = regexp.MustCompile(`(?i)\border\s+by\b`)
+
+// Config configures a Client
+type Config struct {
+	BaseURL          string // https://example.atlassian.net
+	Email            string // Cloud account email; empty for a Data Center personal access token
+	APIToken         string
+	StoryPointsField string // Custom field ID such as customfield_10016
+
+	// Projects are the keys of the projects that imports and write-back
+	// are limited to. Anyone can moderate a session, so the token's own
+	// permissions are not enough.
+	Projects []string
+
+	// HTTPClient is used for requests if set
+	HTTPClient *http.Client
+}
+
+// Client talks to one Jira site
+type Client struct {
+	config Config
+	http   *http.Client
+}
+
+// New returns a client for the Jira site in cfg
+func New(cfg Config) *Client {
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	return &Client{config: cfg, http: client}
+}
+
+// Name returns the story source this client handles
+func (c *Client) Name() string {
+	return Source
+}
+
+// issue is the part of a Jira issue the client reads
+type issue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string `json:"summary"`
+	} `json:"fields"`
+}
+
+// searchResponse covers both the enhanced search of Jira Cloud, paged by
+// token, and the classic search of Jira Data Center, paged by offset
+type searchResponse struct {
+	Issues        []issue `json:"issues"`
+	NextPageToken string  `json:"nextPageToken"`
+	IsLast        bool    `json:"isLast"`
+	StartAt       int     `json:"startAt"`
+	Total         int     `json:"total"`
+}
+
+// Import returns the issues of the configured projects matching a JQL
+// query as stories, in the order of the query, up to a limit of 200
+func (c *Client) Import(ctx context.Context, jql string) ([]poker.Story, error) {
+	jql = c.restrict(jql)
+	issues, err := c.search(ctx, "/rest/api/2/search/jql", jql)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		// Data Center has no enhanced search
+		issues, err = c.search(ctx, "/rest/api/2/search", jql)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stories := make([]poker.Story, 0, len(issues))
+	for _, issue := range issues {
+		// A query can close the parentheses restrict adds around it, so
+		// the results are checked too
+		if !c.allowed(issue.Key) {
+			continue
+		}
+		stories = append(stories, poker.Story{
+			Key:    issue.Key,
+			Title:  issue.Fields.Summary,
+			URL:    c.config.BaseURL + "/browse/" + url.PathEscape(issue.Key),
+			Source: Source,
+		})
+	}
+	return stories, nil
+}
+
+// restrict limits a JQL query to the configured projects, keeping its
+// ORDER BY clause at the end
+func (c *Client) restrict(jql string) string {
+	query, order := jql, ""
+	if matches := orderBy.FindAllStringIndex(jql, -1); len(matches) > 0 {
+		last := matches[len(matches)-1][0]
+		query, order = jql[:last], jql[last:]
+	}
+
+	restricted := "project in (" + strings.Join(c.config.Projects, ", ") + ")"
+	if strings.TrimSpace(query) != "" {
+		restricted += " AND (" + strings.TrimSpace(query) + ")"
+	}
+	if order != "" {
+		restricted += " " + order
+	}
+	return restricted
+}
+
+// allowed reports whether the issue key belongs to a configured project
+func (c *Client) allowed(key string) bool {
+	project, _, found := strings.Cut(key, "-")
+	return found && slices.Contains(c.config.Projects, project)
+}
+
+// search pages through the results of a query
+func (c *Client) search(ctx context.Context, path, jql string) ([]issue, error) {
+	var issues []issue
+	var token string
+	for len(issues) < maxIssues {
+		query := url.Values{
+			"jql":        {jql},
+			"fields":     {"summary"},
+			"maxResults": {strconv.Itoa(pageSize)},
+		}
+		if token != "" {
+			query.Set("nextPageToken", token)
+		} else if len(issues) > 0 {
+			query.Set("startAt", strconv.Itoa(len(issues)))
+		}
+
+		var page searchResponse
+		if err := c.do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		issues = append(issues, page.Issues...)
+
+		token = page.NextPageToken
+		offsetDone := page.StartAt+len(page.Issues) >= page.Total
+		if len(page.Issues) == 0 || page.IsLast || (token == "" && offsetDone) {
+			break
+		}
+	}
+
+	if len(issues) > maxIssues {
+		issues = issues[:maxIssues]
+	}
+	return issues, nil
+}
+
+// WriteEstimate stores the agreed estimate of an imported story in the
+// story points field of its issue
+func (c *Client) WriteEstimate(ctx context.Context, story poker.Story) error {
+	if !c.allowed(story.Key) {
+		return fmt.Errorf("%s: %w", story.Key, ErrProjectNotAllowed)
+	}
+	points, err := strconv.ParseFloat(story.Estimate, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %q", story.Key, ErrNotNumeric, story.Estimate)
+	}
+
+	body := map[string]map[string]float64{
+		"fields": {c.config.StoryPointsField: points},
+	}
+	return c.do(ctx, http.MethodPut, "/rest/api/2/issue/"+url.PathEscape(story.Key), body, nil)
+}
+
+// Error is a non-2xx response from Jira
+type Error struct {
+	Status   int
+	Messages []string
+}
+
+func (e *Error) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("jira: %s", http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("jira: %s: %s", http.StatusText(e.Status), strings.Join(e.Messages, "; "))
+}
+
+// do sends a request with an optional JSON body and decodes the response
+// into out unless it is nil
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.config.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.config.Email != "" {
+		req.SetBasicAuth(c.config.Email, c.config.APIToken)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.config.APIToken)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("jira: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("jira: invalid response: %w", err)
+	}
+	return nil
+}
+
+// responseError reads the messages of a Jira error response
+func responseError(resp *http.Response) error {
+	var body struct {
+		ErrorMessages []string          `json:"errorMessages"`
+		Errors        map[string]string `json:"errors"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&body)
+
+	apiErr := &Error{Status: resp.StatusCode, Messages: body.ErrorMessages}
+	for _, field := range slices.Sorted(maps.Keys(body.Errors)) {
+		apiErr.Messages = append(apiErr.Messages, field+": "+body.Errors[field])
+	}
+	return apiErr
+}
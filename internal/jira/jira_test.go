@@ -0,0 +1,223 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"planning-poker/internal/poker"
+)
+
+// fakeJira is a stand-in for the parts of the Jira REST API the client uses
+type fakeJira struct {
+	issues   []string // Summaries; keys are PP-1, PP-2, ...
+	enhanced bool     // Serve Cloud's enhanced search rather than classic search
+
+	mu      sync.Mutex
+	updates map[string]map[string]interface{} // Fields written by key
+	auth    []string
+	jql     []string
+}
+
+func newFakeJira(t *testing.T, f *fakeJira) *httptest.Server {
+	f.updates = make(map[string]map[string]interface{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /rest/api/2/search/jql", func(w http.ResponseWriter, r *http.Request) {
+		if !f.enhanced {
+			http.NotFound(w, r)
+			return
+		}
+		f.record(r)
+		start, _ := strconv.Atoi(r.URL.Query().Get("nextPageToken"))
+		page, end := f.page(r, start)
+		response := map[string]interface{}{"issues": page, "isLast": end == len(f.issues)}
+		if end < len(f.issues) {
+			response["nextPageToken"] = strconv.Itoa(end)
+		}
+		json.NewEncoder(w).Encode(response)
+	})
+	mux.HandleFunc("GET /rest/api/2/search", func(w http.ResponseWriter, r *http.Request) {
+		f.record(r)
+		start, _ := strconv.Atoi(r.URL.Query().Get("startAt"))
+		page, _ := f.page(r, start)
+		json.NewEncoder(w).Encode(map[string]interface{}{"issues": page, "startAt": start, "total": len(f.issues)})
+	})
+	mux.HandleFunc("PUT /rest/api/2/issue/{key}", func(w http.ResponseWriter, r *http.Request) {
+		f.record(r)
+		var body struct {
+			Fields map[string]interface{} `json:"fields"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body.Fields["customfield_10016"]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"errors": map[string]string{"customfield_10016": "Field is required"},
+			})
+			return
+		}
+		f.mu.Lock()
+		f.updates[r.PathValue("key")] = body.Fields
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (f *fakeJira) record(r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	if jql := r.URL.Query().Get("jql"); jql != "" {
+		f.jql = append(f.jql, jql)
+	}
+}
+
+// page returns the issues from start, at most maxResults of them
+func (f *fakeJira) page(r *http.Request, start int) ([]map[string]interface{}, int) {
+	size, _ := strconv.Atoi(r.URL.Query().Get("maxResults"))
+	end := min(start+size, len(f.issues))
+
+	var page []map[string]interface{}
+	for i := start; i < end; i++ {
+		page = append(page, map[string]interface{}{
+			"key":    fmt.Sprintf("PP-%d", i+1),
+			"fields": map[string]string{"summary": f.issues[i]},
+		})
+	}
+	return page, end
+}
+
+func newClient(url string) *Client {
+	return New(Config{
+		BaseURL:          url + "/",
+		Email:            "bot@example.com",
+		APIToken:         "secret",
+		StoryPointsField: "customfield_10016",
+		Projects:         []string{"PP"},
+	})
+}
+
+func TestImport(t *testing.T) {
+	for _, enhanced := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enhanced=%v", enhanced), func(t *testing.T) {
+			f := &fakeJira{enhanced: enhanced}
+			for i := 0; i < 120; i++ {
+				f.issues = append(f.issues, fmt.Sprintf("Story %d", i+1))
+			}
+			server := newFakeJira(t, f)
+
+			stories, err := newClient(server.URL).Import(context.Background(), "project = PP")
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+
+			if len(stories) != 120 {
+				t.Fatalf("Expected all 120 issues across pages, got %d", len(stories))
+			}
+			want := poker.Story{Key: "PP-120", Title: "Story 120", URL: server.URL + "/browse/PP-120", Source: Source}
+			if stories[119] != want {
+				t.Errorf("Expected %+v, got %+v", want, stories[119])
+			}
+			if f.auth[0] == "" || f.auth[0][:6] != "Basic " {
+				t.Errorf("Expected basic auth with email and API token, got %q", f.auth[0])
+			}
+		})
+	}
+}
+
+func TestImportLimit(t *testing.T) {
+	f := &fakeJira{enhanced: true}
+	for i := 0; i < maxIssues+30; i++ {
+		f.issues = append(f.issues, "Story")
+	}
+	server := newFakeJira(t, f)
+
+	stories, err := newClient(server.URL).Import(context.Background(), "project = PP")
+	if err != nil || len(stories) != maxIssues {
+		t.Errorf("Expected import to stop at %d issues, got %d (%v)", maxIssues, len(stories), err)
+	}
+}
+
+func TestImportRestrictsProjects(t *testing.T) {
+	f := &fakeJira{enhanced: true, issues: []string{"Story"}}
+	server := newFakeJira(t, f)
+	client := newClient(server.URL)
+	client.config.Projects = []string{"PP", "WEB"}
+
+	if _, err := client.Import(context.Background(), "status = Open order by rank"); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if want := "project in (PP, WEB) AND (status = Open) order by rank"; f.jql[0] != want {
+		t.Errorf("Expected query %q, got %q", want, f.jql[0])
+	}
+
+	// Issues of other projects are dropped even if the query escapes the
+	// restriction
+	client.config.Projects = []string{"WEB"}
+	stories, err := client.Import(context.Background(), ") OR (project = PP")
+	if err != nil || len(stories) != 0 {
+		t.Errorf("Expected no stories from other projects, got %+v (%v)", stories, err)
+	}
+}
+
+func TestWriteEstimateRestrictsProjects(t *testing.T) {
+	f := &fakeJira{}
+	server := newFakeJira(t, f)
+
+	err := newClient(server.URL).WriteEstimate(context.Background(), poker.Story{Key: "OPS-1", Estimate: "3"})
+	if !errors.Is(err, ErrProjectNotAllowed) {
+		t.Errorf("Expected %v, got %v", ErrProjectNotAllowed, err)
+	}
+	if len(f.auth) != 0 {
+		t.Errorf("Expected no request to Jira, got %d", len(f.auth))
+	}
+}
+
+func TestWriteEstimate(t *testing.T) {
+	f := &fakeJira{}
+	server := newFakeJira(t, f)
+	client := newClient(server.URL)
+
+	err := client.WriteEstimate(context.Background(), poker.Story{Key: "PP-7", Estimate: "0.5"})
+	if err != nil {
+		t.Fatalf("WriteEstimate failed: %v", err)
+	}
+	if points := f.updates["PP-7"]["customfield_10016"]; points != 0.5 {
+		t.Errorf("Expected story points 0.5, got %v", points)
+	}
+
+	err = client.WriteEstimate(context.Background(), poker.Story{Key: "PP-7", Estimate: "☕"})
+	if !errors.Is(err, ErrNotNumeric) {
+		t.Errorf("Expected %v, got %v", ErrNotNumeric, err)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	f := &fakeJira{}
+	server := newFakeJira(t, f)
+
+	// Jira explains which field it rejected
+	client := New(Config{BaseURL: server.URL, APIToken: "pat", StoryPointsField: "customfield_99999", Projects: []string{"PP"}})
+	err := client.WriteEstimate(context.Background(), poker.Story{Key: "PP-1", Estimate: "3"})
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Fatalf("Expected a 400 Error, got %v", err)
+	}
+	if err.Error() != "jira: Bad Request: customfield_10016: Field is required" {
+		t.Errorf("Unexpected error message %q", err.Error())
+	}
+	if f.auth[0] != "Bearer pat" {
+		t.Errorf("Expected a personal access token without an email, got %q", f.auth[0])
+	}
+}
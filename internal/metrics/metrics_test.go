@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounterExposition(t *testing.T) {
+	c := NewCounter("test_messages_total", "Messages.\nBy type.", "type")
+	c.Inc("vote")
+	c.Add(2, "vote")
+	c.Inc(`say "hi"`)
+
+	var buf bytes.Buffer
+	c.write(&buf)
+
+	expected := `# HELP test_messages_total Messages.\nBy type.
+# TYPE test_messages_total counter
+test_messages_total{type="say \"hi\""} 1
+test_messages_total{type="vote"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected exposition:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+	if c.Value("vote") != 3 {
+		t.Errorf("Expected value 3, got %v", c.Value("vote"))
+	}
+}
+
+func TestGaugeExposition(t *testing.T) {
+	g := NewGauge("test_connections", "Connections.")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+
+	var buf bytes.Buffer
+	g.write(&buf)
+
+	if !strings.Contains(buf.String(), "\ntest_connections 1\n") {
+		t.Errorf("Unexpected exposition:\n%s", buf.String())
+	}
+
+	// A gauge in its own registry is written there and not with Default
+	r := NewRegistry()
+	NewGaugeIn(r, "test_server_connections", "Connections of one server.").Inc()
+	buf.Reset()
+	r.Write(&buf)
+	if !strings.Contains(buf.String(), "\ntest_server_connections 1\n") {
+		t.Errorf("Unexpected exposition:\n%s", buf.String())
+	}
+	buf.Reset()
+	Default.Write(&buf)
+	if strings.Contains(buf.String(), "test_server_connections") {
+		t.Error("Expected the gauge not to be registered with Default")
+	}
+}
+
+func TestHistogramExposition(t *testing.T) {
+	h := NewHistogram("test_duration_seconds", "Durations.", []float64{0.1, 1}, "handler")
+	h.Observe(0.05, "api")
+	h.Observe(0.5, "api")
+	h.Observe(5, "api")
+
+	var buf bytes.Buffer
+	h.write(&buf)
+
+	for _, line := range []string{
+		`test_duration_seconds_bucket{handler="api",le="0.1"} 1`,
+		`test_duration_seconds_bucket{handler="api",le="1"} 2`,
+		`test_duration_seconds_bucket{handler="api",le="+Inf"} 3`,
+		`test_duration_seconds_sum{handler="api"} 5.55`,
+		`test_duration_seconds_count{handler="api"} 3`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestRegistryWritesInNameOrder(t *testing.T) {
+	r := NewRegistry()
+	NewGaugeFunc(r, "test_b", "B.", func() float64 { return 2 })
+	NewGaugeFunc(r, "test_a", "A.", func() float64 { return 1 })
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+
+	if strings.Index(buf.String(), "test_a 1") > strings.Index(buf.String(), "test_b 2") {
+		t.Errorf("Expected metrics in name order:\n%s", buf.String())
+	}
+}
+
+func TestDuplicateMetricPanics(t *testing.T) {
+	r := NewRegistry()
+	NewGaugeFunc(r, "test_dup", "Dup.", func() float64 { return 0 })
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected duplicate registration to panic")
+		}
+	}()
+	NewGaugeFunc(r, "test_dup", "Dup.", func() float64 { return 0 })
+}
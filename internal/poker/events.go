@@ -0,0 +1,188 @@
+package poker
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// EventType names a session event that automation can react to
+type EventType string
+
+const (
+	EventSessionStarted EventType = "session_started"
+	EventVotesRevealed  EventType = "votes_revealed"
+	EventEstimateSet    EventType = "estimate_set"
+	EventSessionEnded   EventType = "session_ended"
+)
+
+// EventTypes lists every event type
+var EventTypes = []EventType{EventSessionStarted, EventVotesRevealed, EventEstimateSet, EventSessionEnded}
+
+// maxWebhooks bounds the webhooks of a session
+const maxWebhooks = 10
+
+// Event describes something that happened in a session. Only the fields
+// relevant to the type are set.
+type Event struct {
+	Type      EventType   `json:"type"`
+	SessionID string      `json:"sessionId"`
+	Time      time.Time   `json:"time"`
+	Story     *Story      `json:"story,omitempty"`     // votes_revealed and estimate_set
+	Votes     []EventVote `json:"votes,omitempty"`     // votes_revealed
+	Estimated []Story     `json:"estimated,omitempty"` // session_ended
+
+	// Webhooks are the session's webhooks when the event happened
+	Webhooks []Webhook `json:"-"`
+}
+
+// EventVote is one participant's vote in a votes_revealed event
+type EventVote struct {
+	UserID string  `json:"userId"`
+	Name   string  `json:"name"`
+	Vote   *string `json:"vote"` // Null if the participant did not vote
+}
+
+// EventHandler is called when an event happens, on the instance that
+// received the message causing it only, so that each event is handled once.
+// It is called with the session lock held, in the order of the events, and
+// must not block or call back into the session.
+type EventHandler func(event Event)
+
+// OnEvent sets the handler called for session events
+func (s *Session) OnEvent(handler EventHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onEvent = handler
+}
+
+// emitUnsafe passes an event to the handler if this replica received the
+// message that caused it
+func (s *Session) emitUnsafe(local bool, event Event) {
+	if !local || s.onEvent == nil {
+		return
+	}
+
+	event.SessionID = s.ID
+	event.Time = time.Now()
+	event.Webhooks = slices.Clone(s.Webhooks)
+	s.onEvent(event)
+}
+
+// revealedEventUnsafe builds the votes_revealed event
+func (s *Session) revealedEventUnsafe() Event {
+	event := Event{Type: EventVotesRevealed, Story: s.currentStoryUnsafe()}
+	for _, user := range s.Users {
+		var vote *string
+		if user.Vote != nil {
+			v := *user.Vote
+			vote = &v
+		}
+		event.Votes = append(event.Votes, EventVote{UserID: user.ID, Name: user.Name, Vote: vote})
+	}
+	slices.SortFunc(event.Votes, func(a, b EventVote) int {
+		if a.Name != b.Name {
+			return cmp.Compare(a.Name, b.Name)
+		}
+		return cmp.Compare(a.UserID, b.UserID)
+	})
+	return event
+}
+
+// currentStoryUnsafe returns the story being estimated, or nil if none is
+func (s *Session) currentStoryUnsafe() *Story {
+	if s.ActiveStory != nil {
+		return cloneStory(s.ActiveStory)
+	}
+	if s.CurrentStory != "" {
+		return &Story{Title: s.CurrentStory}
+	}
+	return nil
+}
+
+// Webhook is an endpoint that session events are posted to
+type Webhook struct {
+	ID     string      `json:"id"`
+	URL    string      `json:"url"`
+	Secret string      `json:"secret,omitempty"` // Signs payloads when set
+	Events []EventType `json:"events,omitempty"` // Every event when empty
+}
+
+// Wants reports whether the webhook subscribes to events of type t
+func (w Webhook) Wants(t EventType) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, t)
+}
+
+// removeWebhookData is the data of a remove_webhook message
+type removeWebhookData struct {
+	ID string `json:"id"`
+}
+
+// checkWebhookMessageUnsafe validates the webhook messages, which only the
+// moderator may send
+func (s *Session) checkWebhookMessageUnsafe(user *User, msg Message) error {
+	if !user.IsModerator {
+		s.logger().Warn("Non-moderator attempted to change webhooks", "user_id", user.ID, "user", user.Name)
+		return ErrNotModerator
+	}
+
+	switch msg.Type {
+	case MessageTypeAddWebhook:
+		var hook Webhook
+		if err := json.Unmarshal(msg.Data, &hook); err != nil || hook.ID == "" || !ValidWebhookURL(hook.URL) {
+			return ErrInvalidData
+		}
+		for _, t := range hook.Events {
+			if !slices.Contains(EventTypes, t) {
+				return ErrInvalidData
+			}
+		}
+		if len(s.Webhooks) >= maxWebhooks || slices.ContainsFunc(s.Webhooks, func(w Webhook) bool { return w.ID == hook.ID }) {
+			return ErrInvalidData
+		}
+
+	case MessageTypeRemoveWebhook:
+		var data removeWebhookData
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			return ErrInvalidData
+		}
+		if !slices.ContainsFunc(s.Webhooks, func(w Webhook) bool { return w.ID == data.ID }) {
+			return ErrWebhookNotFound
+		}
+	}
+
+	return nil
+}
+
+// handleWebhookMessageUnsafe applies a checked webhook message. Webhooks
+// are not part of the state participants see, so the version is unchanged.
+func (s *Session) handleWebhookMessageUnsafe(msg Message) {
+	switch msg.Type {
+	case MessageTypeAddWebhook:
+		var hook Webhook
+		json.Unmarshal(msg.Data, &hook)
+		s.Webhooks = append(s.Webhooks, hook)
+		s.logger().Info("Webhook added", "webhook_id", hook.ID)
+
+	case MessageTypeRemoveWebhook:
+		var data removeWebhookData
+		json.Unmarshal(msg.Data, &data)
+		s.Webhooks = slices.DeleteFunc(s.Webhooks, func(w Webhook) bool { return w.ID == data.ID })
+		s.logger().Info("Webhook removed", "webhook_id", data.ID)
+	}
+}
+
+// GetWebhooks returns the session's webhooks
+func (s *Session) GetWebhooks() []Webhook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.Webhooks)
+}
+
+// ValidWebhookURL reports whether u is an absolute http or https URL
+func ValidWebhookURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
@@ -0,0 +1,138 @@
+package poker
+
+import (
+	"testing"
+
+	"planning-poker/internal/bus"
+)
+
+func recordEvents(s *Session) *[]Event {
+	var events []Event
+	s.OnEvent(func(event Event) {
+		events = append(events, event)
+	})
+	return &events
+}
+
+func TestSessionEvents(t *testing.T) {
+	session := NewSession("TEST123")
+	creator := session.AddUser("Alice", nil, true)
+	session.SetCreator(creator.ID)
+	participant := session.AddUser("Bob", nil, false)
+	events := recordEvents(session)
+
+	session.StartSession(creator.ID)
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeSetStory, Data: mustMarshal(map[string]string{"story": "Login page"})})
+	session.HandleMessage(participant.ID, Message{Type: MessageTypeVote, Data: mustMarshal(map[string]string{"vote": "5"})})
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeReveal})
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeSetEstimate, Data: mustMarshal(map[string]string{"estimate": "5"})})
+	if err := session.HandleMessage(participant.ID, Message{Type: MessageTypeEndSession}); err != ErrNotModerator {
+		t.Errorf("Expected participant end_session to fail with %v, got %v", ErrNotModerator, err)
+	}
+	if err := session.HandleMessage(creator.ID, Message{Type: MessageTypeEndSession}); err != nil {
+		t.Fatalf("end_session failed: %v", err)
+	}
+
+	var types []EventType
+	for _, event := range *events {
+		types = append(types, event.Type)
+		if event.SessionID != "TEST123" || event.Time.IsZero() {
+			t.Errorf("Expected %s to carry the session and time, got %+v", event.Type, event)
+		}
+	}
+	want := []EventType{EventSessionStarted, EventVotesRevealed, EventEstimateSet, EventSessionEnded}
+	if len(types) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, types)
+		}
+	}
+
+	revealed := (*events)[1]
+	if revealed.Story == nil || revealed.Story.Title != "Login page" || len(revealed.Votes) != 2 {
+		t.Fatalf("Unexpected votes_revealed event %+v", revealed)
+	}
+	if revealed.Votes[0].Name != "Alice" || revealed.Votes[0].Vote != nil || *revealed.Votes[1].Vote != "5" {
+		t.Errorf("Expected votes sorted by name with Alice not voting, got %+v", revealed.Votes)
+	}
+
+	ended := (*events)[3]
+	if len(ended.Estimated) != 1 || ended.Estimated[0].Estimate != "5" {
+		t.Errorf("Expected session_ended to list the estimated stories, got %+v", ended.Estimated)
+	}
+
+	state := session.GetState()
+	if state.Status != SessionStatusEnded {
+		t.Errorf("Expected session to be ended, got %s", state.Status)
+	}
+	if err := session.HandleMessage(participant.ID, Message{Type: MessageTypeVote, Data: mustMarshal(map[string]string{"vote": "3"})}); err != ErrSessionEnded {
+		t.Errorf("Expected vote after end to fail with %v, got %v", ErrSessionEnded, err)
+	}
+	if err := session.HandleMessage(participant.ID, Message{Type: MessageTypeSync}); err != nil {
+		t.Errorf("Expected sync after end to succeed, got %v", err)
+	}
+}
+
+func TestWebhookMessages(t *testing.T) {
+	session := NewSession("TEST123")
+	creator := session.AddUser("Alice", nil, true)
+	participant := session.AddUser("Bob", nil, false)
+	events := recordEvents(session)
+
+	hook := Webhook{ID: "hook-1", URL: "https://ci.example.com/poker", Secret: "s3cret", Events: []EventType{EventEstimateSet}}
+	add := Message{Type: MessageTypeAddWebhook, Data: mustMarshal(hook)}
+	version := session.GetState().Version
+
+	tests := []struct {
+		name   string
+		userID string
+		msg    Message
+		want   error
+	}{
+		{"participant add", participant.ID, add, ErrNotModerator},
+		{"bad url", creator.ID, Message{Type: MessageTypeAddWebhook, Data: []byte(`{"id":"x","url":"ftp://example.com"}`)}, ErrInvalidData},
+		{"unknown event", creator.ID, Message{Type: MessageTypeAddWebhook, Data: []byte(`{"id":"x","url":"https://example.com","events":["vote"]}`)}, ErrInvalidData},
+		{"add", creator.ID, add, nil},
+		{"duplicate id", creator.ID, add, ErrInvalidData},
+		{"remove unknown", creator.ID, Message{Type: MessageTypeRemoveWebhook, Data: []byte(`{"id":"nope"}`)}, ErrWebhookNotFound},
+	}
+	for _, tt := range tests {
+		if err := session.HandleMessage(tt.userID, tt.msg); err != tt.want {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	if got := session.GetState().Version; got != version {
+		t.Errorf("Expected webhook changes not to bump the version %d, got %d", version, got)
+	}
+
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeReveal})
+	if got := (*events)[0].Webhooks; len(got) != 1 || got[0].ID != "hook-1" || got[0].Wants(EventVotesRevealed) {
+		t.Errorf("Expected the event to carry the estimate_set-only webhook, got %+v", got)
+	}
+
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeRemoveWebhook, Data: []byte(`{"id":"hook-1"}`)})
+	if hooks := session.GetWebhooks(); len(hooks) != 0 {
+		t.Errorf("Expected webhook to be removed, got %+v", hooks)
+	}
+}
+
+func TestWebhooksReplicate(t *testing.T) {
+	b := bus.NewLocal()
+	instanceA := newReplica(t, "SHARED", b)
+	creator := instanceA.AddUser("Alice", nil, true)
+
+	hook := Webhook{ID: "hook-1", URL: "https://ci.example.com/poker", Secret: "s3cret"}
+	if err := instanceA.HandleMessage(creator.ID, Message{Type: MessageTypeAddWebhook, Data: mustMarshal(hook)}); err != nil {
+		t.Fatalf("add_webhook failed: %v", err)
+	}
+	eventually(t, "webhook added", func() bool { return len(instanceA.GetWebhooks()) == 1 })
+
+	// A replica joining later gets the webhook from the snapshot
+	instanceB := newReplica(t, "SHARED", b)
+	if hooks := instanceB.GetWebhooks(); len(hooks) != 1 || hooks[0].Secret != "s3cret" {
+		t.Errorf("Expected B to load the webhook from the snapshot, got %+v", hooks)
+	}
+}
@@ -0,0 +1,26 @@
+package poker
+
+import "planning-poker/internal/metrics"
+
+var (
+	messagesReceived = metrics.NewCounter("planning_poker_messages_received_total",
+		"Client messages received by this instance, by message type.", "type")
+	messagesSent = metrics.NewCounter("planning_poker_messages_sent_total",
+		"Messages sent to participants connected to this instance, by message type.", "type")
+	droppedSends = metrics.NewCounter("planning_poker_dropped_sends_total",
+		"Messages that could not be sent to a participant, who was then marked offline.")
+	broadcastDuration = metrics.NewHistogram("planning_poker_broadcast_duration_seconds",
+		"Time taken to send one message to every participant of a session.", metrics.DefaultBuckets)
+)
+
+// messageTypeLabel bounds the label values clients can create
+func messageTypeLabel(t MessageType) string {
+	switch t {
+	case MessageTypeVote, MessageTypeReveal, MessageTypeNewRound, MessageTypeSetStory,
+		MessageTypeStartSession, MessageTypeSync, MessageTypeAddStories, MessageTypeNextStory,
+		MessageTypeSetEstimate, MessageTypeEndSession, MessageTypeAddWebhook, MessageTypeRemoveWebhook:
+		return string(t)
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,377 @@
+package poker
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"planning-poker/internal/bus"
+
+	"github.com/google/uuid"
+)
+
+// A session shared through a bus is replicated: every server instance that
+// has participants in it keeps its own copy of the Session, and every change
+// is published as a sessionEvent instead of being applied directly. Each
+// replica applies events in the order the bus delivers them, so all copies
+// reach the same state and version, and each replica only writes to the
+// connections of its own users.
+//
+// A replica that joins late publishes a sync request and buffers the events
+// that follow it. An existing replica answers with a snapshot taken at the
+// point of the request, and the new replica loads it and replays the buffer.
+// Unless the bus can count subscribers exactly, silence is the only sign
+// that there is no other replica, so the new replica waits for syncTimeout.
+// A replica whose bus lost events, such as while its connection to Redis was
+// down, syncs the same way.
+
+const (
+	// syncTimeout bounds how long a replica waits for a snapshot before it
+	// assumes there is no existing state, or keeps its own
+	syncTimeout = 2 * time.Second
+
+	// joinTimeout bounds how long AddUser waits for the join to be applied
+	joinTimeout = 5 * time.Second
+)
+
+type eventKind string
+
+const (
+	eventJoin        eventKind = "join"
+	eventLeave       eventKind = "leave"
+	eventMessage     eventKind = "message"
+	eventImport      eventKind = "import" // Stories imported by the server
+	eventSyncRequest eventKind = "sync_request"
+	eventSnapshot    eventKind = "snapshot"
+)
+
+// sessionEvent is the payload published on a session's bus topic
+type sessionEvent struct {
+	Kind      eventKind       `json:"kind"`
+	Replica   string          `json:"replica"`
+	UserID    string          `json:"userId,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	IsCreator bool            `json:"isCreator,omitempty"`
+	Message   *Message        `json:"message,omitempty"`
+	Target    string          `json:"target,omitempty"`   // Replica a snapshot answers
+	Snapshot  json.RawMessage `json:"snapshot,omitempty"` // Full Session including votes
+}
+
+// replica holds the bus state of a replicated session. Its fields other
+// than the bus and id are guarded by the session mutex.
+type replica struct {
+	bus         bus.Bus
+	id          string
+	unsubscribe func()
+	ready       bool                    // State has been loaded
+	syncing     bool                    // Own sync request seen, buffering events
+	syncs       int                     // Sync requests made, to match timeouts to them
+	buffered    []sessionEvent          // Events received while syncing
+	pending     map[string]*pendingJoin // Local users whose join is in flight
+	readyOnce   sync.Once
+	readyCh     chan struct{}
+}
+
+// pendingJoin is a local user whose join event has not been applied yet
+type pendingJoin struct {
+	user    *User
+	applied chan struct{} // Closed once the user is in the session or gone
+}
+
+// TopicForSession returns the bus topic carrying events for a session
+func TopicForSession(sessionID string) string {
+	return "planning-poker:session:" + sessionID
+}
+
+// NewSessionWithBus creates a session replica that shares its state with
+// every other replica subscribed to the same bus topic. It returns once the
+// replica has loaded any existing state.
+func NewSessionWithBus(id string, b bus.Bus) (*Session, error) {
+	s := NewSession(id)
+	s.replica = &replica{
+		bus:     b,
+		id:      uuid.New().String(),
+		pending: make(map[string]*pendingJoin),
+		readyCh: make(chan struct{}),
+	}
+
+	unsubscribe, err := b.Subscribe(TopicForSession(id), s.handleEvent)
+	if err != nil {
+		return nil, err
+	}
+	s.replica.unsubscribe = unsubscribe
+
+	if err := s.publishEvent(sessionEvent{Kind: eventSyncRequest}); err != nil {
+		unsubscribe()
+		return nil, err
+	}
+
+	// Nobody but ourselves is subscribed, so there is no state to load
+	if s.alone() {
+		s.mu.Lock()
+		s.finishSyncUnsafe(nil)
+		s.mu.Unlock()
+	}
+
+	select {
+	case <-s.replica.readyCh:
+	case <-time.After(syncTimeout):
+		s.logger().Warn("No snapshot received, starting with empty state")
+		s.mu.Lock()
+		s.finishSyncUnsafe(nil)
+		s.mu.Unlock()
+	}
+
+	return s, nil
+}
+
+// Close stops receiving events for a replicated session
+func (s *Session) Close() {
+	if s.replica != nil {
+		s.replica.unsubscribe()
+	}
+}
+
+// publishJoin registers a local user, announces it to all replicas and
+// waits until the join has been applied here, so that the user's messages
+// are accepted as soon as AddUser returns
+func (s *Session) publishJoin(user *User, isCreator bool) {
+	join := &pendingJoin{user: user, applied: make(chan struct{})}
+	s.mu.Lock()
+	s.replica.pending[user.ID] = join
+	s.mu.Unlock()
+
+	err := s.publishEvent(sessionEvent{
+		Kind:      eventJoin,
+		UserID:    user.ID,
+		Name:      user.Name,
+		IsCreator: isCreator,
+	})
+	if err != nil {
+		s.logger().Error("Failed to publish event", "event", eventJoin, "user_id", user.ID, "error", err)
+		s.mu.Lock()
+		s.replica.dropPendingUnsafe(user.ID)
+		s.mu.Unlock()
+		return
+	}
+
+	select {
+	case <-join.applied:
+	case <-time.After(joinTimeout):
+		s.logger().Warn("Join not applied in time", "user_id", user.ID, "timeout", joinTimeout)
+	}
+}
+
+// dropPendingUnsafe forgets a pending join and releases its waiter
+func (r *replica) dropPendingUnsafe(userID string) *User {
+	join, exists := r.pending[userID]
+	if !exists {
+		return nil
+	}
+	delete(r.pending, userID)
+	close(join.applied)
+	return join.user
+}
+
+// publish sends an event to all replicas, logging failures
+func (s *Session) publish(event sessionEvent) {
+	if err := s.publishEvent(event); err != nil {
+		s.logger().Error("Failed to publish event", "event", event.Kind, "user_id", event.UserID, "error", err)
+	}
+}
+
+func (s *Session) publishEvent(event sessionEvent) error {
+	event.Replica = s.replica.id
+	return s.replica.bus.Publish(TopicForSession(s.ID), mustMarshal(event))
+}
+
+// alone reports whether this is known to be the only replica. Only buses
+// that can count subscribers exactly can tell; over others, such as Redis,
+// a replica waits for a snapshot until syncTimeout instead.
+func (s *Session) alone() bool {
+	counter, ok := s.replica.bus.(bus.Counter)
+	return ok && counter.Subscribers(TopicForSession(s.ID)) <= 1
+}
+
+// handleEvent is the bus handler for the session topic
+func (s *Session) handleEvent(payload []byte) {
+	if payload == nil {
+		s.logger().Warn("Bus events may have been lost, syncing")
+		s.resync()
+		return
+	}
+
+	var event sessionEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		s.logger().Warn("Invalid bus event", "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	r := s.replica
+
+	var snapshot json.RawMessage
+	switch {
+	case event.Kind == eventSyncRequest && event.Replica == r.id:
+		// Everything after our own request is not covered by the snapshot
+		if !r.ready {
+			r.syncing = true
+		}
+
+	case event.Kind == eventSyncRequest:
+		if r.ready {
+			snapshot = mustMarshal(s)
+		}
+
+	case event.Kind == eventSnapshot:
+		if !r.ready && event.Target == r.id {
+			s.finishSyncUnsafe(event.Snapshot)
+		}
+
+	case r.ready:
+		s.applyEventUnsafe(event)
+
+	case r.syncing:
+		r.buffered = append(r.buffered, event)
+	}
+	s.mu.Unlock()
+
+	// Publish outside the lock; the snapshot already reflects this position
+	// in the stream because events are handled one at a time
+	if snapshot != nil {
+		s.publish(sessionEvent{
+			Kind:     eventSnapshot,
+			Target:   event.Replica,
+			Snapshot: snapshot,
+		})
+	}
+}
+
+// resync replaces the state of a replica that may have missed events with
+// a snapshot from another replica. Events received until its own sync
+// request comes back are covered by the snapshot and dropped. Without an
+// answer the replica keeps its state.
+func (s *Session) resync() {
+	s.mu.Lock()
+	r := s.replica
+	r.ready = false
+	r.syncing = false
+	r.buffered = nil
+	r.syncs++
+	attempt := r.syncs
+	s.mu.Unlock()
+
+	err := s.publishEvent(sessionEvent{Kind: eventSyncRequest})
+	if err != nil {
+		s.logger().Error("Failed to publish event", "event", eventSyncRequest, "error", err)
+	}
+	if err != nil || s.alone() {
+		s.mu.Lock()
+		s.finishSyncUnsafe(nil)
+		s.mu.Unlock()
+		return
+	}
+
+	time.AfterFunc(syncTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.syncs == attempt && !r.ready {
+			s.logger().Warn("No snapshot received, keeping local state")
+			s.finishSyncUnsafe(nil)
+		}
+	})
+}
+
+// finishSyncUnsafe loads an optional snapshot, replays buffered events and
+// marks the replica ready
+func (s *Session) finishSyncUnsafe(snapshot json.RawMessage) {
+	r := s.replica
+	if r.ready {
+		return
+	}
+
+	if snapshot != nil {
+		var state Session
+		if err := json.Unmarshal(snapshot, &state); err != nil {
+			s.logger().Warn("Invalid snapshot", "error", err)
+		} else {
+			if state.Users == nil {
+				state.Users = make(map[string]*User)
+			}
+			s.keepLocalUsersUnsafe(state.Users)
+			s.Users = state.Users
+			s.CurrentStory = state.CurrentStory
+			s.VotesRevealed = state.VotesRevealed
+			s.ModeratorID = state.ModeratorID
+			s.CreatorID = state.CreatorID
+			s.Status = state.Status
+			s.CreatedAt = state.CreatedAt
+			s.Version = state.Version
+			s.Queue = state.Queue
+			s.ActiveStory = state.ActiveStory
+			s.Estimated = state.Estimated
+			s.Webhooks = state.Webhooks
+		}
+	}
+
+	r.ready = true
+	r.syncing = false
+	for _, event := range r.buffered {
+		s.applyEventUnsafe(event)
+	}
+	r.buffered = nil
+
+	r.readyOnce.Do(func() { close(r.readyCh) })
+}
+
+// keepLocalUsersUnsafe moves the connections of local users, and of pending
+// joins the snapshot already covers, to the users of a snapshot. Local
+// users missing from it are disconnected, so that their clients join again.
+func (s *Session) keepLocalUsersUnsafe(users map[string]*User) {
+	for id, user := range users {
+		if local, exists := s.Users[id]; exists && local.conn != nil {
+			user.conn = local.conn
+		} else if pending := s.replica.dropPendingUnsafe(id); pending != nil {
+			user.conn = pending.conn
+		}
+	}
+
+	for id, local := range s.Users {
+		if _, exists := users[id]; !exists && local.conn != nil {
+			s.logger().Warn("Local user missing from snapshot, disconnecting", "user_id", id, "user", local.Name)
+			local.conn.Close()
+		}
+	}
+}
+
+// applyEventUnsafe applies a replicated change to the local copy
+func (s *Session) applyEventUnsafe(event sessionEvent) {
+	switch event.Kind {
+	case eventJoin:
+		user := s.replica.dropPendingUnsafe(event.UserID)
+		if user == nil {
+			// Connected to another instance; nothing to send locally
+			user = &User{
+				ID:          event.UserID,
+				Name:        event.Name,
+				IsOnline:    true,
+				IsModerator: event.IsCreator,
+			}
+		}
+		s.addUserUnsafe(user, event.IsCreator)
+
+	case eventLeave:
+		s.replica.dropPendingUnsafe(event.UserID)
+		s.removeUserUnsafe(event.UserID)
+
+	case eventMessage:
+		if event.Message != nil {
+			s.handleMessageUnsafe(event.UserID, *event.Message, event.Replica == s.replica.id)
+		}
+
+	case eventImport:
+		if event.Message != nil {
+			s.importStoriesUnsafe(event.UserID, *event.Message)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package poker
+
+import (
+	"testing"
+	"time"
+
+	"planning-poker/internal/bus"
+	"planning-poker/internal/bus/bustest"
+)
+
+func newReplica(t *testing.T, id string, b bus.Bus) *Session {
+	t.Helper()
+
+	session, err := NewSessionWithBus(id, b)
+	if err != nil {
+		t.Fatalf("Failed to create replica: %v", err)
+	}
+	t.Cleanup(session.Close)
+	return session
+}
+
+// eventually polls cond until it holds or the deadline passes
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func voteOf(s *Session, userID string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if user, exists := s.Users[userID]; exists && user.Vote != nil {
+		return *user.Vote
+	}
+	return ""
+}
+
+func userCount(s *Session) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.Users)
+}
+
+func TestReplicatedSessionOnLocalBus(t *testing.T) {
+	b := bus.NewLocal()
+
+	instanceA := newReplica(t, "SHARED", b)
+	creator := instanceA.AddUser("Alice", nil, true)
+
+	// A replica created later loads the existing state
+	instanceB := newReplica(t, "SHARED", b)
+	if len(instanceB.Users) != 1 || instanceB.CreatorID != creator.ID {
+		t.Fatalf("Expected late replica to load creator from snapshot, got %d users", len(instanceB.Users))
+	}
+
+	participant := instanceB.AddUser("Bob", nil, false)
+	if len(instanceA.Users) != 2 {
+		t.Errorf("Expected join on B to reach A, got %d users", len(instanceA.Users))
+	}
+
+	if !instanceA.StartSession(creator.ID) {
+		t.Fatal("Creator should be able to start session")
+	}
+	if instanceB.Status != SessionStatusActive {
+		t.Errorf("Expected B to see active session, got %s", instanceB.Status)
+	}
+
+	instanceB.HandleMessage(participant.ID, Message{
+		Type: MessageTypeVote,
+		Data: mustMarshal(map[string]string{"vote": "8"}),
+	})
+	if got := voteOf(instanceA, participant.ID); got != "8" {
+		t.Errorf("Expected A to see Bob's vote '8', got %q", got)
+	}
+
+	instanceB.RemoveUser(participant.ID)
+	if len(instanceA.Users) != 1 {
+		t.Errorf("Expected leave on B to reach A, got %d users", len(instanceA.Users))
+	}
+
+	if instanceA.Version != instanceB.Version {
+		t.Errorf("Expected replicas to share version, got %d and %d", instanceA.Version, instanceB.Version)
+	}
+}
+
+func TestReplicatedSessionOnRedis(t *testing.T) {
+	srv := bustest.NewRedisServer(t)
+
+	connect := func() bus.Bus {
+		b, err := bus.NewRedis(bus.RedisOptions{Addr: srv.Addr})
+		if err != nil {
+			t.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		t.Cleanup(func() { b.Close() })
+		return b
+	}
+
+	instanceA := newReplica(t, "SHARED", connect())
+	creator := instanceA.AddUser("Alice", nil, true)
+
+	// AddUser returns once the join has been applied, so the user's first
+	// message is accepted
+	vote := Message{Type: MessageTypeVote, Data: mustMarshal(map[string]string{"vote": "3"})}
+	if err := instanceA.HandleMessage(creator.ID, vote); err != nil {
+		t.Fatalf("Expected vote right after joining to succeed, got %v", err)
+	}
+	eventually(t, "creator vote", func() bool { return voteOf(instanceA, creator.ID) == "3" })
+
+	// The second instance has its own Redis connection
+	instanceB := newReplica(t, "SHARED", connect())
+	if got := voteOf(instanceB, creator.ID); got != "3" {
+		t.Errorf("Expected snapshot to carry creator vote '3', got %q", got)
+	}
+
+	participant := instanceB.AddUser("Bob", nil, false)
+	eventually(t, "participant on A", func() bool {
+		instanceA.mu.RLock()
+		defer instanceA.mu.RUnlock()
+		_, exists := instanceA.Users[participant.ID]
+		return exists
+	})
+
+	instanceA.HandleMessage(creator.ID, Message{Type: MessageTypeReveal})
+	revealed := func(s *Session) bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.VotesRevealed
+	}
+	eventually(t, "reveal on both replicas", func() bool {
+		return revealed(instanceA) && revealed(instanceB)
+	})
+
+	instanceA.mu.RLock()
+	instanceB.mu.RLock()
+	defer instanceA.mu.RUnlock()
+	defer instanceB.mu.RUnlock()
+	if instanceA.Version != instanceB.Version {
+		t.Errorf("Expected replicas to share version, got %d and %d", instanceA.Version, instanceB.Version)
+	}
+}
+
+func TestReplicaResyncsAfterLostEvents(t *testing.T) {
+	srv := bustest.NewRedisServer(t)
+	connect := func() bus.Bus {
+		b, err := bus.NewRedis(bus.RedisOptions{Addr: srv.Addr})
+		if err != nil {
+			t.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		t.Cleanup(func() { b.Close() })
+		return b
+	}
+
+	instanceA := newReplica(t, "SHARED", connect())
+	instanceB := newReplica(t, "SHARED", connect())
+	creator := instanceA.AddUser("Alice", nil, true)
+	conn := &fakeConnection{}
+	participant := instanceB.AddUser("Bob", conn, false)
+	eventually(t, "participant on A", func() bool { return userCount(instanceA) == 2 })
+
+	// A vote that only A applies stands in for one B missed while its
+	// subscription was down
+	vote := Message{Type: MessageTypeVote, Data: mustMarshal(map[string]string{"vote": "5"})}
+	instanceA.mu.Lock()
+	instanceA.handleMessageUnsafe(creator.ID, vote, true)
+	instanceA.mu.Unlock()
+
+	// The bus tells B about the gap once it has resubscribed
+	instanceB.handleEvent(nil)
+	eventually(t, "B to load the missed vote", func() bool { return voteOf(instanceB, creator.ID) == "5" })
+
+	instanceB.mu.RLock()
+	kept := instanceB.Users[participant.ID] != nil && instanceB.Users[participant.ID].conn == conn
+	instanceB.mu.RUnlock()
+	if !kept {
+		t.Error("Expected B to keep the connection of its local participant")
+	}
+
+	instanceA.mu.RLock()
+	instanceB.mu.RLock()
+	defer instanceA.mu.RUnlock()
+	defer instanceB.mu.RUnlock()
+	if instanceA.Version != instanceB.Version {
+		t.Errorf("Expected replicas to share version, got %d and %d", instanceA.Version, instanceB.Version)
+	}
+}
@@ -2,26 +2,38 @@ package poker
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
 )
 
 type MessageType string
 
 const (
-	MessageTypeVote         MessageType = "vote"
-	MessageTypeReveal       MessageType = "reveal"
-	MessageTypeNewRound     MessageType = "new_round"
-	MessageTypeSetStory     MessageType = "set_story"
-	MessageTypeUserJoined   MessageType = "user_joined"
-	MessageTypeUserLeft     MessageType = "user_left"
-	MessageTypeSessionState MessageType = "session_state"
-	MessageTypeStartSession MessageType = "start_session"
-	MessageTypeWaitingRoom  MessageType = "waiting_room"
+	MessageTypeVote          MessageType = "vote"
+	MessageTypeReveal        MessageType = "reveal"
+	MessageTypeNewRound      MessageType = "new_round"
+	MessageTypeSetStory      MessageType = "set_story"
+	MessageTypeUserJoined    MessageType = "user_joined"
+	MessageTypeUserLeft      MessageType = "user_left"
+	MessageTypeSessionState  MessageType = "session_state"
+	MessageTypeStartSession  MessageType = "start_session"
+	MessageTypeWaitingRoom   MessageType = "waiting_room"
+	MessageTypeStatePatch    MessageType = "state_patch"
+	MessageTypeSync          MessageType = "sync"
+	MessageTypeAddStories    MessageType = "add_stories"
+	MessageTypeNextStory     MessageType = "next_story"
+	MessageTypeSetEstimate   MessageType = "set_estimate"
+	MessageTypeEndSession    MessageType = "end_session"
+	MessageTypeAddWebhook    MessageType = "add_webhook"
+	MessageTypeRemoveWebhook MessageType = "remove_webhook"
+
+	// MessageTypeServerShutdown tells a client its server is going away;
+	// the data is a ShutdownNotice
+	MessageTypeServerShutdown MessageType = "server_shutdown"
 )
 
 type SessionStatus string
@@ -38,13 +50,60 @@ type Message struct {
 	UserID string          `json:"userId,omitempty"`
 }
 
+// StatePatch describes a single incremental change to the session state.
+// Op names the action that caused the change; only the fields relevant to
+// that action are set. Clients apply patches in Version order and send a
+// MessageTypeSync message to receive a full snapshot when they detect a gap.
+type StatePatch struct {
+	Version uint64             `json:"version"`
+	Op      MessageType        `json:"op"`
+	UserID  string             `json:"userId,omitempty"`
+	User    *ParticipantView   `json:"user,omitempty"`
+	Vote    *string            `json:"vote,omitempty"`
+	Votes   map[string]*string `json:"votes,omitempty"`
+	Story   *string            `json:"story,omitempty"`
+	Status  SessionStatus      `json:"status,omitempty"`
+
+	// Story queue changes. Changed lists the story fields the patch
+	// replaces, which are sent in full; a listed field that is absent is
+	// empty, or null for ActiveStory. Fields not listed are unchanged.
+	Changed     []string `json:"changed,omitempty"`
+	Queue       []Story  `json:"queue,omitempty"`
+	ActiveStory *Story   `json:"activeStory,omitempty"`
+	Estimated   []Story  `json:"estimated,omitempty"`
+}
+
+// Story fields a StatePatch can list in Changed
+const (
+	PatchQueue       = "queue"
+	PatchActiveStory = "activeStory"
+	PatchEstimated   = "estimated"
+)
+
+// ShutdownNotice is the data of a server_shutdown message. Clients should
+// reconnect after ReconnectAfterMs plus some jitter, so that everyone does
+// not reconnect at once; the session continues on the remaining instances.
+type ShutdownNotice struct {
+	Message          string `json:"message"`
+	ReconnectAfterMs int64  `json:"reconnectAfterMs"`
+}
+
+// Connection is the outbound side of a participant's connection. The
+// server adapts WebSockets and its SSE and long-polling queues to it; tests
+// use in-memory fakes. Send is called with the session lock held, so it must
+// not block for long.
+type Connection interface {
+	Send(msg Message) error
+	Close() error
+}
+
 type User struct {
-	ID          string          `json:"id"`
-	Name        string          `json:"name"`
-	Vote        *string         `json:"vote"`
-	IsOnline    bool            `json:"isOnline"`
-	IsModerator bool            `json:"isModerator"`
-	conn        *websocket.Conn `json:"-"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Vote        *string    `json:"vote"`
+	IsOnline    bool       `json:"isOnline"`
+	IsModerator bool       `json:"isModerator"`
+	conn        Connection `json:"-"`
 }
 
 type Session struct {
@@ -56,7 +115,14 @@ type Session struct {
 	CreatorID     string           `json:"creatorId"` // Who created the session
 	Status        SessionStatus    `json:"status"`    // Session status
 	CreatedAt     time.Time        `json:"createdAt"`
+	Version       uint64           `json:"version"` // Incremented on every state change
+	Queue         []Story          `json:"queue"`   // Stories waiting to be estimated
+	ActiveStory   *Story           `json:"activeStory"`
+	Estimated     []Story          `json:"estimated"` // Stories with an agreed estimate, in order
+	Webhooks      []Webhook        `json:"webhooks"`  // Never sent to participants
 	mu            sync.RWMutex     `json:"-"`
+	replica       *replica         `json:"-"` // Set when shared with other instances via a bus
+	onEvent       EventHandler     `json:"-"`
 }
 
 func NewSession(id string) *Session {
@@ -69,10 +135,7 @@ func NewSession(id string) *Session {
 	}
 }
 
-func (s *Session) AddUser(name string, conn *websocket.Conn, isCreator bool) *User {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
+func (s *Session) AddUser(name string, conn Connection, isCreator bool) *User {
 	user := &User{
 		ID:          uuid.New().String(),
 		Name:        name,
@@ -81,6 +144,20 @@ func (s *Session) AddUser(name string, conn *websocket.Conn, isCreator bool) *Us
 		conn:        conn,
 	}
 
+	if s.replica != nil {
+		s.publishJoin(user, isCreator)
+		return user
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addUserUnsafe(user, isCreator)
+	return user
+}
+
+// addUserUnsafe adds user to the session without acquiring locks
+func (s *Session) addUserUnsafe(user *User, isCreator bool) {
 	s.Users[user.ID] = user
 
 	// Set creator information if this is the creator
@@ -90,9 +167,10 @@ func (s *Session) AddUser(name string, conn *websocket.Conn, isCreator bool) *Us
 	}
 
 	// Notify all users about the new user
-	s.broadcastMessage(Message{
-		Type: MessageTypeUserJoined,
-		Data: mustMarshal(user),
+	s.broadcastPatch(StatePatch{
+		Op:     MessageTypeUserJoined,
+		UserID: user.ID,
+		User:   s.userView(user),
 	})
 
 	// Send appropriate state based on session status and creator status
@@ -107,38 +185,91 @@ func (s *Session) AddUser(name string, conn *websocket.Conn, isCreator bool) *Us
 		})
 	}
 
-	// Always send session state so users know about other participants
-	user.sendMessage(Message{
-		Type: MessageTypeSessionState,
-		Data: mustMarshal(s.getStateUnsafe()),
-	})
-
-	return user
+	// Always send a full snapshot so users know about other participants
+	user.sendMessage(s.stateMessage())
 }
 
 func (s *Session) RemoveUser(userID string) {
+	if s.replica != nil {
+		s.publish(sessionEvent{Kind: eventLeave, UserID: userID})
+		return
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.removeUserUnsafe(userID)
+}
+
+// removeUserUnsafe removes a user without acquiring locks
+func (s *Session) removeUserUnsafe(userID string) {
 	if user, exists := s.Users[userID]; exists {
 		user.IsOnline = false
 		delete(s.Users, userID)
+		if user.conn != nil {
+			user.conn.Close()
+		}
 
-		s.broadcastMessage(Message{
-			Type: MessageTypeUserLeft,
-			Data: mustMarshal(map[string]string{"userId": userID}),
+		s.broadcastPatch(StatePatch{
+			Op:     MessageTypeUserLeft,
+			UserID: userID,
 		})
 	}
 }
 
-func (s *Session) HandleMessage(userID string, msg Message) {
+// Errors returned when a message is rejected
+var (
+	ErrUserNotFound    = errors.New("user not found in session")
+	ErrNotModerator    = errors.New("only the moderator can do this")
+	ErrNotCreator      = errors.New("only the session creator can do this")
+	ErrAlreadyStarted  = errors.New("session has already started")
+	ErrInvalidData     = errors.New("invalid message data")
+	ErrUnknownMessage  = errors.New("unknown message type")
+	ErrQueueEmpty      = errors.New("story queue is empty")
+	ErrNotRevealed     = errors.New("votes have not been revealed")
+	ErrSessionEnded    = errors.New("session has ended")
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// HandleMessage applies a client message sent by userID. It returns an
+// error if the user may not send the message or its data is invalid.
+func (s *Session) HandleMessage(userID string, msg Message) error {
+	messagesReceived.Inc(messageTypeLabel(msg.Type))
+
+	// Snapshots are only sent to the requesting connection, so sync
+	// requests never need to leave this instance
+	if s.replica != nil && msg.Type != MessageTypeSync {
+		// Reject early so callers get an error; replicas check again when
+		// the event is applied
+		s.mu.RLock()
+		err := s.checkMessageUnsafe(userID, msg)
+		s.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		s.publish(sessionEvent{Kind: eventMessage, UserID: userID, Message: &msg})
+		return nil
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.handleMessageUnsafe(userID, msg, true)
+}
+
+// checkMessageUnsafe reports whether userID may send msg, without
+// acquiring locks or changing state
+func (s *Session) checkMessageUnsafe(userID string, msg Message) error {
 	user, exists := s.Users[userID]
 	if !exists {
-		log.Printf("HandleMessage: User %s not found in session", userID)
-		return
+		s.logger().Warn("Message from unknown user", "user_id", userID)
+		return ErrUserNotFound
+	}
+
+	// An ended session only serves snapshots
+	if s.Status == SessionStatusEnded && msg.Type != MessageTypeSync {
+		return ErrSessionEnded
 	}
 
 	switch msg.Type {
@@ -147,74 +278,152 @@ func (s *Session) HandleMessage(userID string, msg Message) {
 			Vote string `json:"vote"`
 		}
 		if err := json.Unmarshal(msg.Data, &voteData); err != nil {
-			log.Printf("Invalid vote data: %v", err)
-			return
+			s.logger().Warn("Invalid vote data", "user_id", userID, "error", err)
+			return ErrInvalidData
 		}
 
-		user.Vote = &voteData.Vote
-
-		// Broadcast the vote (hidden) to all users
-		s.broadcastMessage(Message{
-			Type: MessageTypeSessionState,
-			Data: mustMarshal(s.getStateUnsafe()),
-		})
-
 	case MessageTypeReveal:
 		// Only allow moderator to reveal votes
 		if !user.IsModerator {
-			log.Printf("User %s attempted to reveal votes but is not moderator", user.Name)
-			return
+			s.logger().Warn("Non-moderator attempted to reveal votes", "user_id", userID, "user", user.Name)
+			return ErrNotModerator
 		}
-		s.VotesRevealed = true
-		s.broadcastMessage(Message{
-			Type: MessageTypeSessionState,
-			Data: mustMarshal(s.getStateUnsafe()),
-		})
 
 	case MessageTypeNewRound:
 		// Only allow moderator to start new rounds
 		if !user.IsModerator {
-			log.Printf("User %s attempted to start new round but is not moderator", user.Name)
-			return
+			s.logger().Warn("Non-moderator attempted to start new round", "user_id", userID, "user", user.Name)
+			return ErrNotModerator
 		}
-		s.startNewRound()
-		s.broadcastMessage(Message{
-			Type: MessageTypeSessionState,
-			Data: mustMarshal(s.getStateUnsafe()),
-		})
 
 	case MessageTypeSetStory:
 		// Only allow moderator to set stories
 		if !user.IsModerator {
-			log.Printf("User %s attempted to set story but is not moderator", user.Name)
-			return
+			s.logger().Warn("Non-moderator attempted to set story", "user_id", userID, "user", user.Name)
+			return ErrNotModerator
 		}
 		var storyData struct {
 			Story string `json:"story"`
 		}
 		if err := json.Unmarshal(msg.Data, &storyData); err != nil {
-			log.Printf("Invalid story data: %v", err)
-			return
+			s.logger().Warn("Invalid story data", "user_id", userID, "error", err)
+			return ErrInvalidData
 		}
 
-		s.CurrentStory = storyData.Story
-		s.startNewRound() // Reset votes when setting new story
-		s.broadcastMessage(Message{
-			Type: MessageTypeSessionState,
-			Data: mustMarshal(s.getStateUnsafe()),
-		})
-
 	case MessageTypeStartSession:
 		// Only allow creator to start session
 		if s.CreatorID != userID {
-			log.Printf("User %s attempted to start session but is not creator", user.Name)
-			return
+			s.logger().Warn("Non-creator attempted to start session", "user_id", userID, "user", user.Name)
+			return ErrNotCreator
+		}
+		if s.Status != SessionStatusWaiting {
+			s.logger().Warn("Session already started", "user_id", userID, "user", user.Name)
+			return ErrAlreadyStarted
+		}
+
+	case MessageTypeAddStories, MessageTypeNextStory, MessageTypeSetEstimate:
+		return s.checkStoryMessageUnsafe(user, msg)
+
+	case MessageTypeEndSession:
+		if !user.IsModerator {
+			s.logger().Warn("Non-moderator attempted to end session", "user_id", userID, "user", user.Name)
+			return ErrNotModerator
+		}
+
+	case MessageTypeAddWebhook, MessageTypeRemoveWebhook:
+		return s.checkWebhookMessageUnsafe(user, msg)
+
+	case MessageTypeSync:
+
+	default:
+		return ErrUnknownMessage
+	}
+
+	return nil
+}
+
+// handleMessageUnsafe applies a client message without acquiring locks.
+// local is false when the message was sent through another replica, which
+// runs the side effects of the change.
+func (s *Session) handleMessageUnsafe(userID string, msg Message, local bool) error {
+	if err := s.checkMessageUnsafe(userID, msg); err != nil {
+		return err
+	}
+	user := s.Users[userID]
+
+	switch msg.Type {
+	case MessageTypeVote:
+		var voteData struct {
+			Vote string `json:"vote"`
+		}
+		json.Unmarshal(msg.Data, &voteData)
+
+		user.Vote = &voteData.Vote
+
+		// Broadcast the vote (hidden) to all users
+		s.broadcastPatch(StatePatch{
+			Op:     MessageTypeVote,
+			UserID: userID,
+			Vote:   s.userView(user).Vote,
+		})
+
+	case MessageTypeReveal:
+		s.VotesRevealed = true
+		votes := make(map[string]*string, len(s.Users))
+		for id, u := range s.Users {
+			votes[id] = u.Vote
 		}
+		s.broadcastPatch(StatePatch{
+			Op:    MessageTypeReveal,
+			Votes: votes,
+		})
+		s.emitUnsafe(local, s.revealedEventUnsafe())
 
-		if !s.startSessionUnsafe(userID) {
-			log.Printf("Failed to start session for user %s", user.Name)
+	case MessageTypeNewRound:
+		s.startNewRound()
+		s.broadcastPatch(StatePatch{
+			Op: MessageTypeNewRound,
+		})
+
+	case MessageTypeSetStory:
+		var storyData struct {
+			Story string `json:"story"`
 		}
+		json.Unmarshal(msg.Data, &storyData)
+
+		s.CurrentStory = storyData.Story
+		s.ActiveStory = nil // A free-form story replaces any queued one
+		s.startNewRound()   // Reset votes when setting new story
+		s.broadcastPatch(StatePatch{
+			Op:      MessageTypeSetStory,
+			Story:   &s.CurrentStory,
+			Changed: []string{PatchActiveStory},
+		})
+
+	case MessageTypeStartSession:
+		s.startSessionUnsafe(userID, local)
+
+	case MessageTypeAddStories, MessageTypeNextStory, MessageTypeSetEstimate:
+		s.handleStoryMessageUnsafe(msg, local)
+
+	case MessageTypeEndSession:
+		s.Status = SessionStatusEnded
+		s.logger().Info("Session ended", "user_id", userID, "user", user.Name)
+		s.broadcastPatch(StatePatch{
+			Op:     MessageTypeEndSession,
+			Status: s.Status,
+		})
+		s.emitUnsafe(local, Event{Type: EventSessionEnded, Estimated: cloneStories(s.Estimated)})
+
+	case MessageTypeAddWebhook, MessageTypeRemoveWebhook:
+		s.handleWebhookMessageUnsafe(msg)
+
+	case MessageTypeSync:
+		// Client detected a version gap and needs a full snapshot
+		user.sendMessage(s.stateMessage())
 	}
+
+	return nil
 }
 
 func (s *Session) startNewRound() {
@@ -224,44 +433,32 @@ func (s *Session) startNewRound() {
 	}
 }
 
-func (s *Session) GetState() interface{} {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.getStateUnsafe()
-}
-
-func (s *Session) getStateUnsafe() interface{} {
-	// Create a copy of users with vote visibility based on reveal state
-	users := make(map[string]*User)
-	for id, user := range s.Users {
-		userCopy := *user
-		userCopy.conn = nil // Don't include connection in JSON
-
-		// Hide votes if not revealed
-		if !s.VotesRevealed && userCopy.Vote != nil {
-			hiddenVote := "?"
-			userCopy.Vote = &hiddenVote
-		}
-
-		users[id] = &userCopy
+// stateMessage builds a full session_state snapshot message
+func (s *Session) stateMessage() Message {
+	return Message{
+		Type: MessageTypeSessionState,
+		Data: mustMarshal(s.getStateUnsafe()),
 	}
+}
 
-	return map[string]interface{}{
-		"id":            s.ID,
-		"users":         users,
-		"currentStory":  s.CurrentStory,
-		"votesRevealed": s.VotesRevealed,
-		"status":        s.Status,
-		"createdAt":     s.CreatedAt,
-	}
+// broadcastPatch bumps the state version and sends patch to all users
+func (s *Session) broadcastPatch(patch StatePatch) {
+	s.Version++
+	patch.Version = s.Version
+	s.broadcastMessage(Message{
+		Type: MessageTypeStatePatch,
+		Data: mustMarshal(patch),
+	})
 }
 
 func (s *Session) broadcastMessage(msg Message) {
+	defer broadcastDuration.ObserveSince(time.Now())
+
 	for userID, user := range s.Users {
 		if user.IsOnline {
 			user.sendMessage(msg)
 		} else {
-			log.Printf("Skipping offline user %s (%s)", user.Name, userID)
+			s.logger().Debug("Skipping offline user", "user_id", userID, "user", user.Name)
 		}
 	}
 }
@@ -271,21 +468,66 @@ func (u *User) sendMessage(msg Message) {
 		return
 	}
 
-	if err := u.conn.WriteJSON(msg); err != nil {
-		log.Printf("Error sending message to user %s: %v", u.Name, err)
+	if err := u.conn.Send(msg); err != nil {
+		slog.Warn("Error sending message", "user_id", u.ID, "user", u.Name, "error", err)
+		droppedSends.Inc()
 		u.IsOnline = false
+		return
 	}
+	messagesSent.Inc(string(msg.Type))
+}
+
+// logger returns the default logger annotated with the session ID
+func (s *Session) logger() *slog.Logger {
+	return slog.With("session_id", s.ID)
 }
 
 func mustMarshal(v interface{}) json.RawMessage {
 	data, err := json.Marshal(v)
 	if err != nil {
-		log.Printf("JSON marshal error: %v", err)
+		slog.Error("JSON marshal error", "error", err)
 		return json.RawMessage("{}")
 	}
 	return data
 }
 
+// Shutdown sends a server_shutdown notice to the participants connected to
+// this instance and closes their connections. Their handlers remove them
+// from the session as usual; the session state is left intact. Notices are
+// sent in parallel and outside the session lock, so slow clients neither
+// hold up each other nor the session.
+func (s *Session) Shutdown(reconnectAfter time.Duration) {
+	s.mu.RLock()
+	var conns []Connection
+	for _, user := range s.Users {
+		if user.conn != nil {
+			conns = append(conns, user.conn)
+		}
+	}
+	s.mu.RUnlock()
+
+	msg := Message{
+		Type: MessageTypeServerShutdown,
+		Data: mustMarshal(ShutdownNotice{
+			Message:          "The server is restarting. Reconnecting...",
+			ReconnectAfterMs: reconnectAfter.Milliseconds(),
+		}),
+	}
+
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := conn.Send(msg); err != nil {
+				s.logger().Debug("Shutdown notice not delivered", "error", err)
+			}
+			conn.Close()
+		}()
+	}
+	wg.Wait()
+}
+
 // SetCreator sets the session creator and makes them the moderator
 func (s *Session) SetCreator(userID string) {
 	s.mu.Lock()
@@ -302,37 +544,30 @@ func (s *Session) SetCreator(userID string) {
 
 // StartSession starts the session (only creator can do this)
 func (s *Session) StartSession(userID string) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// Only the creator can start the session
-	if s.CreatorID != userID {
-		return false
-	}
-
-	if s.Status != SessionStatusWaiting {
-		return false
+	if s.replica != nil {
+		s.mu.RLock()
+		allowed := s.CreatorID == userID && s.Status == SessionStatusWaiting
+		s.mu.RUnlock()
+
+		if allowed {
+			s.publish(sessionEvent{
+				Kind:    eventMessage,
+				UserID:  userID,
+				Message: &Message{Type: MessageTypeStartSession},
+			})
+		}
+		return allowed
 	}
 
-	s.Status = SessionStatusActive
-	log.Printf("Session %s started by %s", s.ID, s.Users[userID].Name)
-
-	// Notify all users that session has started
-	s.broadcastMessage(Message{
-		Type: MessageTypeStartSession,
-		Data: mustMarshal(map[string]interface{}{
-			"message": "Session has been started by the moderator!",
-		}),
-	})
-
-	// Send current session state to all users
-	s.broadcastSessionState()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return true
+	return s.startSessionUnsafe(userID, true)
 }
 
-// startSessionUnsafe starts the session without acquiring locks (for internal use)
-func (s *Session) startSessionUnsafe(userID string) bool {
+// startSessionUnsafe starts the session without acquiring locks (for
+// internal use). local is as for handleMessageUnsafe.
+func (s *Session) startSessionUnsafe(userID string, local bool) bool {
 	// Only the creator can start the session
 	if s.CreatorID != userID {
 		return false
@@ -343,7 +578,7 @@ func (s *Session) startSessionUnsafe(userID string) bool {
 	}
 
 	s.Status = SessionStatusActive
-	log.Printf("Session %s started by %s", s.ID, s.Users[userID].Name)
+	s.logger().Info("Session started", "user_id", userID, "user", s.Users[userID].Name)
 
 	// Notify all users that session has started
 	s.broadcastMessage(Message{
@@ -353,17 +588,12 @@ func (s *Session) startSessionUnsafe(userID string) bool {
 		}),
 	})
 
-	// Send current session state to all users
-	s.broadcastSessionState()
+	// Send the status change to all users
+	s.broadcastPatch(StatePatch{
+		Op:     MessageTypeStartSession,
+		Status: s.Status,
+	})
+	s.emitUnsafe(local, Event{Type: EventSessionStarted})
 
 	return true
 }
-
-// broadcastSessionState sends the current session state to all users
-func (s *Session) broadcastSessionState() {
-	state := s.getStateUnsafe()
-	s.broadcastMessage(Message{
-		Type: MessageTypeSessionState,
-		Data: mustMarshal(state),
-	})
-}
@@ -1,7 +1,10 @@
 package poker
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestSessionCreation(t *testing.T) {
@@ -253,9 +256,9 @@ func TestSessionStateIncluesStatus(t *testing.T) {
 	session := NewSession("TEST123")
 
 	// Test waiting state
-	state := session.GetState().(map[string]interface{})
-	if state["status"] != SessionStatusWaiting {
-		t.Errorf("Expected session state to include status 'waiting', got %v", state["status"])
+	state := session.GetState()
+	if state.Status != SessionStatusWaiting {
+		t.Errorf("Expected session state to include status 'waiting', got %v", state.Status)
 	}
 
 	// Add creator and start session
@@ -263,8 +266,352 @@ func TestSessionStateIncluesStatus(t *testing.T) {
 	session.StartSession(creator.ID)
 
 	// Test active state
-	activeState := session.GetState().(map[string]interface{})
-	if activeState["status"] != SessionStatusActive {
-		t.Errorf("Expected session state to include status 'active', got %v", activeState["status"])
+	activeState := session.GetState()
+	if activeState.Status != SessionStatusActive {
+		t.Errorf("Expected session state to include status 'active', got %v", activeState.Status)
+	}
+}
+
+func TestStateVersionIncrementsOnChanges(t *testing.T) {
+	session := NewSession("TEST123")
+
+	if session.Version != 0 {
+		t.Errorf("Expected new session version to be 0, got %d", session.Version)
+	}
+
+	// Joining is a state change
+	creator := session.AddUser("Alice", nil, true)
+	if session.Version != 1 {
+		t.Errorf("Expected version 1 after join, got %d", session.Version)
+	}
+
+	session.StartSession(creator.ID)
+	if session.Version != 2 {
+		t.Errorf("Expected version 2 after start, got %d", session.Version)
+	}
+
+	session.HandleMessage(creator.ID, Message{
+		Type: MessageTypeVote,
+		Data: mustMarshal(map[string]string{"vote": "5"}),
+	})
+	if session.Version != 3 {
+		t.Errorf("Expected version 3 after vote, got %d", session.Version)
+	}
+
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeReveal})
+	if session.Version != 4 {
+		t.Errorf("Expected version 4 after reveal, got %d", session.Version)
+	}
+
+	session.RemoveUser(creator.ID)
+	if session.Version != 5 {
+		t.Errorf("Expected version 5 after leave, got %d", session.Version)
+	}
+}
+
+func TestRejectedActionDoesNotChangeVersion(t *testing.T) {
+	session := NewSession("TEST123")
+	session.AddUser("Alice", nil, true)
+	participant := session.AddUser("Bob", nil, false)
+
+	version := session.Version
+
+	// Non-moderator reveal is ignored
+	session.HandleMessage(participant.ID, Message{Type: MessageTypeReveal})
+
+	// Sync only sends a snapshot to the requester
+	session.HandleMessage(participant.ID, Message{Type: MessageTypeSync})
+
+	if session.Version != version {
+		t.Errorf("Expected version to remain %d, got %d", version, session.Version)
+	}
+}
+
+func TestSessionStateIncludesVersion(t *testing.T) {
+	session := NewSession("TEST123")
+	session.AddUser("Alice", nil, true)
+
+	state := session.GetState()
+	if state.Version != session.Version {
+		t.Errorf("Expected session state version %d, got %d", session.Version, state.Version)
+	}
+}
+
+func TestSessionStateIncludesRoles(t *testing.T) {
+	session := NewSession("TEST123")
+	creator := session.AddUser("Alice", nil, true)
+	voter := session.AddUser("Bob", nil, false)
+
+	state := session.GetState()
+	if state.CreatorID != creator.ID || state.ModeratorID != creator.ID {
+		t.Errorf("Expected creator and moderator %s, got %s and %s", creator.ID, state.CreatorID, state.ModeratorID)
+	}
+	if view := state.Users[creator.ID]; !view.IsCreator || !view.IsModerator {
+		t.Errorf("Expected creator view to be creator and moderator, got %+v", view)
+	}
+	if view := state.Users[voter.ID]; view.IsCreator || view.IsModerator {
+		t.Errorf("Expected voter view to have no roles, got %+v", view)
+	}
+
+	// The snapshot must not share vote pointers with the session
+	vote := "5"
+	voter.Vote = &vote
+	session.VotesRevealed = true
+	state = session.GetState()
+	*state.Users[voter.ID].Vote = "13"
+	if *voter.Vote != "5" {
+		t.Errorf("Changing a snapshot changed the session vote to %s", *voter.Vote)
+	}
+}
+
+func TestUserViewHidesVoteUntilRevealed(t *testing.T) {
+	session := NewSession("TEST123")
+	user := session.AddUser("Alice", nil, true)
+
+	vote := "8"
+	user.Vote = &vote
+
+	if view := session.userView(user); view.Vote == nil || *view.Vote != "?" {
+		t.Errorf("Expected hidden vote '?', got %v", view.Vote)
+	}
+
+	session.VotesRevealed = true
+	if view := session.userView(user); view.Vote == nil || *view.Vote != "8" {
+		t.Errorf("Expected revealed vote '8', got %v", view.Vote)
+	}
+}
+
+// fakeConnection records messages sent to a participant
+type fakeConnection struct {
+	messages []Message
+	closed   bool
+	err      error // Returned from Send when set
+}
+
+func (c *fakeConnection) Send(msg Message) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+func (c *fakeConnection) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConnection) types() []MessageType {
+	types := make([]MessageType, len(c.messages))
+	for i, msg := range c.messages {
+		types[i] = msg.Type
+	}
+	return types
+}
+
+func (c *fakeConnection) last() Message {
+	return c.messages[len(c.messages)-1]
+}
+
+func TestJoinMessages(t *testing.T) {
+	session := NewSession("TEST123")
+
+	creatorConn := &fakeConnection{}
+	session.AddUser("Alice", creatorConn, true)
+
+	// The creator skips the waiting room
+	if got := creatorConn.types(); len(got) != 2 || got[0] != MessageTypeStatePatch || got[1] != MessageTypeSessionState {
+		t.Errorf("Expected creator to receive [state_patch session_state], got %v", got)
+	}
+
+	participantConn := &fakeConnection{}
+	session.AddUser("Bob", participantConn, false)
+
+	if got := participantConn.types(); len(got) != 3 || got[1] != MessageTypeWaitingRoom {
+		t.Errorf("Expected participant to receive a waiting_room message, got %v", got)
+	}
+
+	// Existing users learn about the new participant through a patch
+	var patch StatePatch
+	json.Unmarshal(creatorConn.last().Data, &patch)
+	if patch.Op != MessageTypeUserJoined || patch.User == nil || patch.User.Name != "Bob" {
+		t.Errorf("Expected user_joined patch for Bob, got %+v", patch)
+	}
+}
+
+func TestVoteBroadcastsHiddenPatch(t *testing.T) {
+	session := NewSession("TEST123")
+	creatorConn := &fakeConnection{}
+	creator := session.AddUser("Alice", creatorConn, true)
+	participantConn := &fakeConnection{}
+	session.AddUser("Bob", participantConn, false)
+
+	session.HandleMessage(creator.ID, Message{
+		Type: MessageTypeVote,
+		Data: mustMarshal(map[string]string{"vote": "13"}),
+	})
+
+	for _, conn := range []*fakeConnection{creatorConn, participantConn} {
+		var patch StatePatch
+		json.Unmarshal(conn.last().Data, &patch)
+		if patch.Op != MessageTypeVote || patch.UserID != creator.ID {
+			t.Errorf("Expected vote patch for creator, got %+v", patch)
+		}
+		if patch.Vote == nil || *patch.Vote != "?" {
+			t.Errorf("Expected hidden vote '?', got %v", patch.Vote)
+		}
+	}
+}
+
+func TestSyncSendsSnapshotToRequesterOnly(t *testing.T) {
+	session := NewSession("TEST123")
+	creatorConn := &fakeConnection{}
+	session.AddUser("Alice", creatorConn, true)
+	participantConn := &fakeConnection{}
+	participant := session.AddUser("Bob", participantConn, false)
+
+	creatorCount := len(creatorConn.messages)
+	session.HandleMessage(participant.ID, Message{Type: MessageTypeSync})
+
+	if participantConn.last().Type != MessageTypeSessionState {
+		t.Errorf("Expected requester to receive session_state, got %s", participantConn.last().Type)
+	}
+	if len(creatorConn.messages) != creatorCount {
+		t.Error("Expected other users not to receive the snapshot")
+	}
+}
+
+func TestRemoveUserClosesConnection(t *testing.T) {
+	session := NewSession("TEST123")
+	conn := &fakeConnection{}
+	user := session.AddUser("Alice", conn, true)
+
+	session.RemoveUser(user.ID)
+
+	if !conn.closed {
+		t.Error("Expected connection to be closed when user is removed")
+	}
+}
+
+func TestSendErrorMarksUserOffline(t *testing.T) {
+	session := NewSession("TEST123")
+	creator := session.AddUser("Alice", &fakeConnection{}, true)
+	brokenConn := &fakeConnection{}
+	participant := session.AddUser("Bob", brokenConn, false)
+
+	brokenConn.err = errors.New("connection reset")
+	dropped := droppedSends.Value()
+	session.HandleMessage(creator.ID, Message{
+		Type: MessageTypeVote,
+		Data: mustMarshal(map[string]string{"vote": "1"}),
+	})
+
+	if participant.IsOnline {
+		t.Error("Expected user to be marked offline after a failed send")
+	}
+	if droppedSends.Value() != dropped+1 {
+		t.Errorf("Expected one dropped send to be counted, got %v", droppedSends.Value()-dropped)
+	}
+}
+
+func TestHandleMessageErrors(t *testing.T) {
+	session := NewSession("TEST123")
+	creator := session.AddUser("Alice", nil, true)
+	participant := session.AddUser("Bob", nil, false)
+
+	tests := []struct {
+		name   string
+		userID string
+		msg    Message
+		want   error
+	}{
+		{"unknown user", "nobody", Message{Type: MessageTypeVote}, ErrUserNotFound},
+		{"participant reveal", participant.ID, Message{Type: MessageTypeReveal}, ErrNotModerator},
+		{"participant start", participant.ID, Message{Type: MessageTypeStartSession}, ErrNotCreator},
+		{"invalid vote data", participant.ID, Message{Type: MessageTypeVote, Data: []byte(`"5"`)}, ErrInvalidData},
+		{"unknown type", creator.ID, Message{Type: "dance"}, ErrUnknownMessage},
+		{"creator start", creator.ID, Message{Type: MessageTypeStartSession}, nil},
+		{"start twice", creator.ID, Message{Type: MessageTypeStartSession}, ErrAlreadyStarted},
+	}
+
+	for _, tt := range tests {
+		if err := session.HandleMessage(tt.userID, tt.msg); err != tt.want {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestShutdownNotifiesAndClosesConnections(t *testing.T) {
+	session := NewSession("TEST123")
+
+	conn := &fakeConnection{}
+	session.AddUser("Alice", conn, true)
+	session.AddUser("Remote", nil, false)
+
+	session.Shutdown(2 * time.Second)
+
+	if conn.last().Type != MessageTypeServerShutdown || !conn.closed {
+		t.Fatalf("Expected server_shutdown followed by close, got %v closed=%v", conn.types(), conn.closed)
+	}
+	var notice ShutdownNotice
+	json.Unmarshal(conn.last().Data, &notice)
+	if notice.ReconnectAfterMs != 2000 {
+		t.Errorf("Expected reconnectAfterMs 2000, got %d", notice.ReconnectAfterMs)
+	}
+}
+
+// stallingConnection holds shutdown notices until release is closed, like
+// a client that stopped reading
+type stallingConnection struct {
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func (c *stallingConnection) Send(msg Message) error {
+	if msg.Type == MessageTypeServerShutdown {
+		<-c.release
+	}
+	return nil
+}
+
+func (c *stallingConnection) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestShutdownDoesNotWaitOnStalledClients(t *testing.T) {
+	session := NewSession("TEST123")
+
+	stalled := &stallingConnection{release: make(chan struct{}), closed: make(chan struct{})}
+	other := &stallingConnection{release: make(chan struct{}), closed: make(chan struct{})}
+	close(other.release)
+	session.AddUser("Alice", stalled, true)
+	session.AddUser("Bob", other, false)
+
+	done := make(chan struct{})
+	go func() {
+		session.Shutdown(2 * time.Second)
+		close(done)
+	}()
+
+	select {
+	case <-other.closed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected other clients to be notified while one stalls")
+	}
+	state := make(chan SessionState)
+	go func() { state <- session.GetState() }()
+	select {
+	case <-state:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the session not to be locked while notices are sent")
+	}
+
+	close(stalled.release)
+	<-done
+	select {
+	case <-stalled.closed:
+	default:
+		t.Error("Expected the stalled connection to be closed once its notice is sent")
 	}
 }
@@ -0,0 +1,91 @@
+package poker
+
+import "time"
+
+// SessionState is the snapshot of a session sent to participants in
+// session_state messages and returned by the HTTP API. Version matches the
+// Version of the last StatePatch applied, so clients can tell which patches
+// a snapshot already contains.
+type SessionState struct {
+	ID            string                      `json:"id"`
+	Users         map[string]*ParticipantView `json:"users"`
+	CurrentStory  string                      `json:"currentStory"`
+	VotesRevealed bool                        `json:"votesRevealed"`
+	ModeratorID   string                      `json:"moderatorId"`
+	CreatorID     string                      `json:"creatorId"`
+	Status        SessionStatus               `json:"status"`
+	CreatedAt     time.Time                   `json:"createdAt"`
+	Version       uint64                      `json:"version"`
+	Queue         []Story                     `json:"queue"`
+	ActiveStory   *Story                      `json:"activeStory"`
+	Estimated     []Story                     `json:"estimated"`
+}
+
+// ParticipantView is a user as other participants see them: votes read "?"
+// until they are revealed
+type ParticipantView struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Vote        *string `json:"vote"`
+	IsOnline    bool    `json:"isOnline"`
+	IsModerator bool    `json:"isModerator"`
+	IsCreator   bool    `json:"isCreator"`
+}
+
+func (s *Session) GetState() SessionState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getStateUnsafe()
+}
+
+// UserCount returns the number of participants in the session
+func (s *Session) UserCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.Users)
+}
+
+func (s *Session) getStateUnsafe() SessionState {
+	users := make(map[string]*ParticipantView, len(s.Users))
+	for id, user := range s.Users {
+		users[id] = s.userView(user)
+	}
+
+	return SessionState{
+		ID:            s.ID,
+		Users:         users,
+		CurrentStory:  s.CurrentStory,
+		VotesRevealed: s.VotesRevealed,
+		ModeratorID:   s.ModeratorID,
+		CreatorID:     s.CreatorID,
+		Status:        s.Status,
+		CreatedAt:     s.CreatedAt,
+		Version:       s.Version,
+		Queue:         cloneStories(s.Queue),
+		ActiveStory:   cloneStory(s.ActiveStory),
+		Estimated:     cloneStories(s.Estimated),
+	}
+}
+
+// userView returns the view of user that is safe to send to other participants
+func (s *Session) userView(user *User) *ParticipantView {
+	view := &ParticipantView{
+		ID:          user.ID,
+		Name:        user.Name,
+		IsOnline:    user.IsOnline,
+		IsModerator: user.IsModerator,
+		IsCreator:   user.ID == s.CreatorID,
+	}
+
+	// Hide votes if not revealed; copy them otherwise so the view never
+	// aliases session state
+	if user.Vote != nil {
+		vote := "?"
+		if s.VotesRevealed {
+			vote = *user.Vote
+		}
+		view.Vote = &vote
+	}
+
+	return view
+}
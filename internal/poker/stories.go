@@ -0,0 +1,198 @@
+package poker
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// maxQueuedStories bounds the story queue of a session
+const maxQueuedStories = 500
+
+// Story is an item in a session's story queue. Stories imported from an
+// issue tracker carry the tracker's name in Source and the issue key in
+// Key, so that the agreed estimate can be written back to the issue. Only
+// ImportStories sets them; they are cleared on stories clients add.
+type Story struct {
+	Key      string `json:"key,omitempty"`
+	Title    string `json:"title"`
+	URL      string `json:"url,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Estimate string `json:"estimate,omitempty"` // Set once a round on the story is finalized
+}
+
+// addStoriesData is the data of an add_stories message
+type addStoriesData struct {
+	Stories []Story `json:"stories"`
+}
+
+// setEstimateData is the data of a set_estimate message
+type setEstimateData struct {
+	Estimate string `json:"estimate"`
+}
+
+// checkStoryMessageUnsafe validates the story queue messages, which only
+// the moderator may send
+func (s *Session) checkStoryMessageUnsafe(user *User, msg Message) error {
+	if !user.IsModerator {
+		s.logger().Warn("Non-moderator attempted to change the story queue", "user_id", user.ID, "user", user.Name, "type", msg.Type)
+		return ErrNotModerator
+	}
+
+	switch msg.Type {
+	case MessageTypeAddStories:
+		var data addStoriesData
+		if err := json.Unmarshal(msg.Data, &data); err != nil || len(data.Stories) == 0 {
+			s.logger().Warn("Invalid stories data", "user_id", user.ID, "error", err)
+			return ErrInvalidData
+		}
+		if len(s.Queue)+len(data.Stories) > maxQueuedStories {
+			return ErrInvalidData
+		}
+		for _, story := range data.Stories {
+			if strings.TrimSpace(story.Title) == "" {
+				return ErrInvalidData
+			}
+		}
+
+	case MessageTypeNextStory:
+		if len(s.Queue) == 0 {
+			return ErrQueueEmpty
+		}
+
+	case MessageTypeSetEstimate:
+		var data setEstimateData
+		if err := json.Unmarshal(msg.Data, &data); err != nil || data.Estimate == "" {
+			s.logger().Warn("Invalid estimate data", "user_id", user.ID, "error", err)
+			return ErrInvalidData
+		}
+		if !s.VotesRevealed {
+			return ErrNotRevealed
+		}
+	}
+
+	return nil
+}
+
+// ImportStories queues stories the server imported from an issue tracker,
+// on behalf of userID, who must be the moderator. Unlike add_stories
+// messages, which clients could point at any issue, the stories keep their
+// Key, URL and Source, so that agreed estimates are written back.
+func (s *Session) ImportStories(userID string, stories []Story) error {
+	msg := Message{Type: MessageTypeAddStories, Data: mustMarshal(addStoriesData{Stories: stories})}
+
+	if s.replica != nil {
+		s.mu.RLock()
+		err := s.checkMessageUnsafe(userID, msg)
+		s.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		s.publish(sessionEvent{Kind: eventImport, UserID: userID, Message: &msg})
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.importStoriesUnsafe(userID, msg)
+}
+
+// importStoriesUnsafe checks and applies the add_stories message built by
+// ImportStories, keeping the tracker fields of its stories
+func (s *Session) importStoriesUnsafe(userID string, msg Message) error {
+	if err := s.checkMessageUnsafe(userID, msg); err != nil {
+		return err
+	}
+
+	var data addStoriesData
+	json.Unmarshal(msg.Data, &data)
+	s.queueStoriesUnsafe(data.Stories)
+	return nil
+}
+
+// queueStoriesUnsafe appends stories to the queue
+func (s *Session) queueStoriesUnsafe(stories []Story) {
+	for _, story := range stories {
+		story.Estimate = ""
+		s.Queue = append(s.Queue, story)
+	}
+	s.broadcastPatch(StatePatch{
+		Op:      MessageTypeAddStories,
+		Changed: []string{PatchQueue},
+		Queue:   cloneStories(s.Queue),
+	})
+}
+
+// handleStoryMessageUnsafe applies a checked story queue message
+func (s *Session) handleStoryMessageUnsafe(msg Message, local bool) {
+	switch msg.Type {
+	case MessageTypeAddStories:
+		var data addStoriesData
+		json.Unmarshal(msg.Data, &data)
+
+		// Only the server knows which issues a story may be written to
+		for i := range data.Stories {
+			data.Stories[i].Key = ""
+			data.Stories[i].URL = ""
+			data.Stories[i].Source = ""
+		}
+		s.queueStoriesUnsafe(data.Stories)
+
+	case MessageTypeNextStory:
+		story := s.Queue[0]
+		s.Queue = s.Queue[1:]
+		s.ActiveStory = &story
+		s.CurrentStory = story.Title
+		s.startNewRound()
+		s.broadcastPatch(StatePatch{
+			Op:          MessageTypeNextStory,
+			Story:       &s.CurrentStory,
+			Changed:     []string{PatchQueue, PatchActiveStory},
+			Queue:       cloneStories(s.Queue),
+			ActiveStory: cloneStory(s.ActiveStory),
+		})
+
+	case MessageTypeSetEstimate:
+		var data setEstimateData
+		json.Unmarshal(msg.Data, &data)
+
+		// A free-form story is recorded by its title
+		story := s.ActiveStory
+		if story == nil {
+			story = &Story{Title: s.CurrentStory}
+			s.ActiveStory = story
+		}
+
+		// Estimating the same story again corrects the previous estimate
+		if story.Estimate != "" && len(s.Estimated) > 0 {
+			s.Estimated = s.Estimated[:len(s.Estimated)-1]
+		}
+		story.Estimate = data.Estimate
+		s.Estimated = append(s.Estimated, *story)
+
+		s.logger().Info("Estimate set", "story", story.Title, "key", story.Key, "estimate", story.Estimate)
+		s.broadcastPatch(StatePatch{
+			Op:          MessageTypeSetEstimate,
+			Changed:     []string{PatchActiveStory, PatchEstimated},
+			ActiveStory: cloneStory(story),
+			Estimated:   cloneStories(s.Estimated),
+		})
+
+		s.emitUnsafe(local, Event{Type: EventEstimateSet, Story: cloneStory(story)})
+	}
+}
+
+// cloneStories copies a story list so that snapshots and patches never
+// alias session state. The copy is never nil, so it encodes as [].
+func cloneStories(stories []Story) []Story {
+	return append(make([]Story, 0, len(stories)), stories...)
+}
+
+func cloneStory(story *Story) *Story {
+	if story == nil {
+		return nil
+	}
+	clone := *story
+	return &clone
+}
@@ -0,0 +1,195 @@
+package poker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"planning-poker/internal/bus"
+)
+
+func importStories(t *testing.T, s *Session, userID string, stories ...Story) {
+	t.Helper()
+
+	if err := s.ImportStories(userID, stories); err != nil {
+		t.Fatalf("ImportStories failed: %v", err)
+	}
+}
+
+func TestStoryQueue(t *testing.T) {
+	session := NewSession("TEST123")
+	conn := &fakeConnection{}
+	creator := session.AddUser("Alice", conn, true)
+
+	importStories(t, session, creator.ID,
+		Story{Key: "PP-1", Title: "Login page", Source: "jira"},
+		Story{Key: "PP-2", Title: "Logout", Source: "jira"},
+	)
+
+	var patch StatePatch
+	json.Unmarshal(conn.last().Data, &patch)
+	if patch.Op != MessageTypeAddStories || len(patch.Queue) != 2 {
+		t.Errorf("Expected add_stories patch with the queue, got %+v", patch)
+	}
+
+	if err := session.HandleMessage(creator.ID, Message{Type: MessageTypeNextStory}); err != nil {
+		t.Fatalf("next_story failed: %v", err)
+	}
+
+	state := session.GetState()
+	if state.CurrentStory != "Login page" || state.ActiveStory == nil || state.ActiveStory.Key != "PP-1" {
+		t.Errorf("Expected PP-1 to be the current story, got %q %+v", state.CurrentStory, state.ActiveStory)
+	}
+	if len(state.Queue) != 1 || state.Queue[0].Key != "PP-2" {
+		t.Errorf("Expected PP-2 to remain queued, got %+v", state.Queue)
+	}
+
+	// A free-form story is not the queued one
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeSetStory, Data: mustMarshal(map[string]string{"story": "Something else"})})
+	if session.GetState().ActiveStory != nil {
+		t.Error("Expected set_story to clear the active story")
+	}
+}
+
+func TestPatchClearsLastStory(t *testing.T) {
+	session := NewSession("TEST123")
+	conn := &fakeConnection{}
+	creator := session.AddUser("Alice", conn, true)
+	importStories(t, session, creator.ID, Story{Key: "PP-1", Title: "Login page", Source: "jira"})
+
+	// Taking the last story empties the queue, which the patch must say
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeNextStory})
+	var patch map[string]json.RawMessage
+	json.Unmarshal(conn.last().Data, &patch)
+	if string(patch["changed"]) != `["queue","activeStory"]` || patch["queue"] != nil {
+		t.Errorf("Expected the patch to list the emptied queue, got %s", conn.last().Data)
+	}
+
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeSetStory, Data: mustMarshal(map[string]string{"story": "Something else"})})
+	patch = nil
+	json.Unmarshal(conn.last().Data, &patch)
+	if string(patch["changed"]) != `["activeStory"]` || patch["activeStory"] != nil {
+		t.Errorf("Expected set_story to clear the active story in the patch, got %s", conn.last().Data)
+	}
+}
+
+func TestSetEstimate(t *testing.T) {
+	session := NewSession("TEST123")
+	creator := session.AddUser("Alice", nil, true)
+	participant := session.AddUser("Bob", nil, false)
+
+	estimates := make(chan Story, 2)
+	session.OnEvent(func(event Event) {
+		if event.Type == EventEstimateSet {
+			estimates <- *event.Story
+		}
+	})
+
+	importStories(t, session, creator.ID, Story{Key: "PP-1", Title: "Login page", Source: "jira"})
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeNextStory})
+
+	estimate := Message{Type: MessageTypeSetEstimate, Data: mustMarshal(map[string]string{"estimate": "5"})}
+	if err := session.HandleMessage(creator.ID, estimate); err != ErrNotRevealed {
+		t.Errorf("Expected estimate before reveal to fail with %v, got %v", ErrNotRevealed, err)
+	}
+	if err := session.HandleMessage(participant.ID, estimate); err != ErrNotModerator {
+		t.Errorf("Expected participant estimate to fail with %v, got %v", ErrNotModerator, err)
+	}
+
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeReveal})
+	if err := session.HandleMessage(creator.ID, estimate); err != nil {
+		t.Fatalf("set_estimate failed: %v", err)
+	}
+
+	story := <-estimates
+	if story.Key != "PP-1" || story.Estimate != "5" {
+		t.Errorf("Expected handler to receive PP-1 estimated at 5, got %+v", story)
+	}
+
+	// Estimating again corrects the recorded estimate
+	session.HandleMessage(creator.ID, Message{Type: MessageTypeSetEstimate, Data: mustMarshal(map[string]string{"estimate": "8"})})
+	<-estimates
+	state := session.GetState()
+	if len(state.Estimated) != 1 || state.Estimated[0].Estimate != "8" {
+		t.Errorf("Expected one estimated story at 8, got %+v", state.Estimated)
+	}
+}
+
+func TestStoryMessageErrors(t *testing.T) {
+	session := NewSession("TEST123")
+	creator := session.AddUser("Alice", nil, true)
+	participant := session.AddUser("Bob", nil, false)
+
+	tests := []struct {
+		name   string
+		userID string
+		msg    Message
+		want   error
+	}{
+		{"participant add", participant.ID, Message{Type: MessageTypeAddStories, Data: []byte(`{"stories":[{"title":"x"}]}`)}, ErrNotModerator},
+		{"no stories", creator.ID, Message{Type: MessageTypeAddStories, Data: []byte(`{"stories":[]}`)}, ErrInvalidData},
+		{"untitled story", creator.ID, Message{Type: MessageTypeAddStories, Data: []byte(`{"stories":[{"key":"PP-1"}]}`)}, ErrInvalidData},
+		{"empty queue", creator.ID, Message{Type: MessageTypeNextStory}, ErrQueueEmpty},
+		{"empty estimate", creator.ID, Message{Type: MessageTypeSetEstimate, Data: []byte(`{"estimate":""}`)}, ErrInvalidData},
+	}
+
+	for _, tt := range tests {
+		if err := session.HandleMessage(tt.userID, tt.msg); err != tt.want {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestEstimateEventOnOriginOnly(t *testing.T) {
+	b := bus.NewLocal()
+	instanceA := newReplica(t, "SHARED", b)
+	creator := instanceA.AddUser("Alice", nil, true)
+	instanceB := newReplica(t, "SHARED", b)
+
+	fromA := make(chan Story, 1)
+	fromB := make(chan Story, 1)
+	instanceA.OnEvent(func(event Event) {
+		if event.Type == EventEstimateSet {
+			fromA <- *event.Story
+		}
+	})
+	instanceB.OnEvent(func(event Event) {
+		if event.Type == EventEstimateSet {
+			fromB <- *event.Story
+		}
+	})
+
+	importStories(t, instanceA, creator.ID, Story{Key: "PP-1", Title: "Login page"})
+	instanceA.HandleMessage(creator.ID, Message{Type: MessageTypeNextStory})
+	instanceA.HandleMessage(creator.ID, Message{Type: MessageTypeReveal})
+	instanceA.HandleMessage(creator.ID, Message{Type: MessageTypeSetEstimate, Data: mustMarshal(map[string]string{"estimate": "3"})})
+
+	<-fromA
+	select {
+	case story := <-fromB:
+		t.Errorf("Expected only the originating replica to run the handler, B got %+v", story)
+	default:
+	}
+
+	if state := instanceB.GetState(); len(state.Estimated) != 1 || state.Estimated[0].Estimate != "3" || state.Estimated[0].Key != "PP-1" {
+		t.Errorf("Expected B to replicate the estimate of the imported story, got %+v", state.Estimated)
+	}
+}
+
+func TestClientStoriesHaveNoTrackerFields(t *testing.T) {
+	session := NewSession("TEST123")
+	creator := session.AddUser("Alice", nil, true)
+
+	// A client could otherwise have the server write to any issue
+	err := session.HandleMessage(creator.ID, Message{
+		Type: MessageTypeAddStories,
+		Data: []byte(`{"stories":[{"title":"Login page","key":"PROD-1","url":"https://jira.example.com/browse/PROD-1","source":"jira"}]}`),
+	})
+	if err != nil {
+		t.Fatalf("add_stories failed: %v", err)
+	}
+
+	queue := session.GetState().Queue
+	if len(queue) != 1 || queue[0] != (Story{Title: "Login page"}) {
+		t.Errorf("Expected only the title to be kept, got %+v", queue)
+	}
+}
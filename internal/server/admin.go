@@ -0,0 +1,72 @@
+package server
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// AdminHealthResponse is the detailed health report on the admin listener
+type AdminHealthResponse struct {
+	HealthResponse
+	Uptime     string         `json:"uptime"`
+	Goroutines int            `json:"goroutines"`
+	Transports map[string]int `json:"transports"` // Connections by transport
+	BusBackend string         `json:"busBackend"`
+}
+
+// AdminHandler returns the handler for the admin listener: metrics, expvar,
+// pprof when enabled, the webhook delivery log and a detailed health
+// check. It must only be served on the admin address, never on the public
+// one.
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/health", s.handleAdminHealth)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/webhooks/deliveries", s.handleAdminDeliveries)
+
+	cfg := s.Config()
+	if cfg == nil || cfg.MetricsEnabled {
+		mux.HandleFunc("/metrics", s.HandleMetrics)
+	}
+
+	if cfg != nil && cfg.EnablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	return mux
+}
+
+func (s *Server) handleAdminHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	response, ready := s.health()
+	response.Status = "ready"
+	if !ready {
+		response.Status = "not_ready"
+	}
+
+	busBackend := "local"
+	if cfg := s.Config(); cfg != nil {
+		busBackend = cfg.BusBackend
+	}
+
+	// Always 200: this report is for people, not load balancers
+	writeJSON(w, http.StatusOK, AdminHealthResponse{
+		HealthResponse: response,
+		Uptime:         time.Since(s.started).Round(time.Second).String(),
+		Goroutines:     runtime.NumGoroutine(),
+		Transports:     s.connectionCounts(),
+		BusBackend:     busBackend,
+	})
+}
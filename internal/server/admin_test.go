@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"planning-poker/internal/config"
+)
+
+func adminGet(handler http.Handler, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAdminHandler(t *testing.T) {
+	cfg := &config.Config{BusBackend: "local", MetricsEnabled: true, EnablePprof: true, IsDevelopment: true}
+	server := NewWithConfig(cfg)
+	restCall(server, "POST", "/api/sessions", "", `{"sessionId":"ADMIN1","moderator":"ci-bot"}`)
+	handler := server.AdminHandler()
+
+	for _, path := range []string{"/metrics", "/debug/vars", "/debug/pprof/", "/debug/pprof/cmdline"} {
+		if rr := adminGet(handler, path); rr.Code != http.StatusOK {
+			t.Errorf("Expected %s to return %d, got %d", path, http.StatusOK, rr.Code)
+		}
+	}
+
+	rr := adminGet(handler, "/health")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var health AdminHealthResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Could not parse health response: %v", err)
+	}
+	if health.Status != "ready" || health.Sessions != 1 || health.Participants != 1 || health.BusBackend != "local" {
+		t.Errorf("Unexpected health response %+v", health)
+	}
+	if health.Goroutines == 0 {
+		t.Error("Expected goroutine count")
+	}
+}
+
+func TestAdminHandlerDisabledFeatures(t *testing.T) {
+	server := NewWithConfig(&config.Config{MetricsEnabled: false, EnablePprof: false, IsDevelopment: true})
+	handler := server.AdminHandler()
+
+	for _, path := range []string{"/metrics", "/debug/pprof/"} {
+		if rr := adminGet(handler, path); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected %s to be disabled, got %d", path, rr.Code)
+		}
+	}
+}
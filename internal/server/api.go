@@ -0,0 +1,328 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"planning-poker/internal/poker"
+	"planning-poker/internal/webhook"
+)
+
+// Request and response bodies of the HTTP API. Every JSON body is decoded
+// into one of these types and validated before a handler acts on it; the
+// OpenAPI document served at /api/openapi.json describes the same shapes.
+
+const (
+	maxRequestBody  = 64 << 10 // Bytes
+	maxNameLength   = 64       // Characters
+	maxVoteLength   = 16       // Characters
+	maxStoryLength  = 1000     // Characters
+	maxURLLength    = 2000     // Characters
+	maxSecretLength = 256      // Characters
+)
+
+// sessionIDPattern matches the IDs generated by the web client and any
+// other URL-safe ID a script might choose
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Error codes used in ErrorResponse
+const (
+	codeInvalidRequest   = "invalid_request"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeConflict         = "conflict"
+	codeRateLimited      = "rate_limited"
+	codeUnavailable      = "unavailable"
+	codeBadGateway       = "bad_gateway"
+)
+
+// validator is implemented by request bodies that check their own fields
+type validator interface {
+	Validate() error
+}
+
+// ErrorResponse is the body of every non-2xx API response
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// CreateSessionRequest is the body of POST /api/sessions
+type CreateSessionRequest struct {
+	SessionID string `json:"sessionId"`
+	Moderator string `json:"moderator,omitempty"` // Optional: join as creator over REST
+}
+
+func (req CreateSessionRequest) Validate() error {
+	if !sessionIDPattern.MatchString(req.SessionID) {
+		return errors.New("sessionId must be 1-64 letters, digits, '-' or '_'")
+	}
+	if req.Moderator != "" {
+		return validateName("moderator", req.Moderator)
+	}
+	return nil
+}
+
+// CreateSessionResponse is returned by POST /api/sessions
+type CreateSessionResponse struct {
+	SessionID      string `json:"sessionId"`
+	Status         string `json:"status"`
+	ModeratorID    string `json:"moderatorId,omitempty"`
+	ModeratorToken string `json:"moderatorToken,omitempty"`
+}
+
+// SessionListResponse is returned by GET /api/sessions
+type SessionListResponse struct {
+	Sessions []string `json:"sessions"`
+}
+
+// JoinRequest is the body of POST /api/sessions/{id}/participants
+type JoinRequest struct {
+	Name      string `json:"name"`
+	Creator   bool   `json:"creator,omitempty"`
+	Transport string `json:"transport,omitempty"` // "poll" (default) or "rest"
+}
+
+func (req JoinRequest) Validate() error {
+	if err := validateName("name", req.Name); err != nil {
+		return err
+	}
+	switch participantKind(req.Transport) {
+	case "", participantPoll, participantREST:
+		return nil
+	default:
+		return fmt.Errorf("transport must be %q or %q", participantPoll, participantREST)
+	}
+}
+
+// JoinResponse identifies a participant joined without a WebSocket
+type JoinResponse struct {
+	UserID string `json:"userId"`
+	Token  string `json:"token"`
+}
+
+// VoteRequest is the body of POST /api/sessions/{id}/vote
+type VoteRequest struct {
+	Vote string `json:"vote"`
+}
+
+func (req VoteRequest) Validate() error {
+	if req.Vote == "" || utf8.RuneCountInString(req.Vote) > maxVoteLength {
+		return fmt.Errorf("vote must be 1-%d characters", maxVoteLength)
+	}
+	return nil
+}
+
+// StoryRequest is the body of POST /api/sessions/{id}/story
+type StoryRequest struct {
+	Story string `json:"story"`
+}
+
+func (req StoryRequest) Validate() error {
+	if utf8.RuneCountInString(req.Story) > maxStoryLength {
+		return fmt.Errorf("story must be at most %d characters", maxStoryLength)
+	}
+	return nil
+}
+
+// AddStoriesRequest is the body of POST /api/sessions/{id}/stories
+type AddStoriesRequest struct {
+	Stories []poker.Story `json:"stories"`
+}
+
+func (req AddStoriesRequest) Validate() error {
+	if len(req.Stories) == 0 {
+		return errors.New("stories must not be empty")
+	}
+	for _, story := range req.Stories {
+		if strings.TrimSpace(story.Title) == "" || utf8.RuneCountInString(story.Title) > maxStoryLength {
+			return fmt.Errorf("story titles must be 1-%d characters", maxStoryLength)
+		}
+	}
+	return nil
+}
+
+// EstimateRequest is the body of POST /api/sessions/{id}/estimate
+type EstimateRequest struct {
+	Estimate string `json:"estimate"`
+}
+
+func (req EstimateRequest) Validate() error {
+	if req.Estimate == "" || utf8.RuneCountInString(req.Estimate) > maxVoteLength {
+		return fmt.Errorf("estimate must be 1-%d characters", maxVoteLength)
+	}
+	return nil
+}
+
+// ImportRequest is the body of POST /api/sessions/{id}/import
+type ImportRequest struct {
+	Source string `json:"source"` // Tracker name, such as "jira"
+	Query  string `json:"query"`  // Tracker query, such as JQL
+}
+
+func (req ImportRequest) Validate() error {
+	if req.Source == "" {
+		return errors.New("source is required")
+	}
+	if strings.TrimSpace(req.Query) == "" || utf8.RuneCountInString(req.Query) > maxStoryLength {
+		return fmt.Errorf("query must be 1-%d characters", maxStoryLength)
+	}
+	return nil
+}
+
+// ImportResponse is returned by POST /api/sessions/{id}/import
+type ImportResponse struct {
+	Stories []poker.Story `json:"stories"` // Stories added to the queue
+}
+
+// WebhookRequest is the body of POST /api/sessions/{id}/webhooks
+type WebhookRequest struct {
+	URL    string            `json:"url"`
+	Secret string            `json:"secret,omitempty"` // Signs payloads when set
+	Events []poker.EventType `json:"events,omitempty"` // Every event when empty
+}
+
+func (req WebhookRequest) Validate() error {
+	if len(req.URL) > maxURLLength || !poker.ValidWebhookURL(req.URL) {
+		return fmt.Errorf("url must be an http or https URL of at most %d characters", maxURLLength)
+	}
+	if len(req.Secret) > maxSecretLength {
+		return fmt.Errorf("secret must be at most %d characters", maxSecretLength)
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(poker.EventTypes, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+// WebhookView is a session webhook as the API returns it; the secret is
+// never returned
+type WebhookView struct {
+	ID     string            `json:"id"`
+	URL    string            `json:"url"`
+	Events []poker.EventType `json:"events"`
+	Signed bool              `json:"signed"` // Whether a secret is set
+}
+
+// WebhooksResponse is returned by GET /api/sessions/{id}/webhooks
+type WebhooksResponse struct {
+	Webhooks []WebhookView `json:"webhooks"`
+}
+
+// DeliveriesResponse lists webhook deliveries, newest first
+type DeliveriesResponse struct {
+	Deliveries []webhook.Delivery `json:"deliveries"`
+}
+
+// MessageRequest is the body of POST /api/sessions/{id}/messages; it is the
+// same envelope a WebSocket client sends
+type MessageRequest poker.Message
+
+func (req MessageRequest) Validate() error {
+	switch req.Type {
+	case poker.MessageTypeVote:
+		return validateData(req.Data, &VoteRequest{})
+	case poker.MessageTypeSetStory:
+		return validateData(req.Data, &StoryRequest{})
+	case poker.MessageTypeAddStories:
+		return validateData(req.Data, &AddStoriesRequest{})
+	case poker.MessageTypeSetEstimate:
+		return validateData(req.Data, &EstimateRequest{})
+	case poker.MessageTypeReveal, poker.MessageTypeNewRound, poker.MessageTypeStartSession, poker.MessageTypeSync,
+		poker.MessageTypeNextStory, poker.MessageTypeEndSession:
+		return nil
+	default:
+		return fmt.Errorf("unsupported message type %q", req.Type)
+	}
+}
+
+// AcceptedResponse is returned when an action has been handed to the session
+type AcceptedResponse struct {
+	Status string `json:"status"`
+}
+
+// PollResponse is returned by GET /api/sessions/{id}/poll
+type PollResponse struct {
+	Messages []json.RawMessage `json:"messages"`
+}
+
+func validateName(field, name string) error {
+	if strings.TrimSpace(name) == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Errorf("%s must be 1-%d characters", field, maxNameLength)
+	}
+	return nil
+}
+
+// validateData decodes a message's data into v and validates it
+func validateData(data json.RawMessage, v validator) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("invalid message data")
+	}
+	return v.Validate()
+}
+
+// decodeRequest strictly decodes a JSON body into v and validates it,
+// writing an error response and returning false on failure
+func decodeRequest(w http.ResponseWriter, r *http.Request, v validator) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid JSON: "+err.Error())
+		return false
+	}
+	if decoder.More() {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid JSON: unexpected data after body")
+		return false
+	}
+
+	if err := v.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an ErrorResponse
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{Code: code, Message: message})
+}
+
+// methodNotAllowed writes a 405 listing the allowed methods
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+}
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// HandleOpenAPI serves the OpenAPI document describing the HTTP API
+func (s *Server) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
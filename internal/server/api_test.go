@@ -0,0 +1,196 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"planning-poker/internal/chat"
+	"planning-poker/internal/poker"
+	"planning-poker/internal/webhook"
+)
+
+type openAPIDoc struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPI(t *testing.T) openAPIDoc {
+	t.Helper()
+
+	req, _ := http.NewRequest("GET", "/api/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	New().HandleOpenAPI(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Expected application/json, got %s", contentType)
+	}
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("OpenAPI document is not valid JSON: %v", err)
+	}
+	return doc
+}
+
+func TestOpenAPIDocumentsRoutes(t *testing.T) {
+	doc := loadOpenAPI(t)
+
+	routes := map[string][]string{
+		"/api/sessions":                                  {"get", "post"},
+		"/api/sessions/{sessionId}":                      {"get"},
+		"/api/sessions/{sessionId}/events":               {"get"},
+		"/api/sessions/{sessionId}/participants":         {"post", "delete"},
+		"/api/sessions/{sessionId}/poll":                 {"get"},
+		"/api/sessions/{sessionId}/messages":             {"post"},
+		"/api/sessions/{sessionId}/vote":                 {"post"},
+		"/api/sessions/{sessionId}/reveal":               {"post"},
+		"/api/sessions/{sessionId}/new-round":            {"post"},
+		"/api/sessions/{sessionId}/story":                {"post"},
+		"/api/sessions/{sessionId}/start":                {"post"},
+		"/api/sessions/{sessionId}/stories":              {"post"},
+		"/api/sessions/{sessionId}/next-story":           {"post"},
+		"/api/sessions/{sessionId}/estimate":             {"post"},
+		"/api/sessions/{sessionId}/import":               {"post"},
+		"/api/sessions/{sessionId}/end":                  {"post"},
+		"/api/sessions/{sessionId}/webhooks":             {"get", "post"},
+		"/api/sessions/{sessionId}/webhooks/deliveries":  {"get"},
+		"/api/sessions/{sessionId}/webhooks/{webhookId}": {"delete"},
+		"/api/chat/command":                              {"post"},
+		"/healthz":                                       {"get"},
+		"/readyz":                                        {"get"},
+	}
+
+	for path, methods := range routes {
+		for _, method := range methods {
+			if _, ok := doc.Paths[path][method]; !ok {
+				t.Errorf("OpenAPI document is missing %s %s", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// TestOpenAPISchemasMatchTypes keeps the document in step with the JSON
+// tags of the request and response types
+func TestOpenAPISchemasMatchTypes(t *testing.T) {
+	doc := loadOpenAPI(t)
+
+	types := map[string]interface{}{
+		"ErrorResponse":         ErrorResponse{},
+		"CreateSessionRequest":  CreateSessionRequest{},
+		"CreateSessionResponse": CreateSessionResponse{},
+		"SessionListResponse":   SessionListResponse{},
+		"JoinRequest":           JoinRequest{},
+		"JoinResponse":          JoinResponse{},
+		"VoteRequest":           VoteRequest{},
+		"StoryRequest":          StoryRequest{},
+		"AddStoriesRequest":     AddStoriesRequest{},
+		"EstimateRequest":       EstimateRequest{},
+		"ImportRequest":         ImportRequest{},
+		"ImportResponse":        ImportResponse{},
+		"Story":                 poker.Story{},
+		"WebhookRequest":        WebhookRequest{},
+		"WebhookView":           WebhookView{},
+		"WebhooksResponse":      WebhooksResponse{},
+		"DeliveriesResponse":    DeliveriesResponse{},
+		"Delivery":              webhook.Delivery{},
+		"Event":                 poker.Event{},
+		"EventVote":             poker.EventVote{},
+		"ChatResponse":          chat.Response{},
+		"MessageRequest":        MessageRequest{},
+		"AcceptedResponse":      AcceptedResponse{},
+		"PollResponse":          PollResponse{},
+		"HealthResponse":        HealthResponse{},
+		"SessionState":          poker.SessionState{},
+		"ParticipantView":       poker.ParticipantView{},
+		"StatePatch":            poker.StatePatch{},
+		"ShutdownNotice":        poker.ShutdownNotice{},
+	}
+
+	for name, v := range types {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("OpenAPI document is missing schema %s", name)
+			continue
+		}
+
+		fields := make(map[string]bool)
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if tag == "" || tag == "-" {
+				continue
+			}
+			fields[tag] = true
+			if _, ok := schema.Properties[tag]; !ok {
+				t.Errorf("Schema %s is missing property %s", name, tag)
+			}
+		}
+		for property := range schema.Properties {
+			if !fields[property] {
+				t.Errorf("Schema %s has property %s not in %s", name, property, typ.Name())
+			}
+		}
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	server := New()
+
+	rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"VALID123","moderator":"ci-bot"}`)
+	var created CreateSessionResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	token := created.ModeratorToken
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		code   string
+	}{
+		{"unknown field", "POST", "/api/sessions", "", `{"sessionId":"ABC","extra":1}`, http.StatusBadRequest, codeInvalidRequest},
+		{"invalid session ID", "POST", "/api/sessions", "", `{"sessionId":"a b"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"missing session ID", "POST", "/api/sessions", "", `{}`, http.StatusBadRequest, codeInvalidRequest},
+		{"trailing data", "POST", "/api/sessions", "", `{"sessionId":"ABC"}{}`, http.StatusBadRequest, codeInvalidRequest},
+		{"blank name", "POST", "/api/sessions/VALID123/participants", "", `{"name":"  "}`, http.StatusBadRequest, codeInvalidRequest},
+		{"unknown transport", "POST", "/api/sessions/VALID123/participants", "", `{"name":"Bob","transport":"pigeon"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"empty vote", "POST", "/api/sessions/VALID123/vote", token, `{"vote":""}`, http.StatusBadRequest, codeInvalidRequest},
+		{"long vote", "POST", "/api/sessions/VALID123/vote", token, `{"vote":"` + strings.Repeat("9", maxVoteLength+1) + `"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"long story", "POST", "/api/sessions/VALID123/story", token, `{"story":"` + strings.Repeat("x", maxStoryLength+1) + `"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"server message type", "POST", "/api/sessions/VALID123/messages", token, `{"type":"session_state"}`, http.StatusBadRequest, codeInvalidRequest},
+		{"invalid message data", "POST", "/api/sessions/VALID123/messages", token, `{"type":"vote","data":{"vote":7}}`, http.StatusBadRequest, codeInvalidRequest},
+		{"unknown resource", "GET", "/api/sessions/VALID123/unknown", "", "", http.StatusNotFound, codeNotFound},
+		{"unknown session", "GET", "/api/sessions/NOPE", "", "", http.StatusNotFound, codeNotFound},
+		{"wrong method", "PUT", "/api/sessions/VALID123", "", "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := restCall(server, tt.method, tt.path, tt.token, tt.body)
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+
+			var response ErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Expected JSON error body, got %q", rr.Body.String())
+			}
+			if response.Code != tt.code || response.Message == "" {
+				t.Errorf("Expected code %s with a message, got %+v", tt.code, response)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"planning-poker/internal/chat"
+	"planning-poker/internal/poker"
+)
+
+// notifyTimeout bounds posting one event to a chat channel
+const notifyTimeout = 10 * time.Second
+
+// sessionIDChars are the characters of generated session IDs; like the web
+// client, it leaves out characters that are easily confused such as O and 0
+const sessionIDChars = "ABCDEFGHIJKLMNPQRSTUVWXYZ123456789"
+
+// Notifier posts session events somewhere people read them, such as a
+// Slack channel
+type Notifier interface {
+	Notify(ctx context.Context, event poker.Event) error
+}
+
+// AddNotifier makes every session's events go to n. It must be called
+// before the server handles requests.
+func (s *Server) AddNotifier(n Notifier) {
+	s.notifiers = append(s.notifiers, n)
+}
+
+// notify passes one event to a notifier, logging failures
+func (s *Server) notify(n Notifier, event poker.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	if err := n.Notify(ctx, event); err != nil {
+		slog.Warn("Failed to post chat notification", "session_id", event.SessionID, "event", event.Type, "error", err)
+	}
+}
+
+// HandleChatCommand serves the /poker slash command of Slack and
+// Mattermost: /poker <story> creates a session through the same path as
+// POST /api/sessions and sets the story. The user who ran the command gets
+// the moderator link in a reply only they see, and the channel gets the
+// join link through the command's response URL.
+func (s *Server) HandleChatCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	cfg := s.Config()
+	if cfg == nil || !cfg.ChatCommandEnabled() {
+		writeError(w, http.StatusNotFound, codeNotFound, "Not found")
+		return
+	}
+
+	cmd, err := chat.ParseCommand(r, chat.Verifier{SigningSecret: cfg.ChatSigningSecret, Token: cfg.ChatCommandToken})
+	if errors.Is(err, chat.ErrUnauthorized) {
+		requestLogger(r).Warn("Rejected chat command", "error", err)
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	// Chat services show replies to the user, so problems are reported
+	// in a 200 response rather than as HTTP errors
+	story := cmd.Text
+	switch {
+	case story == "":
+		writeJSON(w, http.StatusOK, chat.Ephemeral("Usage: "+commandName(cmd)+" <story to estimate>"))
+		return
+	case utf8.RuneCountInString(story) > maxStoryLength:
+		writeJSON(w, http.StatusOK, chat.Ephemeral("Stories are limited to 1000 characters."))
+		return
+	case s.Draining():
+		writeJSON(w, http.StatusOK, chat.Ephemeral("The server is restarting. Please try again in a moment."))
+		return
+	}
+
+	moderator := cmd.UserName
+	if validateName("user_name", moderator) != nil {
+		moderator = "chat"
+	}
+
+	sessionID := s.newSessionID()
+	_, p, err := s.createSession(r, CreateSessionRequest{SessionID: sessionID, Moderator: moderator})
+	if err != nil {
+		writeJSON(w, http.StatusOK, chat.Ephemeral("Sorry, the session could not be created."))
+		return
+	}
+
+	// The REST moderator only sets the story; whoever opens the moderator
+	// link becomes the creator
+	msg := poker.Message{Type: poker.MessageTypeSetStory, Data: mustJSON(StoryRequest{Story: story})}
+	if err := p.session.HandleMessage(p.userID, msg); err != nil {
+		requestLogger(r).Warn("Failed to set chat command story", "session_id", sessionID, "error", err)
+	}
+	s.leaveFallback(p)
+
+	logger := requestLogger(r).With("session_id", sessionID)
+	logger.Info("Session created from chat", "user", cmd.UserName, "channel_id", cmd.ChannelID)
+	if cmd.ResponseURL != "" {
+		announcement := chat.InChannel(chat.SessionCreatedText(cfg.PublicURL, sessionID, story, cmd.UserName))
+		go s.announce(logger, cmd.ResponseURL, announcement)
+	}
+	writeJSON(w, http.StatusOK, chat.Ephemeral(chat.ModeratorText(cfg.PublicURL, sessionID, story)))
+}
+
+// announce posts a session created from chat to the command's channel,
+// logging failures
+func (s *Server) announce(logger *slog.Logger, responseURL string, resp chat.Response) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	if err := chat.Reply(ctx, nil, responseURL, resp); err != nil {
+		logger.Warn("Failed to announce chat session", "error", err)
+	}
+}
+
+// newSessionID returns a random six-character session ID that is not in
+// use on this instance
+func (s *Server) newSessionID() string {
+	for {
+		id := make([]byte, 6)
+		for i := range id {
+			id[i] = sessionIDChars[randIndex(len(sessionIDChars))]
+		}
+
+		s.mu.RLock()
+		_, exists := s.sessions[string(id)]
+		s.mu.RUnlock()
+		if !exists {
+			return string(id)
+		}
+	}
+}
+
+func randIndex(n int) int {
+	var b [1]byte
+	for {
+		rand.Read(b[:])
+		// Reject values that would bias the result
+		if int(b[0]) < 256-256%n {
+			return int(b[0]) % n
+		}
+	}
+}
+
+func commandName(cmd chat.Command) string {
+	if cmd.Command == "" {
+		return "/poker"
+	}
+	return cmd.Command
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"planning-poker/internal/chat"
+	"planning-poker/internal/config"
+	"planning-poker/internal/poker"
+)
+
+// fakeNotifier passes on the events it is given
+type fakeNotifier chan poker.Event
+
+func (f fakeNotifier) Notify(ctx context.Context, event poker.Event) error {
+	f <- event
+	return nil
+}
+
+func newChatServer(t *testing.T) *Server {
+	cfg, _ := config.Load(nil)
+	cfg.PublicURL = "https://poker.example.com"
+	cfg.ChatCommandToken = "xr3j5x3p4pfq7ns3ntxmbuw3ky"
+	return NewWithConfig(cfg)
+}
+
+// chatCommand posts the Mattermost fixture with the given form fields
+// replaced. The fixture's response URL is dropped unless fields sets one.
+func chatCommand(t *testing.T, server *Server, token string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := os.ReadFile("../chat/testdata/mattermost_command.txt")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	form, _ := url.ParseQuery(string(body))
+	form.Del("response_url")
+	for name, value := range fields {
+		form.Set(name, value)
+	}
+	form.Set("token", token)
+
+	req := httptest.NewRequest("POST", "/api/chat/command", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	server.HandleChatCommand(rr, req)
+	return rr
+}
+
+func TestChatCommandCreatesSession(t *testing.T) {
+	server := newChatServer(t)
+
+	announced := make(chan chat.Response, 1)
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp chat.Response
+		json.NewDecoder(r.Body).Decode(&resp)
+		announced <- resp
+	}))
+	defer channel.Close()
+
+	rr := chatCommand(t, server, "xr3j5x3p4pfq7ns3ntxmbuw3ky", map[string]string{"response_url": channel.URL})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	// Only the user who ran the command sees the moderator link
+	var reply chat.Response
+	json.Unmarshal(rr.Body.Bytes(), &reply)
+	if reply.ResponseType != "ephemeral" || !strings.Contains(reply.Text, "Planning poker for *Logout button* is ready") {
+		t.Errorf("Unexpected reply %+v", reply)
+	}
+	match := regexp.MustCompile(`https://poker\.example\.com/\?session=([A-Z1-9]{6})&creator=true\|`).FindStringSubmatch(reply.Text)
+	if match == nil {
+		t.Fatalf("Expected a moderator link in %q", reply.Text)
+	}
+
+	// The channel gets the join link only
+	select {
+	case resp := <-announced:
+		joinLink := "<https://poker.example.com/?session=" + match[1] + "|"
+		if resp.ResponseType != "in_channel" || !strings.Contains(resp.Text, "bob started planning poker for *Logout button*") ||
+			!strings.Contains(resp.Text, joinLink) || strings.Contains(resp.Text, "creator=true") {
+			t.Errorf("Unexpected announcement %+v", resp)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Session was not announced in the channel")
+	}
+
+	rr = restCall(server, "GET", "/api/sessions/"+match[1], "", "")
+	var state poker.SessionState
+	json.Unmarshal(rr.Body.Bytes(), &state)
+	if state.CurrentStory != "Logout button" || len(state.Users) != 0 {
+		t.Errorf("Expected an empty session with the story set, got %s", rr.Body.String())
+	}
+}
+
+func TestChatCommandErrors(t *testing.T) {
+	server := newChatServer(t)
+
+	if rr := chatCommand(t, server, "wrong-token", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong token to be rejected, got %d", rr.Code)
+	}
+
+	rr := chatCommand(t, server, "xr3j5x3p4pfq7ns3ntxmbuw3ky", map[string]string{"text": ""})
+	var reply chat.Response
+	json.Unmarshal(rr.Body.Bytes(), &reply)
+	if reply.ResponseType != "ephemeral" || !strings.HasPrefix(reply.Text, "Usage: /poker") {
+		t.Errorf("Expected usage help, got %+v", reply)
+	}
+
+	// Without a token or signing secret the command is not served
+	rr = httptest.NewRecorder()
+	New().HandleChatCommand(rr, httptest.NewRequest("POST", "/api/chat/command", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when disabled, got %d", rr.Code)
+	}
+}
+
+func TestNotifiersReceiveEvents(t *testing.T) {
+	server := New()
+	events := make(fakeNotifier, 1)
+	server.AddNotifier(events)
+
+	rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"CHAT1","moderator":"ci-bot"}`)
+	var created CreateSessionResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	restCall(server, "POST", "/api/sessions/CHAT1/start", created.ModeratorToken, "")
+
+	select {
+	case event := <-events:
+		if event.Type != poker.EventSessionStarted || event.SessionID != "CHAT1" {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Notifier was not called")
+	}
+}
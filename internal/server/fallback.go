@@ -0,0 +1,355 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"planning-poker/internal/poker"
+
+	"github.com/google/uuid"
+)
+
+// Fallback transports for clients whose network blocks WebSocket upgrades.
+// Participants receive messages through either a Server-Sent Events stream
+// or long-polling, and send messages with plain POST requests. Both use the
+// same poker.Session calls as the WebSocket handler.
+
+const (
+	sseKeepAlive   = 15 * time.Second // Comment frames keep proxies from timing out
+	longPollWait   = 25 * time.Second // How long a poll waits for new messages
+	longPollExpiry = 60 * time.Second // Long-poll participants are removed after this long without a request
+	restExpiry     = time.Hour        // REST participants are removed after this long without a request
+
+	participantTokenHeader = "X-Participant-Token"
+)
+
+// participantKind says how a token-identified participant receives messages
+type participantKind string
+
+const (
+	participantStream participantKind = "stream" // Server-Sent Events
+	participantPoll   participantKind = "poll"   // Long-polling
+	participantREST   participantKind = "rest"   // Sends only; reads state with GET
+)
+
+// participant is a user connected without a WebSocket. It is identified by
+// a secret token instead of an open socket.
+type participant struct {
+	token     string
+	kind      participantKind
+	session   *poker.Session
+	userID    string
+	transport *queueTransport // Nil for REST participants
+	expiry    *time.Timer     // Removes idle poll and REST participants
+	logger    *slog.Logger    // Carries the session and user IDs
+	limiter   rateLimiter     // Limits the messages the participant sends
+}
+
+// joinFallback adds a token-identified user to a session. Stream and poll
+// participants get a message queue; REST participants receive nothing.
+func (s *Server) joinFallback(r *http.Request, sessionID, userName string, isCreator bool, kind participantKind) (*participant, error) {
+	session, err := s.getOrCreateSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &participant{
+		token:   uuid.New().String(),
+		kind:    kind,
+		session: session,
+	}
+
+	var conn poker.Connection
+	if kind != participantREST {
+		p.transport = newQueueTransport()
+		conn = p.transport
+	}
+	p.userID = session.AddUser(userName, conn, isCreator).ID
+	p.logger = requestLogger(r).With("session_id", sessionID, "user_id", p.userID)
+
+	switch kind {
+	case participantPoll:
+		p.expiry = time.AfterFunc(longPollExpiry, func() { s.expireParticipant(p) })
+	case participantREST:
+		p.expiry = time.AfterFunc(restExpiry, func() { s.expireParticipant(p) })
+	}
+
+	s.mu.Lock()
+	s.participants[p.token] = p
+	s.mu.Unlock()
+	s.connections.Inc(string(kind))
+
+	p.logger.Info("User joined", "user", userName, "transport", kind, "creator", isCreator)
+	return p, nil
+}
+
+// touch postpones the idle expiry of poll and REST participants
+func (p *participant) touch() {
+	switch p.kind {
+	case participantPoll:
+		p.expiry.Reset(longPollExpiry)
+	case participantREST:
+		p.expiry.Reset(restExpiry)
+	}
+}
+
+func (s *Server) expireParticipant(p *participant) {
+	p.logger.Info("Participant expired", "transport", p.kind)
+	s.leaveFallback(p)
+}
+
+// leaveFallback removes a fallback participant; it is safe to call twice
+func (s *Server) leaveFallback(p *participant) {
+	s.mu.Lock()
+	_, exists := s.participants[p.token]
+	delete(s.participants, p.token)
+	s.mu.Unlock()
+
+	if !exists {
+		return
+	}
+	s.connections.Dec(string(p.kind))
+
+	if p.expiry != nil {
+		p.expiry.Stop()
+	}
+	if p.transport != nil {
+		p.transport.Close()
+	}
+	p.session.RemoveUser(p.userID)
+}
+
+// lookupParticipant finds the participant for the request's token, taken
+// from the X-Participant-Token header, a bearer Authorization header or the
+// token query parameter
+func (s *Server) lookupParticipant(r *http.Request, sessionID string) *participant {
+	token := r.Header.Get(participantTokenHeader)
+	if token == "" {
+		token, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+
+	s.mu.RLock()
+	p, exists := s.participants[token]
+	s.mu.RUnlock()
+
+	if !exists || p.session.ID != sessionID {
+		return nil
+	}
+	return p
+}
+
+// handleEvents streams session messages as Server-Sent Events. The first
+// event, "connected", carries the token needed to POST messages.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	userName := r.URL.Query().Get("user")
+	isCreator := r.URL.Query().Get("creator") == "true"
+	if !sessionIDPattern.MatchString(sessionID) {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid session ID")
+		return
+	}
+	if err := validateName("user", userName); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	if !s.startHandler() {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Server is shutting down")
+		return
+	}
+	defer s.handlers.Done()
+
+	p, err := s.joinFallback(r, sessionID, userName, isCreator, participantStream)
+	if err != nil {
+		requestLogger(r).Error("Failed to open session", "session_id", sessionID, "error", err)
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Failed to join session")
+		return
+	}
+	defer s.leaveFallback(p)
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx response buffering
+
+	fmt.Fprintf(w, "event: connected\ndata: %s\n\n", mustJSON(map[string]string{
+		"userId": p.userID,
+		"token":  p.token,
+	}))
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	closed := false
+	for {
+		for _, msg := range p.transport.drain() {
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", msg); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		if closed {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-p.transport.done:
+			// Deliver what was queued before closing, such as a
+			// server_shutdown notice
+			closed = true
+		case <-p.transport.notify:
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleParticipants joins (POST) or leaves (DELETE) a session for
+// long-polling and REST clients
+func (s *Server) handleParticipants(w http.ResponseWriter, r *http.Request, sessionID string) {
+	switch r.Method {
+	case http.MethodPost:
+		if s.Draining() {
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Server is shutting down")
+			return
+		}
+		if !sessionIDPattern.MatchString(sessionID) {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid session ID")
+			return
+		}
+
+		var req JoinRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		kind := participantKind(req.Transport)
+		if kind == "" {
+			kind = participantPoll
+		}
+
+		p, err := s.joinFallback(r, sessionID, req.Name, req.Creator, kind)
+		if err != nil {
+			requestLogger(r).Error("Failed to open session", "session_id", sessionID, "error", err)
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Failed to join session")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, JoinResponse{UserID: p.userID, Token: p.token})
+
+	case http.MethodDelete:
+		p := s.lookupParticipant(r, sessionID)
+		if p == nil {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Unknown participant token")
+			return
+		}
+		s.leaveFallback(p)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		methodNotAllowed(w, http.MethodPost, http.MethodDelete)
+	}
+}
+
+// handlePoll returns queued messages, waiting up to longPollWait for new
+// ones when the queue is empty
+func (s *Server) handlePoll(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	p := s.lookupParticipant(r, sessionID)
+	if p == nil {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Unknown participant token")
+		return
+	}
+	if p.kind != participantPoll {
+		writeError(w, http.StatusConflict, codeConflict, "Participant is not long-polling")
+		return
+	}
+	p.touch()
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(2 * longPollWait))
+
+	timer := time.NewTimer(longPollWait)
+	defer timer.Stop()
+
+	// The notify signal may be left over from messages already drained,
+	// so keep waiting until something is actually queued
+	messages := p.transport.drain()
+wait:
+	for len(messages) == 0 {
+		select {
+		case <-p.transport.notify:
+			messages = p.transport.drain()
+		case <-p.transport.done:
+			messages = p.transport.drain()
+			break wait
+		case <-timer.C:
+			break wait
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if messages == nil {
+		messages = []json.RawMessage{}
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJSON(w, http.StatusOK, PollResponse{Messages: messages})
+}
+
+// handleMessages accepts a client message from a fallback participant and
+// handles it exactly as if it had arrived over the WebSocket
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	p := s.lookupParticipant(r, sessionID)
+	if p == nil {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Unknown participant token")
+		return
+	}
+	p.touch()
+
+	var req MessageRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	s.dispatchMessage(w, r, p, poker.Message(req))
+}
+
+// mustJSON encodes v for embedding in an event stream
+func mustJSON(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("JSON marshal error", "error", err)
+		return []byte("{}")
+	}
+	return data
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// readSSEEvent reads one event from an event stream, skipping comments
+func readSSEEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+
+	event, data := "message", ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+
+		switch {
+		case line == "" && data != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func postMessage(t *testing.T, url, token string, msg poker.Message) *http.Response {
+	t.Helper()
+
+	body, _ := json.Marshal(msg)
+	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(participantTokenHeader, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestEventStream(t *testing.T) {
+	server := New()
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleSession))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/sessions/SSE123/events?user=Alice&creator=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %s", ct)
+	}
+
+	stream := bufio.NewReader(resp.Body)
+	event, data := readSSEEvent(t, stream)
+	if event != "connected" {
+		t.Fatalf("Expected first event 'connected', got %s", event)
+	}
+	var connected map[string]string
+	json.Unmarshal([]byte(data), &connected)
+	if connected["token"] == "" || connected["userId"] == "" {
+		t.Fatalf("Expected token and userId in connected event, got %s", data)
+	}
+
+	// The join patch is followed by the initial snapshot
+	var msg poker.Message
+	for msg.Type != poker.MessageTypeSessionState {
+		_, data = readSSEEvent(t, stream)
+		json.Unmarshal([]byte(data), &msg)
+	}
+
+	// Send a vote through the POST endpoint
+	messagesURL := ts.URL + "/api/sessions/SSE123/messages"
+	resp2 := postMessage(t, messagesURL, connected["token"], poker.Message{
+		Type: poker.MessageTypeVote,
+		Data: json.RawMessage(`{"vote":"5"}`),
+	})
+	if resp2.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected status %d, got %d", http.StatusAccepted, resp2.StatusCode)
+	}
+
+	_, data = readSSEEvent(t, stream)
+	json.Unmarshal([]byte(data), &msg)
+	if msg.Type != poker.MessageTypeStatePatch {
+		t.Fatalf("Expected state_patch after vote, got %s", msg.Type)
+	}
+	var patch poker.StatePatch
+	json.Unmarshal(msg.Data, &patch)
+	if patch.Op != poker.MessageTypeVote || patch.UserID != connected["userId"] {
+		t.Errorf("Expected vote patch for %s, got %+v", connected["userId"], patch)
+	}
+}
+
+func TestEventStream_MissingUser(t *testing.T) {
+	server := New()
+
+	req, _ := http.NewRequest("GET", "/api/sessions/SSE123/events", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.HandleSession).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestLongPolling(t *testing.T) {
+	server := New()
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleSession))
+	defer ts.Close()
+
+	participantsURL := ts.URL + "/api/sessions/POLL123/participants"
+	resp, err := http.Post(participantsURL, "application/json", strings.NewReader(`{"name":"Alice","creator":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var joined map[string]string
+	json.NewDecoder(resp.Body).Decode(&joined)
+	resp.Body.Close()
+
+	token := joined["token"]
+	if token == "" {
+		t.Fatal("Expected token in join response")
+	}
+
+	poll := func() []poker.Message {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/sessions/POLL123/poll", nil)
+		req.Header.Set(participantTokenHeader, token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected poll status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+
+		var body struct {
+			Messages []poker.Message `json:"messages"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body.Messages
+	}
+
+	messages := poll()
+	if len(messages) == 0 || messages[len(messages)-1].Type != poker.MessageTypeSessionState {
+		t.Fatalf("Expected queued session_state, got %v", messages)
+	}
+
+	// A waiting poll is answered as soon as a message is queued
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		postMessage(t, ts.URL+"/api/sessions/POLL123/messages", token, poker.Message{Type: poker.MessageTypeStartSession})
+	}()
+
+	start := time.Now()
+	messages = poll()
+	if time.Since(start) > longPollWait/2 {
+		t.Error("Expected poll to return when a message arrived")
+	}
+	if len(messages) == 0 || messages[0].Type != poker.MessageTypeStartSession {
+		t.Errorf("Expected start_session message, got %v", messages)
+	}
+
+	// Leaving invalidates the token
+	req, _ := http.NewRequest("DELETE", participantsURL, nil)
+	req.Header.Set(participantTokenHeader, token)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+
+	session := server.sessions["POLL123"]
+	if state := session.GetState(); len(state.Users) != 0 {
+		t.Errorf("Expected participant to be removed from session")
+	}
+}
+
+func TestMessages_UnknownToken(t *testing.T) {
+	server := New()
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleSession))
+	defer ts.Close()
+
+	resp := postMessage(t, ts.URL+"/api/sessions/ANY/messages", "not-a-token", poker.Message{Type: poker.MessageTypeReveal})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
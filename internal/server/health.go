@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// shutdownReconnectAfter is the reconnect hint sent to clients on shutdown:
+// long enough for the load balancer to notice the failing readiness check
+const shutdownReconnectAfter = 2 * time.Second
+
+// HealthResponse is returned by the liveness and readiness checks
+type HealthResponse struct {
+	Status       string            `json:"status"` // "ok", "ready" or "not_ready"
+	Service      string            `json:"service"`
+	Draining     bool              `json:"draining"`
+	Checks       map[string]string `json:"checks"` // Dependency name to "ok" or the error; empty for liveness
+	Sessions     int               `json:"sessions"`
+	Participants int               `json:"participants"`
+	Connections  int               `json:"connections"`
+}
+
+// Drain marks the server as shutting down. Readiness checks fail from then
+// on so load balancers stop sending new clients, while existing
+// connections are served until shutdown.
+func (s *Server) Drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.draining.Store(true)
+}
+
+// Draining reports whether Drain has been called
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// startHandler registers a long-lived connection handler with the
+// handlers wait group, or returns false once the server is draining. The
+// check and Add happen under s.mu so that Shutdown never waits while a
+// handler is being added.
+func (s *Server) startHandler() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Draining() {
+		return false
+	}
+	s.handlers.Add(1)
+	return true
+}
+
+// Shutdown drains the server and disconnects every client. Readiness fails
+// for the configured drain delay first, or until ctx is done, so load
+// balancers stop routing here before clients reconnect. Then participants
+// connected to this instance get a server_shutdown notice with a
+// reconnect hint, and their WebSockets and event streams are closed. It
+// waits until those handlers have exited and pending webhook deliveries
+// are done, or ctx is done. Session state is not persisted here: there is
+// no session store, and with a shared bus the sessions continue on the
+// other instances.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+
+	if cfg := s.Config(); cfg != nil && cfg.DrainDelay > 0 {
+		select {
+		case <-time.After(cfg.DrainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	s.mu.RLock()
+	sessions := make([]*poker.Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.mu.RUnlock()
+
+	var notified sync.WaitGroup
+	for _, session := range sessions {
+		notified.Add(1)
+		go func() {
+			defer notified.Done()
+			session.Shutdown(shutdownReconnectAfter)
+		}()
+	}
+	notified.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.webhooks.Close(ctx)
+		return ctx.Err()
+	}
+
+	for _, session := range sessions {
+		session.Close()
+	}
+	return s.webhooks.Close(ctx)
+}
+
+// status gathers the server's own state, without checking dependencies
+func (s *Server) status() HealthResponse {
+	sessions, participants := s.counts()
+
+	response := HealthResponse{
+		Service:      "planning-poker",
+		Draining:     s.Draining(),
+		Checks:       map[string]string{},
+		Sessions:     sessions,
+		Participants: participants,
+	}
+	for _, count := range s.connectionCounts() {
+		response.Connections += count
+	}
+	return response
+}
+
+// health gathers the current health, including dependency checks, and
+// whether the server can take new clients
+func (s *Server) health() (HealthResponse, bool) {
+	response := s.status()
+	response.Checks["bus"] = "ok"
+
+	ready := !response.Draining
+	if err := s.bus.Ping(); err != nil {
+		response.Checks["bus"] = err.Error()
+		ready = false
+	}
+	return response, ready
+}
+
+// HandleLiveness reports that the process is up and serving requests. It
+// succeeds while draining, so orchestrators do not restart an instance
+// that is shutting down cleanly, and does not check dependencies, so a slow
+// or unreachable Redis does not get healthy instances restarted.
+func (s *Server) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	response := s.status()
+	response.Status = "ok"
+	writeJSON(w, http.StatusOK, response)
+}
+
+// HandleReadiness reports whether the server should receive new clients:
+// it fails while draining or when the session bus is unreachable
+func (s *Server) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	response, ready := s.health()
+	status := http.StatusOK
+	response.Status = "ready"
+	if !ready {
+		status = http.StatusServiceUnavailable
+		response.Status = "not_ready"
+	}
+	writeJSON(w, status, response)
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"planning-poker/internal/bus"
+	"planning-poker/internal/config"
+	"planning-poker/internal/poker"
+
+	"github.com/gorilla/websocket"
+)
+
+// unreachableBus is a bus whose backend cannot be reached
+type unreachableBus struct {
+	*bus.Local
+}
+
+func (unreachableBus) Ping() error {
+	return errors.New("connection refused")
+}
+
+func healthCheck(t *testing.T, handler http.HandlerFunc) (int, HealthResponse) {
+	t.Helper()
+
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+
+	var response HealthResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not parse health response: %v", err)
+	}
+	return rr.Code, response
+}
+
+func TestReadinessWhileDraining(t *testing.T) {
+	server := New()
+	restCall(server, "POST", "/api/sessions", "", `{"sessionId":"HEALTH1","moderator":"ci-bot"}`)
+
+	status, response := healthCheck(t, server.HandleReadiness)
+	if status != http.StatusOK || response.Status != "ready" {
+		t.Errorf("Expected ready, got %d %+v", status, response)
+	}
+	if response.Sessions != 1 || response.Participants != 1 || response.Checks["bus"] != "ok" {
+		t.Errorf("Unexpected health details %+v", response)
+	}
+
+	server.Drain()
+
+	status, response = healthCheck(t, server.HandleReadiness)
+	if status != http.StatusServiceUnavailable || response.Status != "not_ready" || !response.Draining {
+		t.Errorf("Expected not ready while draining, got %d %+v", status, response)
+	}
+
+	// Liveness must keep passing so the instance is not restarted mid-drain
+	status, response = healthCheck(t, server.HandleLiveness)
+	if status != http.StatusOK || response.Status != "ok" || !response.Draining {
+		t.Errorf("Expected live while draining, got %d %+v", status, response)
+	}
+}
+
+func TestReadinessWithUnreachableBus(t *testing.T) {
+	server := NewWithBus(nil, unreachableBus{bus.NewLocal()})
+
+	status, response := healthCheck(t, server.HandleReadiness)
+	if status != http.StatusServiceUnavailable || response.Status != "not_ready" {
+		t.Errorf("Expected not ready, got %d %+v", status, response)
+	}
+	if response.Checks["bus"] != "connection refused" {
+		t.Errorf("Expected bus error in checks, got %v", response.Checks)
+	}
+
+	status, response = healthCheck(t, server.HandleLiveness)
+	if status != http.StatusOK || len(response.Checks) != 0 {
+		t.Errorf("Expected liveness to pass without checking the bus, got %d %+v", status, response)
+	}
+}
+
+func TestShutdownNotifiesClients(t *testing.T) {
+	server := New()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", server.HandleWebSocket)
+	mux.HandleFunc("/api/sessions/", server.HandleSession)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?session=SHUT1&user=Alice&creator=true", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	resp, err := http.Get(ts.URL + "/api/sessions/SHUT1/events?user=Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	stream := bufio.NewReader(resp.Body)
+	readSSEEvent(t, stream)
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownErr <- server.Shutdown(ctx)
+	}()
+
+	// The WebSocket client gets the notice, then a service restart close
+	var notice poker.ShutdownNotice
+	for {
+		var msg poker.Message
+		err := conn.ReadJSON(&msg)
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseServiceRestart) {
+				t.Errorf("Expected close code %d, got %v", websocket.CloseServiceRestart, err)
+			}
+			break
+		}
+		if msg.Type == poker.MessageTypeServerShutdown {
+			json.Unmarshal(msg.Data, &notice)
+		}
+	}
+	if notice.ReconnectAfterMs != shutdownReconnectAfter.Milliseconds() {
+		t.Errorf("Expected server_shutdown with reconnect hint, got %+v", notice)
+	}
+
+	// The event stream client gets the notice, then the stream ends
+	var msg poker.Message
+	for msg.Type != poker.MessageTypeServerShutdown {
+		_, data := readSSEEvent(t, stream)
+		json.Unmarshal([]byte(data), &msg)
+	}
+	if _, err := io.ReadAll(stream); err != nil {
+		t.Errorf("Expected event stream to end cleanly, got %v", err)
+	}
+
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Expected Shutdown to return once clients left, got %v", err)
+	}
+}
+
+func TestShutdownWaitsForDrainDelay(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	server := NewWithConfig(&config.Config{Port: "8080", MaxMessageSize: 1024, LogLevel: "info", DrainDelay: delay})
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?session=SHUT3&user=Alice&creator=true", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	go server.Shutdown(context.Background())
+
+	// Clients are only told to reconnect once load balancers have had the
+	// drain delay to notice the failing readiness check
+	for {
+		var msg poker.Message
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if msg.Type != poker.MessageTypeServerShutdown {
+			continue
+		}
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Errorf("Expected the notice after the %v drain delay, got it after %v", delay, elapsed)
+		}
+		break
+	}
+	if !server.Draining() {
+		t.Error("Expected the server to be draining")
+	}
+
+	// The delay never outlasts the shutdown deadline
+	server = NewWithConfig(&config.Config{Port: "8080", MaxMessageSize: 1024, LogLevel: "info", DrainDelay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- server.Shutdown(ctx) }()
+	select {
+	case <-shutdownErr:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Shutdown to stop waiting when its context ends")
+	}
+}
+
+func TestJoinRejectedWhileDraining(t *testing.T) {
+	server := New()
+	server.Drain()
+
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	defer ts.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?session=SHUT2&user=Alice", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected WebSocket join to fail with 503 while draining, got %v", err)
+	}
+
+	rr := restCall(server, "POST", "/api/sessions/SHUT2/participants", "", `{"name":"Bob"}`)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected participant join to fail with 503 while draining, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// Both limits read the current configuration each time they are applied,
+// so a reload changes them for sessions and connections that are already
+// open.
+
+// ExpireSessions removes the sessions held by this instance that are older
+// than SESSION_TIMEOUT and have nobody in them, and returns how many it
+// removed. A session in use is kept until its last participant leaves.
+func (s *Server) ExpireSessions(now time.Time) int {
+	cfg := s.Config()
+	if cfg == nil || cfg.SessionTimeout <= 0 {
+		return 0
+	}
+
+	s.mu.Lock()
+	var expired []*poker.Session
+	for id, session := range s.sessions {
+		state := session.GetState()
+		if len(state.Users) == 0 && now.Sub(state.CreatedAt) >= cfg.SessionTimeout {
+			delete(s.sessions, id)
+			expired = append(expired, session)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, session := range expired {
+		session.Close()
+		slog.Info("Session expired", "session_id", session.ID)
+	}
+	return len(expired)
+}
+
+// errRateLimited rejects messages beyond MESSAGE_RATE_LIMIT
+var errRateLimited = errors.New("too many messages, slow down")
+
+// messageRateLimit returns how many messages per second a participant may
+// send, or 0 for no limit
+func (s *Server) messageRateLimit() int {
+	if cfg := s.Config(); cfg != nil {
+		return cfg.MessageRateLimit
+	}
+	return 0
+}
+
+// rateLimiter is a token bucket holding up to one second's worth of
+// messages, so a participant may send short bursts but not sustain more
+// than the limit
+type rateLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allow reports whether a message sent at now fits within limit messages
+// per second, and uses up its share of the bucket if so
+func (l *rateLimiter) allow(now time.Time, limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.last.IsZero() {
+		l.tokens = float64(limit)
+	} else {
+		l.tokens = min(float64(limit), l.tokens+now.Sub(l.last).Seconds()*float64(limit))
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
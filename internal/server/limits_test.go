@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"planning-poker/internal/config"
+)
+
+func limitsConfig() *config.Config {
+	return &config.Config{
+		Port:             "8080",
+		MaxMessageSize:   1024,
+		LogLevel:         "info",
+		SessionTimeout:   2 * time.Hour,
+		MessageRateLimit: 2,
+	}
+}
+
+func TestExpireSessionsFollowsReload(t *testing.T) {
+	cfg := limitsConfig()
+	server := NewWithConfig(cfg)
+
+	if _, err := server.getOrCreateSession("EMPTY"); err != nil {
+		t.Fatal(err)
+	}
+	busy, err := server.getOrCreateSession("BUSY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy.AddUser("Alice", nil, true)
+
+	later := time.Now().Add(time.Hour)
+	if expired := server.ExpireSessions(later); expired != 0 {
+		t.Fatalf("Expected no session to expire within the timeout, got %d", expired)
+	}
+
+	next := *cfg
+	next.SessionTimeout = 30 * time.Minute
+	if changed, _ := server.Reload(&next); len(changed) != 1 || changed[0] != "SESSION_TIMEOUT" {
+		t.Fatalf("Expected SESSION_TIMEOUT to change, got %v", changed)
+	}
+
+	if expired := server.ExpireSessions(later); expired != 1 {
+		t.Fatalf("Expected the empty session to expire after the reload, got %d", expired)
+	}
+	server.mu.RLock()
+	_, emptyKept := server.sessions["EMPTY"]
+	_, busyKept := server.sessions["BUSY"]
+	server.mu.RUnlock()
+	if emptyKept || !busyKept {
+		t.Errorf("Expected only the empty session to be removed, got EMPTY kept %v, BUSY kept %v", emptyKept, busyKept)
+	}
+}
+
+func TestMessageRateLimitFollowsReload(t *testing.T) {
+	cfg := limitsConfig()
+	server := NewWithConfig(cfg)
+
+	rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"LIMIT1","moderator":"ci-bot"}`)
+	var created map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	token := created["moderatorToken"]
+
+	reveal := func() int {
+		return restCall(server, "POST", "/api/sessions/LIMIT1/reveal", token, "").Code
+	}
+
+	for i := 0; i < cfg.MessageRateLimit; i++ {
+		if status := reveal(); status != http.StatusAccepted {
+			t.Fatalf("Expected message %d within the limit to be accepted, got %d", i+1, status)
+		}
+	}
+	rr = restCall(server, "POST", "/api/sessions/LIMIT1/reveal", token, "")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected a message over the limit to be rejected, got %d", rr.Code)
+	}
+	var body ErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &body)
+	if body.Code != codeRateLimited {
+		t.Errorf("Expected code %s, got %+v", codeRateLimited, body)
+	}
+
+	next := *cfg
+	next.MessageRateLimit = 0
+	server.Reload(&next)
+	for i := 0; i < 10; i++ {
+		if status := reveal(); status != http.StatusAccepted {
+			t.Fatalf("Expected no limit after the reload, got %d", status)
+		}
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	var limiter rateLimiter
+	now := time.Now()
+
+	for i := 0; i < 4; i++ {
+		if !limiter.allow(now, 4) {
+			t.Fatalf("Expected a burst of 4 to be allowed, rejected message %d", i+1)
+		}
+	}
+	if limiter.allow(now, 4) {
+		t.Error("Expected a fifth message in the same instant to be rejected")
+	}
+	if !limiter.allow(now.Add(250*time.Millisecond), 4) {
+		t.Error("Expected a message to be allowed once a token refilled")
+	}
+	if !limiter.allow(now.Add(250*time.Millisecond), 0) {
+		t.Error("Expected a zero limit to allow every message")
+	}
+}
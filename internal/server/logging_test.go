@@ -0,0 +1,45 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var attached bool
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, attached = r.Context().Value(loggerKey{}).(*slog.Logger)
+	}))
+
+	// A new ID is generated when none is supplied
+	req, _ := http.NewRequest("GET", "/api/sessions", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	generated := rr.Header().Get(requestIDHeader)
+	if generated == "" {
+		t.Error("Expected a generated request ID")
+	}
+	if !attached {
+		t.Error("Expected a logger in the request context")
+	}
+
+	// A well-formed ID from a proxy is kept
+	req, _ = http.NewRequest("GET", "/api/sessions", nil)
+	req.Header.Set(requestIDHeader, "proxy-123")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if id := rr.Header().Get(requestIDHeader); id != "proxy-123" {
+		t.Errorf("Expected request ID proxy-123, got %s", id)
+	}
+
+	// Anything else is replaced
+	req, _ = http.NewRequest("GET", "/api/sessions", nil)
+	req.Header.Set(requestIDHeader, "bad id\nlevel=ERROR")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if id := rr.Header().Get(requestIDHeader); id == "" || id == "bad id\nlevel=ERROR" {
+		t.Errorf("Expected malformed request ID to be replaced, got %q", id)
+	}
+}
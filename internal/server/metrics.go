@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"planning-poker/internal/metrics"
+)
+
+var (
+	httpDuration = metrics.NewHistogram("planning_poker_http_request_duration_seconds",
+		"HTTP request durations by handler, method and status code. WebSocket and event stream requests last as long as the connection.",
+		metrics.DefaultBuckets, "handler", "method", "code")
+)
+
+// newServerMetrics creates the registry of a server's own gauges: its
+// connections, and the gauges read from it at scrape time. Keeping them out
+// of metrics.Default means several servers in one process, as in tests, do
+// not count each other's clients.
+func newServerMetrics(s *Server) *metrics.Registry {
+	registry := metrics.NewRegistry()
+	s.connections = metrics.NewGaugeIn(registry, "planning_poker_connections",
+		"Participants connected to this instance, by transport.", "transport")
+	metrics.NewGaugeFunc(registry, "planning_poker_active_sessions",
+		"Sessions held by this instance.", func() float64 {
+			sessions, _ := s.counts()
+			return float64(sessions)
+		})
+	metrics.NewGaugeFunc(registry, "planning_poker_participants",
+		"Participants in the sessions held by this instance, including those connected to other instances.", func() float64 {
+			_, participants := s.counts()
+			return float64(participants)
+		})
+	return registry
+}
+
+// counts returns the number of sessions held by this instance and the
+// participants in them
+func (s *Server) counts() (sessions, participants int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, session := range s.sessions {
+		participants += session.UserCount()
+	}
+	return len(s.sessions), participants
+}
+
+// connectionCounts returns the connections to this server by transport
+func (s *Server) connectionCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, transport := range []string{"websocket", string(participantStream), string(participantPoll), string(participantREST)} {
+		counts[transport] = int(s.connections.Value(transport))
+	}
+	return counts
+}
+
+// HandleMetrics serves metrics in the Prometheus text exposition format
+func (s *Server) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	s.metrics.Write(w)
+	metrics.Default.Write(w)
+}
+
+// Instrument records the duration and status of requests to next under
+// the given handler name
+func Instrument(handler string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		httpDuration.ObserveSince(start, handler, r.Method, strconv.Itoa(recorder.status))
+	})
+}
+
+// statusRecorder captures the response status. It passes through
+// hijacking for WebSocket upgrades, and flushing via Unwrap for event
+// streams.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
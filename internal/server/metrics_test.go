@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHandleMetrics(t *testing.T) {
+	server := New()
+	restCall(server, "POST", "/api/sessions", "", `{"sessionId":"METRICS1","moderator":"ci-bot"}`)
+	restCall(server, "POST", "/api/sessions", "", `{"sessionId":"METRICS2"}`)
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+	server.HandleMetrics(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Expected text exposition format, got %s", rr.Header().Get("Content-Type"))
+	}
+
+	body := rr.Body.String()
+	for _, line := range []string{
+		"planning_poker_active_sessions 2\n",
+		"planning_poker_participants 1\n",
+		"# TYPE planning_poker_messages_received_total counter\n",
+		"# TYPE planning_poker_broadcast_duration_seconds histogram\n",
+		"# TYPE planning_poker_dropped_sends_total counter\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in metrics:\n%s", line, body)
+		}
+	}
+}
+
+func TestInstrumentRecordsStatus(t *testing.T) {
+	handler := Instrument("test_status", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusTeapot, codeInvalidRequest, "No coffee")
+	}))
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTeapot {
+		t.Errorf("Expected status %d, got %d", http.StatusTeapot, rr.Code)
+	}
+	if count := httpDuration.Count("test_status", "GET", "418"); count != 1 {
+		t.Errorf("Expected one observation, got %d", count)
+	}
+}
+
+func TestInstrumentAllowsWebSocketUpgrade(t *testing.T) {
+	server := New()
+	ts := httptest.NewServer(Instrument("test_websocket", http.HandlerFunc(server.HandleWebSocket)))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?session=METRICSWS&user=Alice&creator=true"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial through Instrument failed: %v", err)
+	}
+	defer conn.Close()
+
+	var msg map[string]interface{}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read first message: %v", err)
+	}
+}
+
+func TestConnectionsArePerServer(t *testing.T) {
+	server, other := New(), New()
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?session=METRICS3&user=Alice", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.status().Connections != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 1 connection, got %d", server.status().Connections)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if connections := other.status().Connections; connections != 0 {
+		t.Errorf("Expected another server to have no connections, got %d", connections)
+	}
+
+	rr := httptest.NewRecorder()
+	server.HandleMetrics(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rr.Body.String(), `planning_poker_connections{transport="websocket"} 1`) {
+		t.Errorf("Expected the connection in metrics:\n%s", rr.Body.String())
+	}
+}
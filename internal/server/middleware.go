@@ -0,0 +1,106 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Chain wraps h in middleware, the first being outermost
+func Chain(h http.Handler, middleware ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// contentSecurityPolicy allows only this origin's scripts and connections.
+// Inline styles remain allowed as the web client uses style attributes;
+// scripts are loaded from app.js, so no inline script can run.
+const contentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self'; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; " +
+	"connect-src 'self' %s; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"form-action 'self'; " +
+	"frame-ancestors 'none'"
+
+// SecurityHeaders sets the Content-Security-Policy and related headers on
+// every response. HSTS is only sent over HTTPS, as browsers ignore it on
+// plain HTTP.
+func (s *Server) SecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+
+		// Older browsers do not treat ws:// and wss:// to this host as
+		// 'self'. Hosts with characters that are special in a policy are
+		// left out rather than escaped.
+		sockets := ""
+		if !strings.ContainsAny(r.Host, " ;,'\"") {
+			sockets = "ws://" + r.Host + " wss://" + r.Host
+		}
+		header.Set("Content-Security-Policy", fmt.Sprintf(contentSecurityPolicy, sockets))
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("X-Content-Type-Options", "nosniff")
+		// Session IDs travel in URLs, so never leak them to other sites
+		header.Set("Referrer-Policy", "same-origin")
+		if r.TLS != nil {
+			header.Set("Strict-Transport-Security", "max-age=63072000")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+const (
+	corsAllowMethods  = "GET, POST, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, Authorization, " + participantTokenHeader + ", " + requestIDHeader
+	corsExposeHeaders = requestIDHeader
+	corsMaxAge        = "600"
+)
+
+// CORS applies the origin policy used for WebSocket upgrades to HTTP
+// requests. Allowed cross-origin requests get CORS headers and preflights
+// are answered here. Requests from rejected origins get no CORS headers,
+// and those that could change state are refused, so a page on another
+// site cannot act through a visitor's browser even when it cannot read the
+// response.
+func (s *Server) CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if allowed, reason := s.originAllowed(r, origin); !allowed {
+			// WebSocket upgrades are checked, and logged, by the upgrader
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			requestLogger(r).Warn("Rejected cross-origin request", "origin", origin, "reason", reason, "method", r.Method)
+			writeError(w, http.StatusForbidden, codeForbidden, "Origin not allowed")
+			return
+		}
+
+		header := w.Header()
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+
+		if preflight {
+			header.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			header.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			header.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"planning-poker/internal/config"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	middleware := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), middleware("first"), middleware("second"))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if strings.Join(order, ",") != "first,second,handler" {
+		t.Errorf("Expected middleware to run in order, got %v", order)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	server := New()
+	handler := server.SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://poker.example.com/", nil))
+
+	csp := rr.Header().Get("Content-Security-Policy")
+	for _, directive := range []string{"script-src 'self';", "frame-ancestors 'none'", "connect-src 'self' ws://poker.example.com wss://poker.example.com;"} {
+		if !strings.Contains(csp, directive) {
+			t.Errorf("Expected CSP to contain %q, got %q", directive, csp)
+		}
+	}
+	if strings.Contains(csp, "script-src 'self' 'unsafe-inline'") {
+		t.Errorf("Expected CSP to forbid inline scripts, got %q", csp)
+	}
+	if rr.Header().Get("X-Frame-Options") != "DENY" || rr.Header().Get("Referrer-Policy") != "same-origin" || rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Missing security headers: %v", rr.Header())
+	}
+	if rr.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("Expected no HSTS over plain HTTP")
+	}
+
+	req := httptest.NewRequest("GET", "https://poker.example.com/", nil)
+	req.TLS = &tls.ConnectionState{}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Header().Get("Strict-Transport-Security") == "" {
+		t.Error("Expected HSTS over HTTPS")
+	}
+
+	// A Host header cannot add directives to the policy
+	req = httptest.NewRequest("GET", "http://poker.example.com/", nil)
+	req.Host = "evil.test;script-src"
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if strings.Contains(rr.Header().Get("Content-Security-Policy"), "evil.test") {
+		t.Errorf("Expected unsafe Host to be left out, got %q", rr.Header().Get("Content-Security-Policy"))
+	}
+}
+
+func TestCORS(t *testing.T) {
+	server := NewWithConfig(&config.Config{AllowedOrigins: []string{"https://*.example.com"}, MaxMessageSize: 1024})
+	var called bool
+	handler := server.CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	request := func(method, origin string) *httptest.ResponseRecorder {
+		called = false
+		req := httptest.NewRequest(method, "http://poker.internal/api/sessions", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Preflight from an allowed origin is answered without the handler
+	rr := request(http.MethodOptions, "https://app.example.com")
+	if rr.Code != http.StatusNoContent || called {
+		t.Errorf("Expected preflight to be answered with 204, got %d (handler called: %v)", rr.Code, called)
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || !strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), participantTokenHeader) {
+		t.Errorf("Unexpected preflight headers %v", rr.Header())
+	}
+
+	rr = request(http.MethodPost, "https://app.example.com")
+	if !called || rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || rr.Header().Get("Vary") != "Origin" {
+		t.Errorf("Expected allowed request with CORS headers, got %v", rr.Header())
+	}
+
+	// Rejected origins may read nothing and change nothing
+	rr = request(http.MethodPost, "https://evil.test")
+	if called || rr.Code != http.StatusForbidden {
+		t.Errorf("Expected POST from rejected origin to be refused, got %d", rr.Code)
+	}
+	rr = request(http.MethodOptions, "https://evil.test")
+	if called || rr.Code != http.StatusForbidden {
+		t.Errorf("Expected preflight from rejected origin to be refused, got %d", rr.Code)
+	}
+	rr = request(http.MethodGet, "https://evil.test")
+	if !called || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected GET from rejected origin to get no CORS headers, got %v", rr.Header())
+	}
+
+	// Same-origin and non-browser requests pass through untouched
+	rr = request(http.MethodPost, "http://poker.internal")
+	if !called || rr.Code != http.StatusOK {
+		t.Errorf("Expected same-origin POST to pass, got %d", rr.Code)
+	}
+	rr = request(http.MethodPost, "")
+	if !called || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected request without Origin to pass without CORS headers, got %v", rr.Header())
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// checkOrigin is the WebSocket upgrader's origin check
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if allowed, reason := s.originAllowed(r, origin); !allowed {
+		requestLogger(r).Warn("Rejected WebSocket origin", "origin", origin, "reason", reason)
+		return false
+	}
+	return true
+}
+
+// originAllowed is the origin policy shared by WebSocket upgrades and CORS.
+// Browsers always send Origin on cross-origin requests, so requests without
+// one come from other clients and cannot be cross-site; same-origin
+// requests are the web client itself. Anything else must match the current
+// AllowedOrigins, which may change on reload, in development mode too. The
+// reason explains a rejection.
+func (s *Server) originAllowed(r *http.Request, origin string) (bool, string) {
+	cfg := s.Config()
+	if cfg == nil {
+		return true, ""
+	}
+
+	if origin == "" {
+		return true, ""
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false, "malformed Origin header"
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true, ""
+	}
+
+	for _, pattern := range cfg.AllowedOrigins {
+		if matchOrigin(pattern, u) {
+			return true, ""
+		}
+	}
+	return false, "not in ALLOWED_ORIGINS"
+}
+
+// matchOrigin reports whether origin matches an ALLOWED_ORIGINS entry: "*",
+// an exact scheme://host[:port], or a wildcard subdomain pattern such as
+// https://*.example.com, which matches any subdomain at any depth but not
+// example.com itself. Scheme and port must match exactly.
+func matchOrigin(pattern string, origin *url.URL) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	p, err := url.Parse(pattern)
+	if err != nil || !strings.EqualFold(p.Scheme, origin.Scheme) || p.Port() != origin.Port() {
+		return false
+	}
+
+	host, wildcard := strings.CutPrefix(strings.ToLower(p.Hostname()), "*.")
+	originHost := strings.ToLower(origin.Hostname())
+	if !wildcard {
+		return host == originHost
+	}
+	return strings.HasSuffix(originHost, "."+host)
+}
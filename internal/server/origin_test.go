@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"planning-poker/internal/config"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		pattern, origin string
+		expected        bool
+	}{
+		{"*", "https://anything.test", true},
+		{"https://example.com", "https://example.com", true},
+		{"https://example.com", "http://example.com", false},
+		{"https://example.com", "https://example.com:8443", false},
+		{"https://example.com:8443", "https://example.com:8443", true},
+		{"https://*.example.com", "https://poker.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://POKER.Example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://evilexample.com", false},
+		{"https://*.example.com", "https://example.com.evil.test", false},
+		{"https://*.example.com", "http://poker.example.com", false},
+	}
+
+	for _, tt := range tests {
+		origin, _ := url.Parse(tt.origin)
+		if got := matchOrigin(tt.pattern, origin); got != tt.expected {
+			t.Errorf("matchOrigin(%q, %q) = %v, expected %v", tt.pattern, tt.origin, got, tt.expected)
+		}
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	production := NewWithConfig(&config.Config{AllowedOrigins: []string{"https://*.example.com"}, MaxMessageSize: 1024})
+	development := NewWithConfig(&config.Config{AllowedOrigins: []string{"https://*.example.com"}, IsDevelopment: true})
+	permissive := NewWithConfig(&config.Config{AllowedOrigins: []string{"*"}, IsDevelopment: true})
+	unconfigured := New()
+
+	request := func(origin string) *http.Request {
+		r, _ := http.NewRequest("GET", "http://poker.internal:8080/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+
+	tests := []struct {
+		server   *Server
+		origin   string
+		expected bool
+	}{
+		{production, "https://poker.example.com", true},
+		{production, "https://evil.test", false},
+		{production, "http://poker.internal:8080", true}, // Same origin
+		{production, "", true},                           // Not a browser
+		{production, "://bad", false},
+		{development, "https://evil.test", false},
+		{development, "https://poker.example.com", true},
+		{permissive, "https://evil.test", true},
+		{unconfigured, "https://evil.test", true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.server.upgrader.CheckOrigin(request(tt.origin)); got != tt.expected {
+			t.Errorf("CheckOrigin(%q) = %v, expected %v", tt.origin, got, tt.expected)
+		}
+	}
+
+	// Creating other servers must not change the production policy
+	if production.upgrader.CheckOrigin(request("https://evil.test")) {
+		t.Error("Expected origin policy to stay per server")
+	}
+
+	if !strings.Contains(logs.String(), `origin=https://evil.test reason="not in ALLOWED_ORIGINS"`) {
+		t.Errorf("Expected rejected origin to be logged with a reason, got %q", logs.String())
+	}
+	if !strings.Contains(logs.String(), `reason="malformed Origin header"`) {
+		t.Errorf("Expected malformed origin to be logged, got %q", logs.String())
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// REST control endpoints let scripts and bots drive a session without a
+// socket. Each action is translated into the same poker.Message a WebSocket
+// client would send and handled by Session.HandleMessage, so permissions
+// and broadcasts are identical. Requests carry the participant token from
+// POST /api/sessions/{id}/participants, or the moderator token returned when
+// a session is created with a moderator name.
+
+// handleAction serves POST /api/sessions/{id}/{action}
+func (s *Server) handleAction(w http.ResponseWriter, r *http.Request, sessionID string, msgType poker.MessageType) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	p := s.lookupParticipant(r, sessionID)
+	if p == nil {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Unknown participant token")
+		return
+	}
+	p.touch()
+
+	msg := poker.Message{Type: msgType}
+
+	switch msgType {
+	case poker.MessageTypeVote:
+		var req VoteRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		msg.Data = mustJSON(req)
+
+	case poker.MessageTypeSetStory:
+		var req StoryRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		msg.Data = mustJSON(req)
+
+	case poker.MessageTypeAddStories:
+		var req AddStoriesRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		msg.Data = mustJSON(req)
+
+	case poker.MessageTypeSetEstimate:
+		var req EstimateRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		msg.Data = mustJSON(req)
+	}
+
+	s.dispatchMessage(w, r, p, msg)
+}
+
+// dispatchMessage hands msg to the participant's session and reports the
+// outcome as an HTTP status
+func (s *Server) dispatchMessage(w http.ResponseWriter, r *http.Request, p *participant, msg poker.Message) {
+	if !p.limiter.allow(time.Now(), s.messageRateLimit()) {
+		requestLogger(r).Debug("Message rejected", "session_id", p.session.ID, "user_id", p.userID, "type", msg.Type, "error", errRateLimited)
+		writeError(w, http.StatusTooManyRequests, codeRateLimited, errRateLimited.Error())
+		return
+	}
+	if err := p.session.HandleMessage(p.userID, msg); err != nil {
+		requestLogger(r).Debug("Message rejected", "session_id", p.session.ID, "user_id", p.userID, "type", msg.Type, "error", err)
+		status, code := messageErrorStatus(err)
+		writeError(w, status, code, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, AcceptedResponse{Status: "accepted"})
+}
+
+// messageErrorStatus maps a HandleMessage error to an HTTP status and
+// error code
+func messageErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, poker.ErrUserNotFound):
+		return http.StatusUnauthorized, codeUnauthorized
+	case errors.Is(err, poker.ErrNotModerator), errors.Is(err, poker.ErrNotCreator):
+		return http.StatusForbidden, codeForbidden
+	case errors.Is(err, poker.ErrAlreadyStarted), errors.Is(err, poker.ErrQueueEmpty), errors.Is(err, poker.ErrNotRevealed),
+		errors.Is(err, poker.ErrSessionEnded):
+		return http.StatusConflict, codeConflict
+	case errors.Is(err, poker.ErrWebhookNotFound):
+		return http.StatusNotFound, codeNotFound
+	default:
+		return http.StatusBadRequest, codeInvalidRequest
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// restCall sends a request with a bearer token and returns the recorder
+func restCall(server *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	if path == "/api/sessions" {
+		server.HandleSessions(rr, req)
+	} else {
+		server.HandleSession(rr, req)
+	}
+	return rr
+}
+
+func TestRESTSessionControl(t *testing.T) {
+	server := New()
+
+	// Create the session with a bot moderator
+	rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"REST123","moderator":"ci-bot"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to create session: %d", rr.Code)
+	}
+	var created map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	moderatorToken := created["moderatorToken"]
+	if moderatorToken == "" || created["moderatorId"] == "" {
+		t.Fatalf("Expected moderator token and ID, got %v", created)
+	}
+
+	// Join a REST participant
+	rr = restCall(server, "POST", "/api/sessions/REST123/participants", "", `{"name":"Bob","transport":"rest"}`)
+	var joined map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &joined)
+	participantToken := joined["token"]
+	if participantToken == "" {
+		t.Fatalf("Expected participant token, got %s", rr.Body.String())
+	}
+
+	steps := []struct {
+		name   string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"participant cannot start", "/api/sessions/REST123/start", participantToken, "", http.StatusForbidden},
+		{"moderator starts", "/api/sessions/REST123/start", moderatorToken, "", http.StatusAccepted},
+		{"start twice", "/api/sessions/REST123/start", moderatorToken, "", http.StatusConflict},
+		{"moderator sets story", "/api/sessions/REST123/story", moderatorToken, `{"story":"Login page"}`, http.StatusAccepted},
+		{"participant cannot set story", "/api/sessions/REST123/story", participantToken, `{"story":"Nope"}`, http.StatusForbidden},
+		{"vote without value", "/api/sessions/REST123/vote", participantToken, `{}`, http.StatusBadRequest},
+		{"participant votes", "/api/sessions/REST123/vote", participantToken, `{"vote":"8"}`, http.StatusAccepted},
+		{"participant cannot reveal", "/api/sessions/REST123/reveal", participantToken, "", http.StatusForbidden},
+		{"moderator reveals", "/api/sessions/REST123/reveal", moderatorToken, "", http.StatusAccepted},
+		{"unknown token", "/api/sessions/REST123/reveal", "bogus", "", http.StatusUnauthorized},
+		{"token from another session", "/api/sessions/OTHER/reveal", moderatorToken, "", http.StatusUnauthorized},
+	}
+
+	for _, step := range steps {
+		rr := restCall(server, "POST", step.path, step.token, step.body)
+		if rr.Code != step.status {
+			t.Errorf("%s: expected status %d, got %d (%s)", step.name, step.status, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr = restCall(server, "GET", "/api/sessions/REST123", "", "")
+	var state struct {
+		CurrentStory  string `json:"currentStory"`
+		VotesRevealed bool   `json:"votesRevealed"`
+		Status        string `json:"status"`
+		Users         map[string]struct {
+			Vote *string `json:"vote"`
+		} `json:"users"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &state)
+
+	if state.Status != "active" || state.CurrentStory != "Login page" || !state.VotesRevealed {
+		t.Errorf("Unexpected session state: %s", rr.Body.String())
+	}
+	if vote := state.Users[joined["userId"]].Vote; vote == nil || *vote != "8" {
+		t.Errorf("Expected revealed vote '8', got %v", vote)
+	}
+
+	// Starting a new round clears the reveal
+	rr = restCall(server, "POST", "/api/sessions/REST123/new-round", moderatorToken, "")
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Expected new round to be accepted, got %d", rr.Code)
+	}
+}
+
+func TestRESTAction_MethodNotAllowed(t *testing.T) {
+	server := New()
+
+	rr := restCall(server, "GET", "/api/sessions/REST123/reveal", "", "")
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
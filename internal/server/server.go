@@ -1,112 +1,183 @@
 package server
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"planning-poker/internal/bus"
 	"planning-poker/internal/config"
+	"planning-poker/internal/metrics"
 	"planning-poker/internal/poker"
+	"planning-poker/internal/webhook"
 
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
-}
-
 type Server struct {
-	sessions map[string]*poker.Session
-	config   *config.Config
-	mu       sync.RWMutex
+	sessions     map[string]*poker.Session
+	joining      map[string]*joiningSession // Sessions being joined on the bus
+	participants map[string]*participant    // Fallback transport users by token
+	trackers     map[string]Tracker         // Issue trackers by story source
+	notifiers    []Notifier                 // Chat channels that session events are posted to
+	webhooks     *webhook.Dispatcher
+	globalHooks  []poker.Webhook               // Receive the events of every session
+	config       atomic.Pointer[config.Config] // Replaced as a whole on reload
+	bus          bus.Bus
+	metrics      *metrics.Registry // This server's own gauges
+	connections  *metrics.Gauge    // Connected participants by transport
+	upgrader     websocket.Upgrader
+	started      time.Time
+	draining     atomic.Bool
+	handlers     sync.WaitGroup // Running WebSocket and event stream handlers
+	mu           sync.RWMutex
 }
 
 func New() *Server {
-	return &Server{
-		sessions: make(map[string]*poker.Session),
-	}
+	return NewWithBus(nil, bus.NewLocal())
 }
 
 func NewWithConfig(cfg *config.Config) *Server {
+	return NewWithBus(cfg, bus.NewLocal())
+}
+
+// NewWithBus creates a server whose sessions are shared with other
+// instances connected to the same bus
+func NewWithBus(cfg *config.Config, b bus.Bus) *Server {
 	server := &Server{
-		sessions: make(map[string]*poker.Session),
-		config:   cfg,
+		sessions:     make(map[string]*poker.Session),
+		joining:      make(map[string]*joiningSession),
+		participants: make(map[string]*participant),
+		trackers:     make(map[string]Tracker),
+		globalHooks:  globalWebhooks(cfg),
+		bus:          b,
+		started:      time.Now(),
+	}
+	webhookConfig := webhook.Config{}
+	if cfg != nil {
+		webhookConfig.MaxAttempts = cfg.WebhookMaxAttempts
+		webhookConfig.AllowedNetworks = cfg.WebhookNetworks()
 	}
+	server.webhooks = webhook.New(webhookConfig)
+	server.config.Store(cfg)
+	server.metrics = newServerMetrics(server)
 
-	// Configure WebSocket upgrader based on configuration
+	server.upgrader = websocket.Upgrader{CheckOrigin: server.checkOrigin}
 	if cfg != nil && cfg.IsProductionMode() {
-		upgrader.CheckOrigin = func(r *http.Request) bool {
-			origin := r.Header.Get("Origin")
-			for _, allowedOrigin := range cfg.AllowedOrigins {
-				if allowedOrigin == "*" || allowedOrigin == origin {
-					return true
-				}
-			}
-			return false
-		}
-
-		// Set message size limit
-		upgrader.ReadBufferSize = int(cfg.MaxMessageSize)
-		upgrader.WriteBufferSize = int(cfg.MaxMessageSize)
+		// Size buffers for the largest expected message
+		server.upgrader.ReadBufferSize = int(cfg.MaxMessageSize)
+		server.upgrader.WriteBufferSize = int(cfg.MaxMessageSize)
 	}
 
 	return server
 }
 
-func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("WebSocket upgrade failed: %v", err)
-		return
+// Config returns the current configuration, which is nil for servers
+// created without one
+func (s *Server) Config() *config.Config {
+	return s.config.Load()
+}
+
+// Reload applies the settings of next that are safe to change while
+// clients are connected, such as the allowed origins, in one atomic swap.
+// Listeners and sessions are left untouched. It returns the settings that
+// changed and those that differ but need a restart.
+func (s *Server) Reload(next *config.Config) (changed, restart []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.config.Load()
+	if current == nil {
+		return nil, nil // Nothing to reload without a configuration
 	}
-	defer conn.Close()
+	reloaded, changed, restart := current.Reload(next)
+	s.config.Store(reloaded)
+	return changed, restart
+}
+
+func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
 
 	sessionID := r.URL.Query().Get("session")
 	userName := r.URL.Query().Get("user")
 	isCreator := r.URL.Query().Get("creator") == "true"
 
-	if sessionID == "" || userName == "" {
-		log.Println("Missing session or user parameter")
+	// Reject bad parameters before upgrading, so the client gets a JSON error
+	if !sessionIDPattern.MatchString(sessionID) {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Invalid session ID")
+		return
+	}
+	if err := validateName("user", userName); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
 		return
 	}
 
-	s.mu.Lock()
-	session, exists := s.sessions[sessionID]
-	if !exists {
-		session = poker.NewSession(sessionID)
-		s.sessions[sessionID] = session
+	if !s.startHandler() {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Server is shutting down")
+		return
+	}
+	defer s.handlers.Done()
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Warn("WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	logger = logger.With("session_id", sessionID)
+
+	session, err := s.getOrCreateSession(sessionID)
+	if err != nil {
+		logger.Error("Failed to open session", "error", err)
+		return
 	}
-	s.mu.Unlock()
 
 	// Add user to session
-	user := session.AddUser(userName, conn, isCreator)
+	user := session.AddUser(userName, &wsConnection{conn: conn, draining: &s.draining}, isCreator)
 
 	defer session.RemoveUser(user.ID)
 
-	log.Printf("User %s joined session %s (creator: %v)", userName, sessionID, isCreator)
+	s.connections.Inc("websocket")
+	defer s.connections.Dec("websocket")
+
+	logger = logger.With("user_id", user.ID)
+	logger.Info("User joined", "user", userName, "transport", "websocket", "creator", isCreator)
 
 	// Handle messages from client
+	var limiter rateLimiter
 	for {
 		var msg poker.Message
 		err := conn.ReadJSON(&msg)
 		if err != nil {
-			log.Printf("Read error: %v", err)
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Warn("WebSocket read error", "error", err)
+			} else {
+				logger.Debug("WebSocket closed", "error", err)
+			}
 			break
 		}
 
-		session.HandleMessage(user.ID, msg)
+		logger.Debug("Message received", "type", msg.Type)
+		if err := MessageRequest(msg).Validate(); err != nil {
+			logger.Debug("Message rejected", "type", msg.Type, "error", err)
+			continue
+		}
+		if !limiter.allow(time.Now(), s.messageRateLimit()) {
+			logger.Debug("Message rejected", "type", msg.Type, "error", errRateLimited)
+			continue
+		}
+		if err := session.HandleMessage(user.ID, msg); err != nil {
+			logger.Debug("Message rejected", "type", msg.Type, "error", err)
+		}
 	}
 }
 
 func (s *Server) HandleSessions(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
 	switch r.Method {
-	case "GET":
+	case http.MethodGet:
 		s.mu.RLock()
 		sessionList := make([]string, 0, len(s.sessions))
 		for id := range s.sessions {
@@ -114,48 +185,174 @@ func (s *Server) HandleSessions(w http.ResponseWriter, r *http.Request) {
 		}
 		s.mu.RUnlock()
 
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"sessions": sessionList,
-		})
+		writeJSON(w, http.StatusOK, SessionListResponse{Sessions: sessionList})
 
-	case "POST":
-		var req struct {
-			SessionID string `json:"sessionId"`
+	case http.MethodPost:
+		if s.Draining() {
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Server is shutting down")
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+
+		var req CreateSessionRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		s.mu.Lock()
-		if _, exists := s.sessions[req.SessionID]; !exists {
-			s.sessions[req.SessionID] = poker.NewSession(req.SessionID)
+		response, _, err := s.createSession(r, req)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Failed to create session")
+			return
 		}
-		s.mu.Unlock()
 
-		json.NewEncoder(w).Encode(map[string]string{
-			"sessionId": req.SessionID,
-			"status":    "created",
-		})
+		writeJSON(w, http.StatusOK, response)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// createSession opens a session and, if req names a moderator, joins them
+// over REST. It returns the moderator participant, or nil without one.
+func (s *Server) createSession(r *http.Request, req CreateSessionRequest) (CreateSessionResponse, *participant, error) {
+	if _, err := s.getOrCreateSession(req.SessionID); err != nil {
+		requestLogger(r).Error("Failed to open session", "session_id", req.SessionID, "error", err)
+		return CreateSessionResponse{}, nil, err
+	}
+
+	response := CreateSessionResponse{
+		SessionID: req.SessionID,
+		Status:    "created",
 	}
+	if req.Moderator == "" {
+		return response, nil, nil
+	}
+
+	p, err := s.joinFallback(r, req.SessionID, req.Moderator, true, participantREST)
+	if err != nil {
+		requestLogger(r).Error("Failed to join session", "session_id", req.SessionID, "error", err)
+		return CreateSessionResponse{}, nil, err
+	}
+	response.ModeratorID = p.userID
+	response.ModeratorToken = p.token
+	return response, p, nil
+}
+
+// joiningSession is a session replica being created. Callers that want
+// the same session wait on done rather than creating a second replica.
+type joiningSession struct {
+	done    chan struct{}
+	session *poker.Session
+	err     error
+}
+
+// getOrCreateSession returns the local replica of a session, joining it on
+// the bus first if this instance has not seen it yet. Joining can wait on
+// the network, so it happens without s.mu held.
+func (s *Server) getOrCreateSession(sessionID string) (*poker.Session, error) {
+	s.mu.Lock()
+	if session, exists := s.sessions[sessionID]; exists {
+		s.mu.Unlock()
+		return session, nil
+	}
+	if join, exists := s.joining[sessionID]; exists {
+		s.mu.Unlock()
+		<-join.done
+		return join.session, join.err
+	}
+	join := &joiningSession{done: make(chan struct{})}
+	s.joining[sessionID] = join
+	s.mu.Unlock()
+
+	join.session, join.err = poker.NewSessionWithBus(sessionID, s.bus)
+	if join.err == nil {
+		join.session.OnEvent(s.handleSessionEvent)
+	}
+
+	s.mu.Lock()
+	delete(s.joining, sessionID)
+	if join.err == nil {
+		s.sessions[sessionID] = join.session
+	}
+	s.mu.Unlock()
+	close(join.done)
+
+	return join.session, join.err
 }
 
 func (s *Server) HandleSession(w http.ResponseWriter, r *http.Request) {
-	// Extract session ID from URL path
-	sessionID := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
+	// Extract session ID and optional sub-resource from URL path
+	path := strings.TrimPrefix(r.URL.Path, "/api/sessions/")
+	sessionID, resource, _ := strings.Cut(path, "/")
+
+	switch resource {
+	case "":
+	case "events":
+		s.handleEvents(w, r, sessionID)
+		return
+	case "participants":
+		s.handleParticipants(w, r, sessionID)
+		return
+	case "poll":
+		s.handlePoll(w, r, sessionID)
+		return
+	case "messages":
+		s.handleMessages(w, r, sessionID)
+		return
+	case "vote":
+		s.handleAction(w, r, sessionID, poker.MessageTypeVote)
+		return
+	case "reveal":
+		s.handleAction(w, r, sessionID, poker.MessageTypeReveal)
+		return
+	case "new-round":
+		s.handleAction(w, r, sessionID, poker.MessageTypeNewRound)
+		return
+	case "story":
+		s.handleAction(w, r, sessionID, poker.MessageTypeSetStory)
+		return
+	case "start":
+		s.handleAction(w, r, sessionID, poker.MessageTypeStartSession)
+		return
+	case "stories":
+		s.handleAction(w, r, sessionID, poker.MessageTypeAddStories)
+		return
+	case "next-story":
+		s.handleAction(w, r, sessionID, poker.MessageTypeNextStory)
+		return
+	case "estimate":
+		s.handleAction(w, r, sessionID, poker.MessageTypeSetEstimate)
+		return
+	case "end":
+		s.handleAction(w, r, sessionID, poker.MessageTypeEndSession)
+		return
+	case "import":
+		s.handleImport(w, r, sessionID)
+		return
+	case "webhooks":
+		s.handleWebhooks(w, r, sessionID, "")
+		return
+	default:
+		if hook, ok := strings.CutPrefix(resource, "webhooks/"); ok {
+			s.handleWebhooks(w, r, sessionID, hook)
+			return
+		}
+		writeError(w, http.StatusNotFound, codeNotFound, "Not found")
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
 
 	s.mu.RLock()
 	session, exists := s.sessions[sessionID]
 	s.mu.RUnlock()
 
 	if !exists {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, codeNotFound, "Session not found")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(session.GetState())
+	writeJSON(w, http.StatusOK, session.GetState())
 }
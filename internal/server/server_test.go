@@ -7,8 +7,13 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"planning-poker/internal/bus"
+	"planning-poker/internal/config"
 	"planning-poker/internal/poker"
+
+	"github.com/gorilla/websocket"
 )
 
 func TestNew(t *testing.T) {
@@ -169,13 +174,13 @@ func TestHandleSession_ExistingSession(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, status)
 	}
 
-	var response map[string]interface{}
+	var response poker.SessionState
 	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
 		t.Fatalf("Could not parse JSON response: %v", err)
 	}
 
-	if response["id"] != "EXISTING123" {
-		t.Errorf("Expected session ID 'EXISTING123', got %v", response["id"])
+	if response.ID != "EXISTING123" {
+		t.Errorf("Expected session ID 'EXISTING123', got %v", response.ID)
 	}
 }
 
@@ -278,6 +283,127 @@ func TestConcurrentSessionCreation(t *testing.T) {
 	}
 }
 
+// blockingBus is a local bus whose Subscribe waits until release is closed,
+// like a Redis bus waiting on an unreachable server
+type blockingBus struct {
+	*bus.Local
+	release chan struct{}
+}
+
+func (b *blockingBus) Subscribe(topic string, handler bus.Handler) (func(), error) {
+	<-b.release
+	return b.Local.Subscribe(topic, handler)
+}
+
+func TestSlowSessionJoinDoesNotBlockServer(t *testing.T) {
+	b := &blockingBus{Local: bus.NewLocal(), release: make(chan struct{})}
+	server := NewWithBus(nil, b)
+
+	sessions := make(chan *poker.Session, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			session, _ := server.getOrCreateSession("SLOW1")
+			sessions <- session
+		}()
+	}
+
+	// Other requests are served while the join waits on the bus
+	done := make(chan struct{})
+	go func() {
+		rr := httptest.NewRecorder()
+		server.HandleSessions(rr, httptest.NewRequest("GET", "/api/sessions", nil))
+		server.Drain()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the server not to wait for the session join")
+	}
+
+	close(b.release)
+	first, second := <-sessions, <-sessions
+	if first == nil || first != second {
+		t.Errorf("Expected both callers to get the same session, got %p and %p", first, second)
+	}
+	if got, _ := server.getOrCreateSession("SLOW1"); got != first {
+		t.Error("Expected the joined session to be registered")
+	}
+}
+
+func TestWebSocketRejectsInvalidInput(t *testing.T) {
+	server := New()
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+	for _, query := range []string{
+		"?user=Alice",
+		"?session=bad%20id&user=Alice",
+		"?session=" + strings.Repeat("A", 65) + "&user=Alice",
+		"?session=WS1",
+		"?session=WS1&user=%20",
+		"?session=WS1&user=" + strings.Repeat("a", maxNameLength+1),
+	} {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL+query, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected with 400, got %v", query, err)
+		}
+	}
+	server.mu.RLock()
+	_, created := server.sessions["WS1"]
+	server.mu.RUnlock()
+	if created {
+		t.Error("Expected no session to be created for rejected connections")
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?session=WS1&user=Alice&creator=true", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	send := func(msgType poker.MessageType, data any) {
+		t.Helper()
+		if err := conn.WriteJSON(poker.Message{Type: msgType, Data: mustJSON(data)}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	send(poker.MessageTypeSetStory, StoryRequest{Story: "Login"})
+	send(poker.MessageTypeSetStory, StoryRequest{Story: strings.Repeat("x", maxStoryLength+1)})
+	send(poker.MessageTypeAddWebhook, map[string]string{"id": "hook", "url": "http://127.0.0.1/"})
+	send(poker.MessageTypeSync, nil)
+
+	// Frames are handled in order, so the snapshot answering sync comes
+	// after the rejected ones
+	for {
+		var msg poker.Message
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if msg.Type != poker.MessageTypeSessionState {
+			continue
+		}
+		var state poker.SessionState
+		json.Unmarshal(msg.Data, &state)
+		if state.CurrentStory == "" {
+			continue // The snapshot sent on join
+		}
+		if state.CurrentStory != "Login" {
+			t.Errorf("Expected the oversized story to be rejected, got %d characters", len(state.CurrentStory))
+		}
+		break
+	}
+
+	server.mu.RLock()
+	session := server.sessions["WS1"]
+	server.mu.RUnlock()
+	if hooks := session.GetWebhooks(); len(hooks) != 0 {
+		t.Errorf("Expected add_webhook over WebSocket to be rejected, got %v", hooks)
+	}
+}
+
 func TestSessionLifecycle(t *testing.T) {
 	server := New()
 
@@ -327,14 +453,57 @@ func TestSessionLifecycle(t *testing.T) {
 		t.Errorf("Failed to get session details: %d", rr.Code)
 	}
 
-	var sessionResponse map[string]interface{}
+	var sessionResponse poker.SessionState
 	json.Unmarshal(rr.Body.Bytes(), &sessionResponse)
 
-	if sessionResponse["id"] != "LIFECYCLE123" {
-		t.Errorf("Expected session ID 'LIFECYCLE123', got %v", sessionResponse["id"])
+	if sessionResponse.ID != "LIFECYCLE123" {
+		t.Errorf("Expected session ID 'LIFECYCLE123', got %v", sessionResponse.ID)
 	}
 
-	if sessionResponse["status"] != "waiting" {
-		t.Errorf("Expected session status 'waiting', got %v", sessionResponse["status"])
+	if sessionResponse.Status != poker.SessionStatusWaiting {
+		t.Errorf("Expected session status 'waiting', got %v", sessionResponse.Status)
+	}
+}
+
+func TestReloadAllowedOrigins(t *testing.T) {
+	cfg := &config.Config{
+		Port:           "8080",
+		AllowedOrigins: []string{"https://old.example.com"},
+		MaxMessageSize: 1024,
+		LogLevel:       "info",
+	}
+	server := NewWithConfig(cfg)
+	ts := httptest.NewServer(http.HandlerFunc(server.HandleWebSocket))
+	defer ts.Close()
+
+	dial := func(origin string) error {
+		url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?session=RELOAD1&user=Alice&creator=true"
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+
+	if err := dial("https://new.example.com"); err == nil {
+		t.Fatal("Expected origin to be rejected before reload")
+	}
+
+	next := *cfg
+	next.AllowedOrigins = []string{"https://new.example.com"}
+	next.Port = "9999"
+	changed, restart := server.Reload(&next)
+
+	if len(changed) != 1 || changed[0] != "ALLOWED_ORIGINS" || len(restart) != 1 || restart[0] != "PORT" {
+		t.Errorf("Expected ALLOWED_ORIGINS to change and PORT to need a restart, got %v and %v", changed, restart)
+	}
+	if err := dial("https://new.example.com"); err != nil {
+		t.Errorf("Expected reloaded origin to be accepted, got %v", err)
+	}
+	if err := dial("https://old.example.com"); err == nil {
+		t.Error("Expected removed origin to be rejected after reload")
+	}
+	if server.Config().Port != "8080" {
+		t.Errorf("Expected port to keep its startup value, got %s", server.Config().Port)
 	}
 }
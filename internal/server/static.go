@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staticFile is a file read from the web UI's file system, kept until its
+// size or modification time changes
+type staticFile struct {
+	data    []byte
+	etag    string
+	size    int64
+	modTime time.Time
+}
+
+// staticHandler serves the web UI with strong ETags
+type staticHandler struct {
+	fsys  fs.FS
+	mu    sync.Mutex
+	files map[string]*staticFile
+}
+
+// Static serves the web UI from fsys, either the embedded files or a local
+// directory during development. Asset names carry no version, so responses
+// are marked no-cache: browsers revalidate on each load and get a 304 from
+// the ETag while the file is unchanged, and never run a stale app.js
+// against a newer server. Missing UI files get a plain-text 404, while paths
+// under /api/ get a JSON error like the rest of the API.
+func Static(fsys fs.FS) http.Handler {
+	return &staticHandler{fsys: fsys, files: make(map[string]*staticFile)}
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Unknown API paths fall through to here; answer them like the API does
+	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+		writeError(w, http.StatusNotFound, codeNotFound, "Not found")
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	name := path.Clean("/" + r.URL.Path)[1:]
+	if name == "" {
+		name = "index.html"
+	}
+
+	file, err := h.open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", file.etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	// ServeContent answers If-None-Match and sets the content type
+	http.ServeContent(w, r, name, file.modTime, bytes.NewReader(file.data))
+}
+
+// open returns the named file, reading it again only if it changed
+func (h *staticHandler) open(name string) (*staticFile, error) {
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fs.ErrNotExist
+	}
+
+	h.mu.Lock()
+	cached, exists := h.files[name]
+	h.mu.Unlock()
+	if exists && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached, nil
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	file := &staticFile{
+		data:    data,
+		etag:    `"` + hex.EncodeToString(sum[:8]) + `"`,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	}
+
+	h.mu.Lock()
+	h.files[name] = file
+	h.mu.Unlock()
+	return file, nil
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"planning-poker/web"
+)
+
+func TestStatic_EmbeddedUI(t *testing.T) {
+	handler := Static(web.FS)
+
+	for _, path := range []string{"/", "/app.js"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK || rr.Body.Len() == 0 {
+			t.Errorf("Expected embedded %s to be served, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestStatic_CacheHeaders(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html": {Data: []byte("<html>v1</html>"), ModTime: time.Unix(1, 0)},
+		"app.js":     {Data: []byte("console.log('v1');"), ModTime: time.Unix(1, 0)},
+	}
+	handler := Static(fsys)
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/", "")
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || rr.Body.String() != "<html>v1</html>" {
+		t.Fatalf("Expected index.html at /, got %d %q", rr.Code, rr.Body.String())
+	}
+	if etag == "" || rr.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("Expected ETag and Cache-Control: no-cache, got %v", rr.Header())
+	}
+	if ct := get("/app.js", "").Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/javascript") {
+		t.Errorf("Expected JavaScript content type, got %s", ct)
+	}
+
+	// Unchanged files are revalidated without a body
+	rr = get("/index.html", etag)
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("Expected 304 for matching ETag, got %d", rr.Code)
+	}
+
+	// Edits in a development directory change the ETag
+	fsys["index.html"] = &fstest.MapFile{Data: []byte("<html>v2</html>"), ModTime: time.Unix(2, 0)}
+	rr = get("/", etag)
+	if rr.Code != http.StatusOK || rr.Body.String() != "<html>v2</html>" || rr.Header().Get("ETag") == etag {
+		t.Errorf("Expected changed file with a new ETag, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestStatic_NotFound(t *testing.T) {
+	handler := Static(fstest.MapFS{
+		"index.html":     {Data: []byte("<html></html>")},
+		"assets/logo.js": {Data: []byte("")},
+	})
+
+	for _, path := range []string{"/missing.js", "/assets", "/../web.go"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s, got %d", path, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rr.Code)
+	}
+
+	// Unknown API paths get the API's JSON error, whatever the method
+	for _, method := range []string{"GET", "POST"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, "/api/unknown", nil))
+		var response ErrorResponse
+		if rr.Code != http.StatusNotFound || json.Unmarshal(rr.Body.Bytes(), &response) != nil || response.Code != codeNotFound {
+			t.Errorf("Expected a JSON 404 for %s /api/unknown, got %d %q", method, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"planning-poker/internal/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLS is what the listeners need to serve HTTPS
+type TLS struct {
+	// Config is the public listener's TLS configuration
+	Config *tls.Config
+
+	// Redirect serves the plain HTTP port: it redirects to HTTPS and, in
+	// ACME mode, answers HTTP-01 challenges
+	Redirect http.Handler
+}
+
+// NewTLS builds the TLS setup from certificate files or ACME. It returns
+// nil when TLS is disabled.
+func NewTLS(cfg *config.Config) (*TLS, error) {
+	redirect := RedirectToHTTPS(cfg.Port)
+
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS certificate: %w", err)
+		}
+		return &TLS{
+			Config: &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			},
+			Redirect: redirect,
+		}, nil
+	}
+
+	if len(cfg.ACMEDomains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+			Cache:      autocert.DirCache(cfg.ACMECacheDir),
+			Email:      cfg.ACMEEmail,
+		}
+		if cfg.ACMEDirectoryURL != "" {
+			manager.Client = &acme.Client{DirectoryURL: cfg.ACMEDirectoryURL}
+		}
+
+		// The manager's config also answers TLS-ALPN-01 challenges, so
+		// certificates can be issued without the HTTP port
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return &TLS{
+			Config:   tlsConfig,
+			Redirect: manager.HTTPHandler(redirect),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// RedirectToHTTPS permanently redirects requests to the same host and path
+// on the HTTPS port. 308 keeps the method, so API calls are not turned into
+// GETs.
+func RedirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"planning-poker/internal/config"
+
+	"github.com/gorilla/websocket"
+)
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and
+// returns the certificate and key paths and a pool trusting it
+func writeTestCertificate(t *testing.T) (string, string, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "planning-poker test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestNewTLS_Disabled(t *testing.T) {
+	serverTLS, err := NewTLS(&config.Config{Port: "8080"})
+	if err != nil || serverTLS != nil {
+		t.Errorf("Expected no TLS without certificates or ACME, got %v %v", serverTLS, err)
+	}
+}
+
+func TestNewTLS_CertificateFiles(t *testing.T) {
+	certFile, keyFile, pool := writeTestCertificate(t)
+
+	serverTLS, err := NewTLS(&config.Config{Port: "8443", TLSCertFile: certFile, TLSKeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("NewTLS failed: %v", err)
+	}
+
+	server := New()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", server.HandleWebSocket)
+	mux.HandleFunc("/healthz", server.HandleLiveness)
+	ts := httptest.NewUnstartedServer(mux)
+	ts.TLS = serverTLS.Config
+	ts.StartTLS()
+	defer ts.Close()
+
+	clientTLS := &tls.Config{RootCAs: pool}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+	resp, err := client.Get(ts.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 over HTTPS, got %d", resp.StatusCode)
+	}
+
+	// Browsers on an https:// page connect with wss://
+	dialer := websocket.Dialer{TLSClientConfig: clientTLS}
+	conn, _, err := dialer.Dial("wss"+strings.TrimPrefix(ts.URL, "https")+"/ws?session=TLS1&user=Alice&creator=true", nil)
+	if err != nil {
+		t.Fatalf("wss:// dial failed: %v", err)
+	}
+	defer conn.Close()
+	var msg map[string]interface{}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Errorf("Failed to read over wss://: %v", err)
+	}
+}
+
+func TestNewTLS_InvalidCertificate(t *testing.T) {
+	certFile, _, _ := writeTestCertificate(t)
+
+	// A certificate is not a key
+	if _, err := NewTLS(&config.Config{TLSCertFile: certFile, TLSKeyFile: certFile}); err == nil {
+		t.Error("Expected mismatched key to fail")
+	}
+}
+
+func TestNewTLS_ACME(t *testing.T) {
+	serverTLS, err := NewTLS(&config.Config{
+		Port:         "443",
+		ACMEDomains:  []string{"poker.example.com"},
+		ACMECacheDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("NewTLS failed: %v", err)
+	}
+
+	if serverTLS.Config.GetCertificate == nil || !slices.Contains(serverTLS.Config.NextProtos, "acme-tls/1") {
+		t.Error("Expected certificates from the ACME manager, including TLS-ALPN-01 challenges")
+	}
+
+	// The HTTP handler answers challenges itself and redirects the rest
+	rr := httptest.NewRecorder()
+	serverTLS.Redirect.ServeHTTP(rr, httptest.NewRequest("GET", "http://poker.example.com/.well-known/acme-challenge/unknown", nil))
+	if rr.Code == http.StatusPermanentRedirect {
+		t.Error("Expected ACME challenge paths not to be redirected")
+	}
+
+	rr = httptest.NewRecorder()
+	serverTLS.Redirect.ServeHTTP(rr, httptest.NewRequest("GET", "http://poker.example.com/", nil))
+	if rr.Code != http.StatusPermanentRedirect || rr.Header().Get("Location") != "https://poker.example.com/" {
+		t.Errorf("Expected redirect to https://poker.example.com/, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		port, url, expected string
+	}{
+		{"443", "http://poker.example.com/api/sessions?x=1", "https://poker.example.com/api/sessions?x=1"},
+		{"443", "http://poker.example.com:80/", "https://poker.example.com/"},
+		{"8443", "http://localhost:8080/ws", "https://localhost:8443/ws"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		RedirectToHTTPS(tt.port).ServeHTTP(rr, httptest.NewRequest("POST", tt.url, nil))
+
+		if rr.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s: expected 308 so the method is kept, got %d", tt.url, rr.Code)
+		}
+		if location := rr.Header().Get("Location"); location != tt.expected {
+			t.Errorf("%s: expected redirect to %s, got %s", tt.url, tt.expected, location)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// trackerTimeout bounds one call to an issue tracker
+const trackerTimeout = 30 * time.Second
+
+// Tracker is an issue tracker that stories can be imported from and that
+// agreed estimates are written back to, such as Jira
+type Tracker interface {
+	// Name is the Source of the stories the tracker imports
+	Name() string
+	// Import returns the stories matching a tracker-specific query
+	Import(ctx context.Context, query string) ([]poker.Story, error)
+	// WriteEstimate records the estimate of a story the tracker imported
+	WriteEstimate(ctx context.Context, story poker.Story) error
+}
+
+// AddTracker makes a tracker available for imports and estimate write-back.
+// It must be called before the server handles requests.
+func (s *Server) AddTracker(t Tracker) {
+	s.trackers[t.Name()] = t
+}
+
+// writeEstimate writes an agreed estimate back to the story's tracker.
+// Only stories imported by handleImport have a Source; others, such as
+// those set with set_story or added by clients, are skipped.
+func (s *Server) writeEstimate(sessionID string, story poker.Story) {
+	t, exists := s.trackers[story.Source]
+	if !exists {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), trackerTimeout)
+	defer cancel()
+
+	logger := slog.With("session_id", sessionID, "tracker", story.Source, "key", story.Key, "estimate", story.Estimate)
+	if err := t.WriteEstimate(ctx, story); err != nil {
+		logger.Error("Failed to write estimate", "error", err)
+		return
+	}
+	logger.Info("Estimate written")
+}
+
+// handleImport serves POST /api/sessions/{id}/import, which appends the
+// stories matching a tracker query to the session's queue
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	p := s.lookupParticipant(r, sessionID)
+	if p == nil {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Unknown participant token")
+		return
+	}
+	p.touch()
+
+	var req ImportRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	t, exists := s.trackers[req.Source]
+	if !exists {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "Unknown or unconfigured source "+req.Source)
+		return
+	}
+
+	// Check before querying the tracker; the session checks again
+	if user := p.session.GetState().Users[p.userID]; user == nil || !user.IsModerator {
+		writeError(w, http.StatusForbidden, codeForbidden, poker.ErrNotModerator.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), trackerTimeout)
+	defer cancel()
+
+	stories, err := t.Import(ctx, req.Query)
+	if err != nil {
+		requestLogger(r).Warn("Import failed", "session_id", sessionID, "tracker", req.Source, "error", err)
+		writeError(w, http.StatusBadGateway, codeBadGateway, err.Error())
+		return
+	}
+	if len(stories) > 0 {
+		if err := p.session.ImportStories(p.userID, stories); err != nil {
+			status, code := messageErrorStatus(err)
+			writeError(w, status, code, err.Error())
+			return
+		}
+	}
+
+	requestLogger(r).Info("Stories imported", "session_id", sessionID, "tracker", req.Source, "count", len(stories))
+	writeJSON(w, http.StatusOK, ImportResponse{Stories: stories})
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// fakeTracker serves canned stories and records written estimates
+type fakeTracker struct {
+	stories   []poker.Story
+	err       error
+	estimates chan poker.Story
+}
+
+func (f *fakeTracker) Name() string { return "fake" }
+
+func (f *fakeTracker) Import(ctx context.Context, query string) ([]poker.Story, error) {
+	return f.stories, f.err
+}
+
+func (f *fakeTracker) WriteEstimate(ctx context.Context, story poker.Story) error {
+	f.estimates <- story
+	return nil
+}
+
+func TestImportAndWriteEstimate(t *testing.T) {
+	server := New()
+	tracker := &fakeTracker{
+		stories: []poker.Story{
+			{Key: "PP-1", Title: "Login page", Source: "fake"},
+			{Key: "PP-2", Title: "Logout", Source: "fake"},
+		},
+		estimates: make(chan poker.Story, 1),
+	}
+	server.AddTracker(tracker)
+
+	rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"JIRA1","moderator":"ci-bot"}`)
+	var created CreateSessionResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	moderatorToken := created.ModeratorToken
+
+	rr = restCall(server, "POST", "/api/sessions/JIRA1/participants", "", `{"name":"Bob","transport":"rest"}`)
+	var joined JoinResponse
+	json.Unmarshal(rr.Body.Bytes(), &joined)
+
+	steps := []struct {
+		name   string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"unknown source", "/api/sessions/JIRA1/import", moderatorToken, `{"source":"trello","query":"x"}`, http.StatusBadRequest},
+		{"participant cannot import", "/api/sessions/JIRA1/import", joined.Token, `{"source":"fake","query":"project = PP"}`, http.StatusForbidden},
+		{"moderator imports", "/api/sessions/JIRA1/import", moderatorToken, `{"source":"fake","query":"project = PP"}`, http.StatusOK},
+		{"next story", "/api/sessions/JIRA1/next-story", moderatorToken, "", http.StatusAccepted},
+		{"vote", "/api/sessions/JIRA1/vote", joined.Token, `{"vote":"5"}`, http.StatusAccepted},
+		{"estimate before reveal", "/api/sessions/JIRA1/estimate", moderatorToken, `{"estimate":"5"}`, http.StatusConflict},
+		{"reveal", "/api/sessions/JIRA1/reveal", moderatorToken, "", http.StatusAccepted},
+		{"participant cannot estimate", "/api/sessions/JIRA1/estimate", joined.Token, `{"estimate":"5"}`, http.StatusForbidden},
+		{"moderator estimates", "/api/sessions/JIRA1/estimate", moderatorToken, `{"estimate":"5"}`, http.StatusAccepted},
+	}
+
+	for _, step := range steps {
+		rr := restCall(server, "POST", step.path, step.token, step.body)
+		if rr.Code != step.status {
+			t.Errorf("%s: expected status %d, got %d (%s)", step.name, step.status, rr.Code, rr.Body.String())
+		}
+	}
+
+	story := <-tracker.estimates
+	if story.Key != "PP-1" || story.Estimate != "5" {
+		t.Errorf("Expected PP-1 to be written back at 5, got %+v", story)
+	}
+
+	rr = restCall(server, "GET", "/api/sessions/JIRA1", "", "")
+	var state poker.SessionState
+	json.Unmarshal(rr.Body.Bytes(), &state)
+	if len(state.Queue) != 1 || state.Queue[0].Key != "PP-2" || len(state.Estimated) != 1 {
+		t.Errorf("Expected PP-2 queued and PP-1 estimated, got %s", rr.Body.String())
+	}
+}
+
+func TestImportTrackerError(t *testing.T) {
+	server := New()
+	server.AddTracker(&fakeTracker{err: errors.New("jira: Bad Request: bad JQL")})
+
+	rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"JIRA2","moderator":"ci-bot"}`)
+	var created CreateSessionResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	rr = restCall(server, "POST", "/api/sessions/JIRA2/import", created.ModeratorToken, `{"source":"fake","query":"project = "}`)
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("Expected tracker failure to be a 502, got %d", rr.Code)
+	}
+}
+
+func TestAddedStoriesAreNotWrittenBack(t *testing.T) {
+	server := New()
+	tracker := &fakeTracker{estimates: make(chan poker.Story, 1)}
+	server.AddTracker(tracker)
+
+	rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"JIRA3","moderator":"ci-bot"}`)
+	var created CreateSessionResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	token := created.ModeratorToken
+
+	// Anyone can be a moderator, so the story must not name an issue
+	restCall(server, "POST", "/api/sessions/JIRA3/stories", token, `{"stories":[{"title":"Forged","key":"PROD-1","source":"fake"}]}`)
+	restCall(server, "POST", "/api/sessions/JIRA3/next-story", token, "")
+	restCall(server, "POST", "/api/sessions/JIRA3/reveal", token, "")
+	if rr := restCall(server, "POST", "/api/sessions/JIRA3/estimate", token, `{"estimate":"5"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected the estimate to be accepted, got %d", rr.Code)
+	}
+
+	select {
+	case story := <-tracker.estimates:
+		t.Errorf("Expected a story added by a client not to be written back, got %+v", story)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
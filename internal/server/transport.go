@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"planning-poker/internal/poker"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxQueuedMessages bounds how far a fallback client may fall behind before
+// its sends start failing and it is marked offline
+const maxQueuedMessages = 256
+
+var (
+	errTransportClosed = errors.New("transport closed")
+	errQueueFull       = errors.New("message queue full")
+)
+
+const (
+	// closeWriteTimeout bounds how long closing a WebSocket waits to send
+	// the close frame
+	closeWriteTimeout = time.Second
+
+	// wsWriteTimeout bounds how long a message may take to reach a
+	// WebSocket client's socket. Broadcasts happen with the session lock
+	// held, so a client that reads too slowly must not hold up the others.
+	wsWriteTimeout = time.Second
+)
+
+// wsConnection adapts a WebSocket to poker.Connection. A WebSocket allows
+// one writer at a time, and shutdown notices are sent outside the session
+// lock, so writes are serialized by mu.
+type wsConnection struct {
+	mu       sync.Mutex
+	conn     *websocket.Conn
+	draining *atomic.Bool // The server's draining flag
+}
+
+// Send writes msg, or gives up after wsWriteTimeout. A WebSocket cannot be
+// written to after a failed write, so the connection is then closed and
+// the read loop in HandleWebSocket ends, removing the participant; the
+// client reconnects and gets a fresh snapshot.
+func (c *wsConnection) Send(msg poker.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		c.conn.NetConn().Close()
+		return err
+	}
+	return nil
+}
+
+// Close sends a close frame, "service restart" while the server is
+// draining and normal otherwise. The read loop in HandleWebSocket then ends
+// when the client answers, or after closeWriteTimeout if it does not, and
+// closes the underlying connection.
+func (c *wsConnection) Close() error {
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if c.draining != nil && c.draining.Load() {
+		closeMessage = websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting")
+	}
+
+	err := c.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(closeWriteTimeout))
+	c.conn.SetReadDeadline(time.Now().Add(closeWriteTimeout))
+	return err
+}
+
+// queueTransport is a poker.Connection for clients that cannot hold a
+// WebSocket open. Messages are queued in memory and drained by the SSE
+// stream or by long-poll requests.
+type queueTransport struct {
+	mu       sync.Mutex
+	messages []json.RawMessage
+	notify   chan struct{} // Signalled when messages are queued
+	done     chan struct{} // Closed by Close
+	closed   bool
+}
+
+func newQueueTransport() *queueTransport {
+	return &queueTransport{
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+func (q *queueTransport) Send(msg poker.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return errTransportClosed
+	}
+	if len(q.messages) >= maxQueuedMessages {
+		return errQueueFull
+	}
+	q.messages = append(q.messages, data)
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// drain removes and returns all queued messages
+func (q *queueTransport) drain() []json.RawMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	messages := q.messages
+	q.messages = nil
+	return messages
+}
+
+// Close makes further sends fail and ends the stream or poll reading the
+// queue once it has delivered the messages already queued
+func (q *queueTransport) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.done)
+	}
+	return nil
+}
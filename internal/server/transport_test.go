@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"planning-poker/internal/poker"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWSConnectionSendGivesUpOnStalledClient(t *testing.T) {
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade failed: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	defer srv.Close()
+
+	// The client never reads, so the socket buffers fill up
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+	conn := <-conns
+	defer conn.Close()
+
+	c := &wsConnection{conn: conn}
+	msg := poker.Message{Type: poker.MessageTypeSetStory, Data: json.RawMessage(`"` + strings.Repeat("x", 1<<20) + `"`)}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		start := time.Now()
+		err := c.Send(msg)
+		if elapsed := time.Since(start); elapsed > wsWriteTimeout+time.Second {
+			t.Fatalf("Send blocked for %s", elapsed)
+		}
+		if err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Sends to a stalled client kept succeeding")
+		}
+	}
+
+	if err := c.Send(poker.Message{Type: poker.MessageTypeSync}); err == nil {
+		t.Error("Expected sends after a timeout to fail")
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"planning-poker/internal/config"
+	"planning-poker/internal/poker"
+	"planning-poker/internal/webhook"
+
+	"github.com/google/uuid"
+)
+
+// globalWebhooks returns the webhooks configured for every session
+func globalWebhooks(cfg *config.Config) []poker.Webhook {
+	if cfg == nil {
+		return nil
+	}
+
+	var events []poker.EventType
+	for _, event := range cfg.WebhookEvents {
+		events = append(events, poker.EventType(event))
+	}
+
+	hooks := make([]poker.Webhook, 0, len(cfg.WebhookURLs))
+	for i, u := range cfg.WebhookURLs {
+		hooks = append(hooks, poker.Webhook{
+			ID:     fmt.Sprintf("global-%d", i+1),
+			URL:    u,
+			Secret: cfg.WebhookSecret,
+			Events: events,
+		})
+	}
+	return hooks
+}
+
+// handleSessionEvent is the event handler of every session. It writes
+// agreed estimates back to their tracker, notifies chat channels and posts
+// the event to the global webhooks and the session's own.
+func (s *Server) handleSessionEvent(event poker.Event) {
+	if event.Type == poker.EventEstimateSet && event.Story != nil {
+		go s.writeEstimate(event.SessionID, *event.Story)
+	}
+	for _, n := range s.notifiers {
+		go s.notify(n, event)
+	}
+
+	for _, hook := range s.globalHooks {
+		if hook.Wants(event.Type) {
+			s.webhooks.SendTrusted(hook, event)
+		}
+	}
+	for _, hook := range event.Webhooks {
+		if hook.Wants(event.Type) {
+			s.webhooks.Send(hook, event)
+		}
+	}
+}
+
+// handleWebhooks serves /api/sessions/{id}/webhooks and its sub-resources:
+// the session's webhooks, one webhook by ID and the delivery log. Only the
+// moderator may use them, since webhooks receive every vote.
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request, sessionID, sub string) {
+	p := s.lookupParticipant(r, sessionID)
+	if p == nil {
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Unknown participant token")
+		return
+	}
+	p.touch()
+
+	if user := p.session.GetState().Users[p.userID]; user == nil || !user.IsModerator {
+		writeError(w, http.StatusForbidden, codeForbidden, poker.ErrNotModerator.Error())
+		return
+	}
+
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		response := WebhooksResponse{Webhooks: []WebhookView{}}
+		for _, hook := range p.session.GetWebhooks() {
+			response.Webhooks = append(response.Webhooks, webhookView(hook))
+		}
+		writeJSON(w, http.StatusOK, response)
+
+	case sub == "" && r.Method == http.MethodPost:
+		var req WebhookRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		hook := poker.Webhook{ID: uuid.New().String(), URL: req.URL, Secret: req.Secret, Events: req.Events}
+		msg := poker.Message{Type: poker.MessageTypeAddWebhook, Data: mustJSON(hook)}
+		if err := p.session.HandleMessage(p.userID, msg); err != nil {
+			status, code := messageErrorStatus(err)
+			writeError(w, status, code, err.Error())
+			return
+		}
+		requestLogger(r).Info("Webhook added", "session_id", sessionID, "webhook_id", hook.ID)
+		writeJSON(w, http.StatusOK, webhookView(hook))
+
+	case sub == "":
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+
+	case sub == "deliveries" && r.Method == http.MethodGet:
+		// Only deliveries to the session's own webhooks; those to global
+		// webhooks are listed on the admin listener
+		hooks := p.session.GetWebhooks()
+		deliveries := s.webhooks.Deliveries(func(d webhook.Delivery) bool {
+			return d.SessionID == sessionID && slices.ContainsFunc(hooks, func(h poker.Webhook) bool { return h.ID == d.WebhookID })
+		})
+		for i := range deliveries {
+			// Response bodies are only shown to operators: a receiver on
+			// an allowed network may return internal details
+			deliveries[i].ResponseBody = ""
+		}
+		writeJSON(w, http.StatusOK, DeliveriesResponse{Deliveries: deliveries})
+
+	case sub == "deliveries":
+		methodNotAllowed(w, http.MethodGet)
+
+	case r.Method == http.MethodDelete:
+		msg := poker.Message{Type: poker.MessageTypeRemoveWebhook, Data: mustJSON(map[string]string{"id": sub})}
+		if err := p.session.HandleMessage(p.userID, msg); err != nil {
+			status, code := messageErrorStatus(err)
+			writeError(w, status, code, err.Error())
+			return
+		}
+		requestLogger(r).Info("Webhook removed", "session_id", sessionID, "webhook_id", sub)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		methodNotAllowed(w, http.MethodDelete)
+	}
+}
+
+// handleAdminDeliveries serves GET /webhooks/deliveries on the admin
+// listener: every delivery in this instance's log
+func (s *Server) handleAdminDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, DeliveriesResponse{Deliveries: s.webhooks.Deliveries(nil)})
+}
+
+func webhookView(hook poker.Webhook) WebhookView {
+	events := hook.Events
+	if events == nil {
+		events = []poker.EventType{}
+	}
+	return WebhookView{ID: hook.ID, URL: hook.URL, Events: events, Signed: hook.Secret != ""}
+}
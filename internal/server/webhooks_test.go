@@ -0,0 +1,218 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"planning-poker/internal/config"
+	"planning-poker/internal/poker"
+	"planning-poker/internal/webhook"
+)
+
+// receivedEvent is a delivery as a webhook receiver saw it
+type receivedEvent struct {
+	event poker.Event
+	valid bool // Whether the signature matched
+}
+
+// newReceiver starts a webhook endpoint that checks signatures against
+// secret and passes on each event it receives
+func newReceiver(t *testing.T, secret string) (*httptest.Server, chan receivedEvent) {
+	events := make(chan receivedEvent, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event poker.Event
+		json.Unmarshal(body, &event)
+		events <- receivedEvent{event, webhook.Verify(secret, body, r.Header.Get(webhook.HeaderSignature))}
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver, events
+}
+
+func nextEvent(t *testing.T, events chan receivedEvent) receivedEvent {
+	t.Helper()
+
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(3 * time.Second):
+		t.Fatal("No webhook delivery received")
+		return receivedEvent{}
+	}
+}
+
+// newLoopbackServer returns a server whose session webhooks may reach the
+// test receivers on loopback
+func newLoopbackServer() *Server {
+	cfg, _ := config.Load(nil)
+	cfg.WebhookAllowedNetworks = []string{"127.0.0.0/8", "::1/128"}
+	return NewWithConfig(cfg)
+}
+
+func TestSessionWebhooks(t *testing.T) {
+	server := newLoopbackServer()
+	receiver, events := newReceiver(t, "s3cret")
+
+	rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"HOOKS1","moderator":"ci-bot"}`)
+	var created CreateSessionResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	moderatorToken := created.ModeratorToken
+
+	rr = restCall(server, "POST", "/api/sessions/HOOKS1/participants", "", `{"name":"Bob","transport":"rest"}`)
+	var joined JoinResponse
+	json.Unmarshal(rr.Body.Bytes(), &joined)
+
+	body := `{"url":"` + receiver.URL + `","secret":"s3cret","events":["votes_revealed","session_ended"]}`
+	if rr := restCall(server, "POST", "/api/sessions/HOOKS1/webhooks", joined.Token, body); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected participant to be forbidden, got %d", rr.Code)
+	}
+	if rr := restCall(server, "POST", "/api/sessions/HOOKS1/webhooks", moderatorToken, `{"url":"ftp://example.com"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an ftp URL to be rejected, got %d", rr.Code)
+	}
+
+	rr = restCall(server, "POST", "/api/sessions/HOOKS1/webhooks", moderatorToken, body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected webhook to be added, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var hook WebhookView
+	json.Unmarshal(rr.Body.Bytes(), &hook)
+
+	rr = restCall(server, "GET", "/api/sessions/HOOKS1/webhooks", moderatorToken, "")
+	if strings.Contains(rr.Body.String(), "s3cret") || !strings.Contains(rr.Body.String(), `"signed":true`) {
+		t.Errorf("Expected the listing to hide the secret, got %s", rr.Body.String())
+	}
+
+	restCall(server, "POST", "/api/sessions/HOOKS1/vote", joined.Token, `{"vote":"8"}`)
+	restCall(server, "POST", "/api/sessions/HOOKS1/reveal", moderatorToken, "")
+
+	received := nextEvent(t, events)
+	if !received.valid || received.event.Type != poker.EventVotesRevealed || received.event.SessionID != "HOOKS1" {
+		t.Errorf("Expected a signed votes_revealed event, got %+v", received)
+	}
+	if len(received.event.Votes) != 2 {
+		t.Errorf("Expected both participants' votes, got %+v", received.event.Votes)
+	}
+
+	rr = restCall(server, "GET", "/api/sessions/HOOKS1/webhooks/deliveries", moderatorToken, "")
+	var log DeliveriesResponse
+	json.Unmarshal(rr.Body.Bytes(), &log)
+	if len(log.Deliveries) != 1 || log.Deliveries[0].WebhookID != hook.ID || log.Deliveries[0].Event != poker.EventVotesRevealed {
+		t.Errorf("Expected one logged delivery, got %s", rr.Body.String())
+	}
+
+	if rr := restCall(server, "POST", "/api/sessions/HOOKS1/end", moderatorToken, ""); rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected session to end, got %d", rr.Code)
+	}
+	if received := nextEvent(t, events); received.event.Type != poker.EventSessionEnded {
+		t.Errorf("Expected session_ended, got %+v", received.event)
+	}
+	if rr := restCall(server, "POST", "/api/sessions/HOOKS1/vote", joined.Token, `{"vote":"3"}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected a vote after the end to conflict, got %d", rr.Code)
+	}
+	if rr := restCall(server, "DELETE", "/api/sessions/HOOKS1/webhooks/"+hook.ID, moderatorToken, ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected webhook changes after the end to conflict, got %d", rr.Code)
+	}
+}
+
+func TestSessionWebhookDeliveriesHideInternals(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "redis password: hunter2", http.StatusBadRequest)
+	}))
+	t.Cleanup(receiver.Close)
+
+	deliveries := func(server *Server) []webhook.Delivery {
+		t.Helper()
+		rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"HOOKS4","moderator":"ci-bot"}`)
+		var created CreateSessionResponse
+		json.Unmarshal(rr.Body.Bytes(), &created)
+		restCall(server, "POST", "/api/sessions/HOOKS4/webhooks", created.ModeratorToken, `{"url":"`+receiver.URL+`"}`)
+		restCall(server, "POST", "/api/sessions/HOOKS4/start", created.ModeratorToken, "")
+
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			rr = restCall(server, "GET", "/api/sessions/HOOKS4/webhooks/deliveries", created.ModeratorToken, "")
+			var log DeliveriesResponse
+			json.Unmarshal(rr.Body.Bytes(), &log)
+			if len(log.Deliveries) == 1 && log.Deliveries[0].Status != webhook.StatusPending {
+				return log.Deliveries
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("No finished delivery in %s", rr.Body.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Session webhooks may not reach the server's own networks
+	blocked := deliveries(New())
+	if blocked[0].Status != webhook.StatusFailed || !strings.Contains(blocked[0].Error, webhook.ErrBlockedAddress.Error()) {
+		t.Errorf("Expected a loopback webhook to be refused, got %+v", blocked[0])
+	}
+
+	server := newLoopbackServer()
+	rejected := deliveries(server)
+	if rejected[0].Error != "400 Bad Request" || rejected[0].ResponseBody != "" {
+		t.Errorf("Expected the moderator not to see the response body, got %+v", rejected[0])
+	}
+	admin := httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(admin, httptest.NewRequest("GET", "/webhooks/deliveries", nil))
+	if !strings.Contains(admin.Body.String(), "hunter2") {
+		t.Errorf("Expected the admin log to keep the response body, got %s", admin.Body.String())
+	}
+}
+
+func TestRemoveWebhook(t *testing.T) {
+	server := New()
+
+	rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"HOOKS2","moderator":"ci-bot"}`)
+	var created CreateSessionResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	rr = restCall(server, "POST", "/api/sessions/HOOKS2/webhooks", created.ModeratorToken, `{"url":"https://ci.example.com/poker"}`)
+	var hook WebhookView
+	json.Unmarshal(rr.Body.Bytes(), &hook)
+
+	if rr := restCall(server, "DELETE", "/api/sessions/HOOKS2/webhooks/"+hook.ID, created.ModeratorToken, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected webhook to be removed, got %d", rr.Code)
+	}
+	if rr := restCall(server, "DELETE", "/api/sessions/HOOKS2/webhooks/"+hook.ID, created.ModeratorToken, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a removed webhook to be gone, got %d", rr.Code)
+	}
+}
+
+func TestGlobalWebhooks(t *testing.T) {
+	receiver, events := newReceiver(t, "global")
+
+	cfg, _ := config.Load(nil)
+	cfg.WebhookURLs = []string{receiver.URL}
+	cfg.WebhookSecret = "global"
+	cfg.WebhookEvents = []string{"session_started"}
+	server := NewWithConfig(cfg)
+
+	rr := restCall(server, "POST", "/api/sessions", "", `{"sessionId":"HOOKS3","moderator":"ci-bot"}`)
+	var created CreateSessionResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	restCall(server, "POST", "/api/sessions/HOOKS3/reveal", created.ModeratorToken, "")
+	restCall(server, "POST", "/api/sessions/HOOKS3/start", created.ModeratorToken, "")
+
+	received := nextEvent(t, events)
+	if !received.valid || received.event.Type != poker.EventSessionStarted {
+		t.Errorf("Expected only a signed session_started event, got %+v", received)
+	}
+
+	// Global deliveries are only listed on the admin listener
+	rr = restCall(server, "GET", "/api/sessions/HOOKS3/webhooks/deliveries", created.ModeratorToken, "")
+	if strings.Contains(rr.Body.String(), "global-1") {
+		t.Errorf("Expected session deliveries to exclude global webhooks, got %s", rr.Body.String())
+	}
+	admin := httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(admin, httptest.NewRequest("GET", "/webhooks/deliveries", nil))
+	if !strings.Contains(admin.Body.String(), `"webhookId":"global-1"`) {
+		t.Errorf("Expected the admin log to list the global delivery, got %s", admin.Body.String())
+	}
+}
@@ -0,0 +1,307 @@
+// Package webhook posts session events to HTTP endpoints with signed JSON
+// payloads, retrying failed deliveries with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"planning-poker/internal/metrics"
+	"planning-poker/internal/poker"
+
+	"github.com/google/uuid"
+)
+
+// Headers set on every delivery
+const (
+	HeaderEvent     = "X-Poker-Event"
+	HeaderDelivery  = "X-Poker-Delivery"
+	HeaderSignature = "X-Poker-Signature" // sha256=<hex HMAC of the body>, if the webhook has a secret
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBaseDelay   = time.Second
+	defaultMaxDelay    = time.Minute
+	defaultLogSize     = 1000
+	requestTimeout     = 10 * time.Second
+	maxErrorBody       = 512 // Bytes of a failed response kept in the log
+)
+
+var deliveries = metrics.NewCounter("planning_poker_webhook_deliveries_total",
+	"Webhook delivery attempts by result: delivered, retried or failed.", "result")
+
+// Status is the state of a delivery
+type Status string
+
+const (
+	StatusPending   Status = "pending" // Being sent or waiting to be retried
+	StatusDelivered Status = "delivered"
+	StatusFailed    Status = "failed" // Rejected, or out of attempts
+)
+
+// Delivery is an entry of the delivery log
+type Delivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhookId"`
+	SessionID      string          `json:"sessionId"`
+	Event          poker.EventType `json:"event"`
+	URL            string          `json:"url"`
+	Status         Status          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"responseStatus,omitempty"` // Of the last attempt
+	Error          string          `json:"error,omitempty"`          // Of the last attempt, without the response body
+	ResponseBody   string          `json:"responseBody,omitempty"`   // Start of the last failed response; only for operators
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+}
+
+// Config configures a Dispatcher. Zero values select the defaults.
+type Config struct {
+	MaxAttempts int           // Per delivery, including the first; default 5
+	BaseDelay   time.Duration // Before the first retry, doubling after each; default 1s
+	MaxDelay    time.Duration // Cap on the delay between retries; default 1m
+	LogSize     int           // Deliveries kept in the log; default 1000
+
+	// AllowedNetworks are private networks that session webhooks may
+	// reach, such as that of an internal CI server. Other loopback,
+	// private and link-local addresses are refused.
+	AllowedNetworks []netip.Prefix
+
+	// HTTPClient is used for requests to trusted webhooks if set
+	HTTPClient *http.Client
+}
+
+// Dispatcher sends deliveries in the background and keeps a bounded log
+// of them, newest last
+type Dispatcher struct {
+	config  Config
+	http    *http.Client    // For trusted webhooks
+	guarded *http.Client    // For session webhooks
+	ctx     context.Context // Canceled to abandon pending retries
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu  sync.Mutex
+	log []*Delivery
+}
+
+// New returns a dispatcher configured by cfg
+func New(cfg Config) *Dispatcher {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultBaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultMaxDelay
+	}
+	if cfg.LogSize <= 0 {
+		cfg.LogSize = defaultLogSize
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{config: cfg, http: client, guarded: guardedClient(cfg.AllowedNetworks), ctx: ctx, cancel: cancel}
+}
+
+// Send queues a delivery of event to a session webhook and returns its ID.
+// Anyone can add a session webhook, so it may not reach the server's own
+// networks outside AllowedNetworks. Send does not block, so it is safe to
+// call from a session event handler.
+func (d *Dispatcher) Send(hook poker.Webhook, event poker.Event) string {
+	return d.send(d.guarded, hook, event)
+}
+
+// SendTrusted is Send for webhooks the operator configured, which may
+// reach any address
+func (d *Dispatcher) SendTrusted(hook poker.Webhook, event poker.Event) string {
+	return d.send(d.http, hook, event)
+}
+
+func (d *Dispatcher) send(client *http.Client, hook poker.Webhook, event poker.Event) string {
+	now := time.Now()
+	delivery := &Delivery{
+		ID:        uuid.New().String(),
+		WebhookID: hook.ID,
+		SessionID: event.SessionID,
+		Event:     event.Type,
+		URL:       hook.URL,
+		Status:    StatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	d.mu.Lock()
+	d.log = append(d.log, delivery)
+	if len(d.log) > d.config.LogSize {
+		d.log = d.log[len(d.log)-d.config.LogSize:]
+	}
+	d.mu.Unlock()
+
+	body, _ := json.Marshal(event)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.deliver(client, delivery, hook, body)
+	}()
+
+	return delivery.ID
+}
+
+// Deliveries returns copies of the logged deliveries accepted by filter,
+// newest first; a nil filter accepts all
+func (d *Dispatcher) Deliveries(filter func(Delivery) bool) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := []Delivery{}
+	for i := len(d.log) - 1; i >= 0; i-- {
+		if delivery := *d.log[i]; filter == nil || filter(delivery) {
+			result = append(result, delivery)
+		}
+	}
+	return result
+}
+
+// Close waits for running deliveries until ctx is done, then abandons
+// their remaining retries
+func (d *Dispatcher) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// deliver makes up to MaxAttempts attempts, updating the log after each
+func (d *Dispatcher) deliver(client *http.Client, delivery *Delivery, hook poker.Webhook, body []byte) {
+	logger := slog.With("session_id", delivery.SessionID, "webhook_id", hook.ID, "delivery_id", delivery.ID, "event", delivery.Event)
+	delay := d.config.BaseDelay
+
+	for attempt := 1; ; attempt++ {
+		status, responseBody, err := d.attempt(client, delivery, hook, body)
+		retry := err != nil && retryable(status) && !errors.Is(err, ErrBlockedAddress) && attempt < d.config.MaxAttempts
+
+		d.mu.Lock()
+		delivery.Attempts = attempt
+		delivery.ResponseStatus = status
+		delivery.ResponseBody = responseBody
+		delivery.UpdatedAt = time.Now()
+		delivery.Error = ""
+		switch {
+		case err == nil:
+			delivery.Status = StatusDelivered
+		case retry:
+			delivery.Error = err.Error()
+		default:
+			delivery.Error = err.Error()
+			delivery.Status = StatusFailed
+		}
+		d.mu.Unlock()
+
+		switch {
+		case err == nil:
+			deliveries.Inc("delivered")
+			logger.Debug("Webhook delivered", "attempts", attempt)
+			return
+		case !retry:
+			deliveries.Inc("failed")
+			logger.Warn("Webhook delivery failed", "attempts", attempt, "error", err)
+			return
+		}
+
+		deliveries.Inc("retried")
+		logger.Debug("Webhook delivery will be retried", "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-d.ctx.Done():
+			d.mu.Lock()
+			delivery.Status = StatusFailed
+			delivery.Error = "abandoned at shutdown: " + delivery.Error
+			d.mu.Unlock()
+			deliveries.Inc("failed")
+			return
+		}
+		delay = min(delay*2, d.config.MaxDelay)
+	}
+}
+
+// attempt posts the payload once. It returns the response status, or 0 if
+// no response was received, the start of the response body unless the
+// status was 2xx, and an error unless the status was 2xx.
+func (d *Dispatcher) attempt(client *http.Client, delivery *Delivery, hook poker.Webhook, body []byte) (int, string, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "planning-poker-webhook")
+	req.Header.Set(HeaderEvent, string(delivery.Event))
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	if hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, "", nil
+	}
+
+	// The body is kept apart from the error: it can be anything the
+	// receiver returns, which session moderators must not read
+	text, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return resp.StatusCode, strings.TrimSpace(string(text)), fmt.Errorf("%s", resp.Status)
+}
+
+// retryable reports whether a failed attempt with the given response
+// status may succeed later: network errors, rate limits and server errors
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
+
+// Sign returns the signature header value of body for secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body for secret.
+// Receivers written in Go can use it to authenticate deliveries.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// waitFor polls the delivery until it is no longer pending
+func waitFor(t *testing.T, d *Dispatcher, id string) Delivery {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, delivery := range d.Deliveries(nil) {
+			if delivery.ID == id && delivery.Status != StatusPending {
+				return delivery
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Delivery %s still pending", id)
+	return Delivery{}
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	type received struct {
+		event     string
+		signature string
+		body      []byte
+	}
+	got := make(chan received, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.Header.Get(HeaderEvent), r.Header.Get(HeaderSignature), body}
+	}))
+	defer receiver.Close()
+
+	d := New(Config{})
+	hook := poker.Webhook{ID: "hook-1", URL: receiver.URL, Secret: "s3cret"}
+	story := &poker.Story{Key: "PP-1", Title: "Login page", Estimate: "5"}
+	id := d.SendTrusted(hook, poker.Event{Type: poker.EventEstimateSet, SessionID: "ABC123", Story: story})
+
+	r := <-got
+	if r.event != "estimate_set" {
+		t.Errorf("Expected event header estimate_set, got %q", r.event)
+	}
+	if !Verify("s3cret", r.body, r.signature) || Verify("other", r.body, r.signature) {
+		t.Errorf("Signature %q does not match the body", r.signature)
+	}
+	var event poker.Event
+	json.Unmarshal(r.body, &event)
+	if event.SessionID != "ABC123" || event.Story == nil || event.Story.Estimate != "5" {
+		t.Errorf("Unexpected payload %s", r.body)
+	}
+
+	delivery := waitFor(t, d, id)
+	if delivery.Status != StatusDelivered || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusOK {
+		t.Errorf("Unexpected delivery %+v", delivery)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	d := New(Config{BaseDelay: time.Millisecond})
+	id := d.SendTrusted(poker.Webhook{ID: "hook-1", URL: receiver.URL}, poker.Event{Type: poker.EventSessionEnded})
+
+	delivery := waitFor(t, d, id)
+	if delivery.Status != StatusDelivered || delivery.Attempts != 3 || delivery.Error != "" {
+		t.Errorf("Expected delivery on the third attempt, got %+v", delivery)
+	}
+}
+
+func TestSendGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get(HeaderEvent) == "votes_revealed" {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+
+	d := New(Config{MaxAttempts: 2, BaseDelay: time.Millisecond})
+	hook := poker.Webhook{ID: "hook-1", URL: receiver.URL}
+
+	exhausted := waitFor(t, d, d.SendTrusted(hook, poker.Event{Type: poker.EventSessionStarted}))
+	if exhausted.Status != StatusFailed || exhausted.Attempts != 2 || exhausted.ResponseStatus != http.StatusBadGateway {
+		t.Errorf("Expected failure after 2 attempts, got %+v", exhausted)
+	}
+
+	rejected := waitFor(t, d, d.SendTrusted(hook, poker.Event{Type: poker.EventVotesRevealed}))
+	if rejected.Status != StatusFailed || rejected.Attempts != 1 ||
+		rejected.Error != "401 Unauthorized" || rejected.ResponseBody != "bad signature" {
+		t.Errorf("Expected a 4xx not to be retried, got %+v", rejected)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("Expected 3 requests, got %d", n)
+	}
+}
+
+func TestSendBlocksPrivateAddresses(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer receiver.Close()
+	hook := poker.Webhook{ID: "hook-1", URL: receiver.URL}
+
+	d := New(Config{BaseDelay: time.Millisecond})
+	blocked := waitFor(t, d, d.Send(hook, poker.Event{Type: poker.EventSessionStarted}))
+	if blocked.Status != StatusFailed || blocked.Attempts != 1 || !strings.Contains(blocked.Error, ErrBlockedAddress.Error()) {
+		t.Errorf("Expected a loopback session webhook to fail at once, got %+v", blocked)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("Expected no request to reach the receiver, got %d", n)
+	}
+
+	d = New(Config{AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}})
+	if allowed := waitFor(t, d, d.Send(hook, poker.Event{Type: poker.EventSessionStarted})); allowed.Status != StatusDelivered {
+		t.Errorf("Expected an allowed network to be reachable, got %+v", allowed)
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	allowed := []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}
+	tests := map[string]bool{
+		"127.0.0.1":       true,
+		"::1":             true,
+		"10.0.0.5":        true,
+		"10.1.2.3":        false,
+		"172.16.0.1":      true,
+		"192.168.1.1":     true,
+		"169.254.169.254": true,
+		"fe80::1":         true,
+		"fd00::1":         true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"224.0.0.1":       true,
+		"93.184.216.34":   false,
+		"2606:4700::1111": false,
+	}
+	for addr, want := range tests {
+		if got := blocked(netip.MustParseAddr(addr), allowed); got != want {
+			t.Errorf("%s: expected blocked=%v, got %v", addr, want, got)
+		}
+	}
+}
+
+func TestDeliveryLog(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	d := New(Config{LogSize: 2})
+	for _, session := range []string{"A", "B", "C"} {
+		waitFor(t, d, d.SendTrusted(poker.Webhook{ID: "hook-1", URL: receiver.URL}, poker.Event{Type: poker.EventSessionStarted, SessionID: session}))
+	}
+
+	log := d.Deliveries(nil)
+	if len(log) != 2 || log[0].SessionID != "C" || log[1].SessionID != "B" {
+		t.Errorf("Expected the newest 2 deliveries, newest first, got %+v", log)
+	}
+	if b := d.Deliveries(func(d Delivery) bool { return d.SessionID == "B" }); len(b) != 1 {
+		t.Errorf("Expected the filter to select one delivery, got %+v", b)
+	}
+}
+
+func TestCloseAbandonsRetries(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	d := New(Config{BaseDelay: time.Hour})
+	id := d.SendTrusted(poker.Webhook{ID: "hook-1", URL: receiver.URL}, poker.Event{Type: poker.EventSessionEnded})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected Close to give up waiting, got %v", err)
+	}
+	if delivery := waitFor(t, d, id); delivery.Status != StatusFailed {
+		t.Errorf("Expected the retry to be abandoned, got %+v", delivery)
+	}
+}
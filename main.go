@@ -2,22 +2,98 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"planning-poker/internal/bus"
+	"planning-poker/internal/chat"
 	"planning-poker/internal/config"
+	"planning-poker/internal/github"
+	"planning-poker/internal/jira"
 	"planning-poker/internal/server"
+	"planning-poker/web"
 )
 
 func main() {
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+
+	// Log with the configured level and format
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.Level())
+	logger := cfg.NewLogger(os.Stderr, logLevel)
+	slog.SetDefault(logger)
+
+	// Connect the event bus that shares sessions between instances
+	var eventBus bus.Bus = bus.NewLocal()
+	if cfg.BusBackend == "redis" {
+		redisBus, err := bus.NewRedis(bus.RedisOptions{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+		})
+		if err != nil {
+			slog.Error("Failed to connect to Redis", "addr", cfg.RedisAddr, "error", err)
+			os.Exit(1)
+		}
+		eventBus = redisBus
+		slog.Info("Sharing sessions through Redis", "addr", cfg.RedisAddr)
+	}
+	defer eventBus.Close()
 
 	// Create a new server instance with configuration
-	srv := server.NewWithConfig(cfg)
+	srv := server.NewWithBus(cfg, eventBus)
+
+	// Issue trackers to import stories from and write estimates back to
+	if cfg.JiraEnabled() {
+		srv.AddTracker(jira.New(jira.Config{
+			BaseURL:          cfg.JiraURL,
+			Email:            cfg.JiraEmail,
+			APIToken:         cfg.JiraAPIToken,
+			StoryPointsField: cfg.JiraStoryPointsField,
+			Projects:         cfg.JiraProjects,
+		}))
+		slog.Info("Jira integration enabled", "url", cfg.JiraURL, "field", cfg.JiraStoryPointsField, "projects", cfg.JiraProjects)
+	}
+	if cfg.GitHubEnabled() {
+		srv.AddTracker(github.New(github.Config{
+			APIURL:        cfg.GitHubAPIURL,
+			Token:         cfg.GitHubToken,
+			Target:        cfg.GitHubEstimateTarget,
+			LabelPrefix:   cfg.GitHubLabelPrefix,
+			ProjectNumber: cfg.GitHubProjectNumber,
+			ProjectField:  cfg.GitHubProjectField,
+			Repos:         cfg.GitHubRepos,
+		}))
+		slog.Info("GitHub integration enabled", "url", cfg.GitHubAPIURL, "target", cfg.GitHubEstimateTarget, "repos", cfg.GitHubRepos)
+	}
+
+	// Chat channel that session events are posted to
+	if cfg.ChatWebhookURL != "" {
+		srv.AddNotifier(chat.NewNotifier(chat.Config{
+			WebhookURL: cfg.ChatWebhookURL,
+			PublicURL:  cfg.PublicURL,
+		}))
+		slog.Info("Chat notifications enabled", "public_url", cfg.PublicURL)
+	}
+
+	// Public routes get their own mux: net/http/pprof and expvar register
+	// on http.DefaultServeMux, which must never be served publicly
+	mux := http.NewServeMux()
 
 	// Create HTTP server
 	httpServer := &http.Server{
@@ -25,33 +101,147 @@ func main() {
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 		IdleTimeout:  cfg.IdleTimeout,
+		Handler:      server.Chain(mux, server.RequestID, srv.SecurityHeaders, srv.CORS),
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
 	}
 
-	// Serve static files
-	http.Handle("/", http.FileServer(http.Dir("./web/")))
+	// Serve HTTPS from certificate files or ACME when configured
+	serverTLS, err := server.NewTLS(cfg)
+	if err != nil {
+		slog.Error("Failed to set up TLS", "error", err)
+		os.Exit(1)
+	}
+	scheme := "http"
+	if serverTLS != nil {
+		httpServer.TLSConfig = serverTLS.Config
+		scheme = "https"
+	}
+
+	// The optional plain HTTP listener only redirects to HTTPS and answers
+	// ACME challenges
+	var redirectServer *http.Server
+	if serverTLS != nil && cfg.HTTPRedirectPort != "" {
+		redirectServer = &http.Server{
+			Addr:         cfg.Host + ":" + cfg.HTTPRedirectPort,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+			Handler:      serverTLS.Redirect,
+			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		}
+	}
+
+	// Diagnostics are served on a separate admin listener. It has no write
+	// timeout so CPU profiles and traces can run for their full duration.
+	adminServer := &http.Server{
+		Addr:              cfg.AdminAddress(),
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		Handler:           srv.AdminHandler(),
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+
+	// handle registers a handler, recording its request durations when
+	// metrics are enabled
+	handle := func(pattern, name string, handler http.Handler) {
+		if cfg.MetricsEnabled {
+			handler = server.Instrument(name, handler)
+		}
+		mux.Handle(pattern, handler)
+	}
+
+	// Serve the web UI embedded in the binary, or from a local directory
+	// so edits show up without rebuilding
+	webFS := fs.FS(web.FS)
+	if cfg.WebDir != "" {
+		webFS = os.DirFS(cfg.WebDir)
+		slog.Info("Serving web UI from directory", "dir", cfg.WebDir)
+	}
+	handle("/", "static", server.Static(webFS))
 
 	// WebSocket endpoint
-	http.HandleFunc("/ws", srv.HandleWebSocket)
+	handle("/ws", "websocket", http.HandlerFunc(srv.HandleWebSocket))
 
 	// API endpoints
-	http.HandleFunc("/api/sessions", srv.HandleSessions)
-	http.HandleFunc("/api/sessions/", srv.HandleSession)
+	handle("/api/sessions", "sessions", http.HandlerFunc(srv.HandleSessions))
+	handle("/api/sessions/", "session", http.HandlerFunc(srv.HandleSession))
+	handle("/api/openapi.json", "openapi", http.HandlerFunc(srv.HandleOpenAPI))
+	handle("/api/chat/command", "chat", http.HandlerFunc(srv.HandleChatCommand))
 
-	// Health check endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok","service":"planning-poker"}`))
-	})
+	// Health check endpoints; /health is kept for existing monitors
+	mux.HandleFunc("/healthz", srv.HandleLiveness)
+	mux.HandleFunc("/readyz", srv.HandleReadiness)
+	mux.HandleFunc("/health", srv.HandleLiveness)
 
-	log.Printf("Planning Poker server starting on %s", cfg.Address())
-	log.Printf("Environment: %s", map[bool]string{true: "development", false: "production"}[cfg.IsDevelopment])
-	log.Printf("Open http://localhost:%s in your browser", cfg.Port)
+	slog.Info("Planning Poker server starting",
+		"addr", cfg.Address(),
+		"tls", serverTLS != nil,
+		"environment", map[bool]string{true: "development", false: "production"}[cfg.IsDevelopment],
+		"log_level", logLevel.Level(),
+	)
+	slog.Info("Open " + scheme + "://localhost:" + cfg.Port + " in your browser")
 
 	// Start server in a goroutine
 	go func() {
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("Server failed to start:", err)
+		var err error
+		if serverTLS != nil {
+			// Certificates come from TLSConfig
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("Server failed to start", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	if redirectServer != nil {
+		go func() {
+			slog.Info("HTTP redirect listener starting", "addr", redirectServer.Addr)
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("HTTP redirect listener failed to start", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// Start the admin listener
+	go func() {
+		slog.Info("Admin listener starting", "addr", cfg.AdminAddress(), "pprof", cfg.EnablePprof, "metrics", cfg.MetricsEnabled)
+		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Admin listener failed to start", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Remove sessions that are past SESSION_TIMEOUT once nobody is in them
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			srv.ExpireSessions(now)
+		}
+	}()
+
+	// Reload safe settings from the config sources on SIGHUP, keeping
+	// listeners and sessions running
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			next, err := config.Load(os.Args[1:])
+			if err != nil {
+				slog.Error("Configuration reload failed, keeping current settings", "error", err)
+				continue
+			}
+
+			changed, restart := srv.Reload(next)
+			logLevel.Set(srv.Config().Level())
+			slog.Info("Configuration reloaded", "changed", changed)
+			if len(restart) > 0 {
+				slog.Warn("Some changed settings only take effect after a restart", "settings", restart)
+			}
 		}
 	}()
 
@@ -60,16 +250,30 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Server shutting down...")
+	slog.Info("Server shutting down")
 
 	// Create a deadline to wait for
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
+	// Fail readiness checks so load balancers stop routing new clients
+	// here, then tell connected clients to reconnect elsewhere
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("Clients did not disconnect before the shutdown timeout", "error", err)
+	}
+
 	// Attempt graceful shutdown
 	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+		slog.Error("Server forced to shutdown", "error", err)
 	} else {
-		log.Println("Server gracefully stopped")
+		slog.Info("Server gracefully stopped")
 	}
+
+	if redirectServer != nil {
+		redirectServer.Shutdown(ctx)
+	}
+
+	// The admin listener stays up until now so a stuck shutdown can be
+	// diagnosed
+	adminServer.Close()
 }
@@ -0,0 +1,90 @@
+package client
+
+// REST request and response bodies. They mirror the server's types field
+// for field; they are declared here rather than imported so that the
+// client does not pull in the server and everything it depends on.
+
+// CreateSessionRequest is the body of POST /api/sessions
+type CreateSessionRequest struct {
+	SessionID string `json:"sessionId"`
+	Moderator string `json:"moderator,omitempty"` // Optional: join as creator over REST
+}
+
+// CreateSessionResponse is returned by POST /api/sessions
+type CreateSessionResponse struct {
+	SessionID      string `json:"sessionId"`
+	Status         string `json:"status"`
+	ModeratorID    string `json:"moderatorId,omitempty"`
+	ModeratorToken string `json:"moderatorToken,omitempty"`
+}
+
+// JoinRequest is the body of POST /api/sessions/{id}/participants
+type JoinRequest struct {
+	Name      string `json:"name"`
+	Creator   bool   `json:"creator,omitempty"`
+	Transport string `json:"transport,omitempty"` // TransportPoll (default) or TransportREST
+}
+
+// JoinResponse identifies a participant joined without a WebSocket
+type JoinResponse struct {
+	UserID string `json:"userId"`
+	Token  string `json:"token"`
+}
+
+// ImportResponse is returned by POST /api/sessions/{id}/import
+type ImportResponse struct {
+	Stories []Story `json:"stories"` // Stories added to the queue
+}
+
+// WebhookView is a session webhook as the API returns it; the secret is
+// never returned
+type WebhookView struct {
+	ID     string      `json:"id"`
+	URL    string      `json:"url"`
+	Events []EventType `json:"events"`
+	Signed bool        `json:"signed"` // Whether a secret is set
+}
+
+// VoteRequest is the data of a vote message and the body of
+// POST /api/sessions/{id}/vote
+type VoteRequest struct {
+	Vote string `json:"vote"`
+}
+
+// StoryRequest is the data of a set_story message and the body of
+// POST /api/sessions/{id}/story
+type StoryRequest struct {
+	Story string `json:"story"`
+}
+
+// AddStoriesRequest is the data of an add_stories message and the body of
+// POST /api/sessions/{id}/stories
+type AddStoriesRequest struct {
+	Stories []Story `json:"stories"`
+}
+
+// EstimateRequest is the data of a set_estimate message and the body of
+// POST /api/sessions/{id}/estimate
+type EstimateRequest struct {
+	Estimate string `json:"estimate"`
+}
+
+type sessionListResponse struct {
+	Sessions []string `json:"sessions"`
+}
+
+type importRequest struct {
+	Source string `json:"source"`
+	Query  string `json:"query"`
+}
+
+type webhookRequest struct {
+	URL    string      `json:"url"`
+	Secret string      `json:"secret,omitempty"`
+	Events []EventType `json:"events,omitempty"`
+}
+
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"planning-poker/internal/server"
+)
+
+// jsonFields returns the JSON names and kinds of a struct's fields
+func jsonFields(t reflect.Type) map[string]reflect.Kind {
+	fields := make(map[string]reflect.Kind)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = t.Field(i).Type.Kind()
+	}
+	return fields
+}
+
+func TestBodiesMatchServer(t *testing.T) {
+	pairs := []struct{ client, server any }{
+		{CreateSessionRequest{}, server.CreateSessionRequest{}},
+		{CreateSessionResponse{}, server.CreateSessionResponse{}},
+		{JoinRequest{}, server.JoinRequest{}},
+		{JoinResponse{}, server.JoinResponse{}},
+		{ImportResponse{}, server.ImportResponse{}},
+		{WebhookView{}, server.WebhookView{}},
+		{sessionListResponse{}, server.SessionListResponse{}},
+		{VoteRequest{}, server.VoteRequest{}},
+		{StoryRequest{}, server.StoryRequest{}},
+		{AddStoriesRequest{}, server.AddStoriesRequest{}},
+		{EstimateRequest{}, server.EstimateRequest{}},
+		{importRequest{}, server.ImportRequest{}},
+		{webhookRequest{}, server.WebhookRequest{}},
+		{errorResponse{}, server.ErrorResponse{}},
+	}
+	for _, pair := range pairs {
+		got, want := reflect.TypeOf(pair.client), reflect.TypeOf(pair.server)
+		if !reflect.DeepEqual(jsonFields(got), jsonFields(want)) {
+			t.Errorf("%s has fields %v, but the server's %s has %v", got.Name(), jsonFields(got), want.Name(), jsonFields(want))
+		}
+	}
+}
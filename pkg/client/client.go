@@ -0,0 +1,265 @@
+// Package client talks to a planning poker server: Client calls the REST
+// API and Conn joins a session over a WebSocket, keeps its state up to date
+// and reconnects when the connection drops.
+//
+// The message and state types are the ones the server uses, re-exported
+// here so that programs outside this module can name them.
+//
+//	c := client.New("https://poker.example.com")
+//	conn, err := c.Dial(ctx, client.DialOptions{Session: "SPRINT42", Name: "bot"})
+//	if err != nil {
+//		return err
+//	}
+//	defer conn.Close()
+//	for event := range conn.Events() {
+//		if event.Kind == client.EventState && event.State.Status == client.StatusActive {
+//			conn.Vote("5")
+//		}
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"planning-poker/internal/poker"
+)
+
+// Protocol types shared with the server
+type (
+	Message         = poker.Message
+	MessageType     = poker.MessageType
+	SessionState    = poker.SessionState
+	SessionStatus   = poker.SessionStatus
+	StatePatch      = poker.StatePatch
+	ParticipantView = poker.ParticipantView
+	ShutdownNotice  = poker.ShutdownNotice
+	Story           = poker.Story
+	Webhook         = poker.Webhook
+	EventType       = poker.EventType
+)
+
+// Session statuses
+const (
+	StatusWaiting = poker.SessionStatusWaiting
+	StatusActive  = poker.SessionStatusActive
+	StatusEnded   = poker.SessionStatusEnded
+)
+
+// Story fields listed in StatePatch.Changed
+const (
+	PatchQueue       = poker.PatchQueue
+	PatchActiveStory = poker.PatchActiveStory
+	PatchEstimated   = poker.PatchEstimated
+)
+
+// Transports of REST participants, for JoinRequest
+const (
+	TransportREST = "rest" // Drive the session with REST calls only
+	TransportPoll = "poll" // Also receive messages by long polling
+)
+
+const requestTimeout = 30 * time.Second
+
+// APIError is returned for error responses from the server
+type APIError struct {
+	StatusCode int
+	Code       string // Such as "forbidden"; see the server's ErrorResponse
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("planning poker: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("planning poker: %s (%d)", e.Message, e.StatusCode)
+}
+
+// Client calls the REST API of the server at BaseURL. Session actions are
+// made as the participant whose token is set with WithToken.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	token string
+}
+
+// New returns a client for the server at baseURL, such as
+// http://localhost:8080
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// WithToken returns a copy of c that acts as the participant of token,
+// from CreateSession with a moderator or from Join
+func (c *Client) WithToken(token string) *Client {
+	clone := *c
+	clone.token = token
+	return &clone
+}
+
+// Sessions lists the sessions on the server
+func (c *Client) Sessions(ctx context.Context) ([]string, error) {
+	var list sessionListResponse
+	err := c.do(ctx, http.MethodGet, "/api/sessions", nil, &list)
+	return list.Sessions, err
+}
+
+// CreateSession creates a session. With a moderator name, the moderator
+// joins over REST and the response carries their token.
+func (c *Client) CreateSession(ctx context.Context, sessionID, moderator string) (CreateSessionResponse, error) {
+	var created CreateSessionResponse
+	err := c.do(ctx, http.MethodPost, "/api/sessions", CreateSessionRequest{SessionID: sessionID, Moderator: moderator}, &created)
+	return created, err
+}
+
+// State returns the state of a session
+func (c *Client) State(ctx context.Context, sessionID string) (SessionState, error) {
+	var state SessionState
+	err := c.do(ctx, http.MethodGet, sessionPath(sessionID, ""), nil, &state)
+	return state, err
+}
+
+// Join joins a session as a REST participant. Use the returned token with
+// WithToken.
+func (c *Client) Join(ctx context.Context, sessionID string, req JoinRequest) (JoinResponse, error) {
+	var joined JoinResponse
+	err := c.do(ctx, http.MethodPost, sessionPath(sessionID, "participants"), req, &joined)
+	return joined, err
+}
+
+// Leave removes the participant of the client's token from a session
+func (c *Client) Leave(ctx context.Context, sessionID string) error {
+	return c.do(ctx, http.MethodDelete, sessionPath(sessionID, "participants"), nil, nil)
+}
+
+// StartSession starts a waiting session (creator only)
+func (c *Client) StartSession(ctx context.Context, sessionID string) error {
+	return c.action(ctx, sessionID, "start", nil)
+}
+
+// Vote votes in the current round
+func (c *Client) Vote(ctx context.Context, sessionID, vote string) error {
+	return c.action(ctx, sessionID, "vote", VoteRequest{Vote: vote})
+}
+
+// Reveal reveals the votes (moderator only)
+func (c *Client) Reveal(ctx context.Context, sessionID string) error {
+	return c.action(ctx, sessionID, "reveal", nil)
+}
+
+// NewRound clears the votes (moderator only)
+func (c *Client) NewRound(ctx context.Context, sessionID string) error {
+	return c.action(ctx, sessionID, "new-round", nil)
+}
+
+// SetStory sets the current story and starts a new round (moderator only)
+func (c *Client) SetStory(ctx context.Context, sessionID, story string) error {
+	return c.action(ctx, sessionID, "story", StoryRequest{Story: story})
+}
+
+// AddStories queues stories (moderator only)
+func (c *Client) AddStories(ctx context.Context, sessionID string, stories []Story) error {
+	return c.action(ctx, sessionID, "stories", AddStoriesRequest{Stories: stories})
+}
+
+// NextStory makes the first queued story current (moderator only)
+func (c *Client) NextStory(ctx context.Context, sessionID string) error {
+	return c.action(ctx, sessionID, "next-story", nil)
+}
+
+// SetEstimate finalizes the round once votes are revealed (moderator only)
+func (c *Client) SetEstimate(ctx context.Context, sessionID, estimate string) error {
+	return c.action(ctx, sessionID, "estimate", EstimateRequest{Estimate: estimate})
+}
+
+// EndSession ends the session (moderator only)
+func (c *Client) EndSession(ctx context.Context, sessionID string) error {
+	return c.action(ctx, sessionID, "end", nil)
+}
+
+// Import queues stories from an issue tracker such as "jira" (moderator only)
+func (c *Client) Import(ctx context.Context, sessionID, source, query string) (ImportResponse, error) {
+	var imported ImportResponse
+	err := c.do(ctx, http.MethodPost, sessionPath(sessionID, "import"), importRequest{Source: source, Query: query}, &imported)
+	return imported, err
+}
+
+// AddWebhook adds a webhook to the session (moderator only). A nil events
+// list subscribes to every event.
+func (c *Client) AddWebhook(ctx context.Context, sessionID, hookURL, secret string, events []EventType) (WebhookView, error) {
+	var view WebhookView
+	req := webhookRequest{URL: hookURL, Secret: secret, Events: events}
+	err := c.do(ctx, http.MethodPost, sessionPath(sessionID, "webhooks"), req, &view)
+	return view, err
+}
+
+// RemoveWebhook removes a webhook from the session (moderator only)
+func (c *Client) RemoveWebhook(ctx context.Context, sessionID, webhookID string) error {
+	return c.do(ctx, http.MethodDelete, sessionPath(sessionID, "webhooks/"+url.PathEscape(webhookID)), nil, nil)
+}
+
+// action posts to /api/sessions/{id}/{name}
+func (c *Client) action(ctx context.Context, sessionID, name string, body any) error {
+	return c.do(ctx, http.MethodPost, sessionPath(sessionID, name), body, nil)
+}
+
+// do sends body as JSON and decodes the response into out, if set
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var body errorResponse
+		if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body) == nil {
+			apiErr.Code, apiErr.Message = body.Code, body.Message
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func sessionPath(sessionID, sub string) string {
+	path := "/api/sessions/" + url.PathEscape(sessionID)
+	if sub != "" {
+		path += "/" + sub
+	}
+	return path
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"planning-poker/internal/server"
+)
+
+// testServer serves the public routes of the servers it is switched to, so
+// that tests can replace an instance that shut down
+type testServer struct {
+	*httptest.Server
+	current atomic.Pointer[http.ServeMux]
+}
+
+func newTestServer(t *testing.T, srv *server.Server) *testServer {
+	t.Helper()
+	ts := &testServer{}
+	ts.use(srv)
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.current.Load().ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *testServer) use(srv *server.Server) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", srv.HandleWebSocket)
+	mux.HandleFunc("/api/sessions", srv.HandleSessions)
+	mux.HandleFunc("/api/sessions/", srv.HandleSession)
+	ts.current.Store(mux)
+}
+
+func TestClientREST(t *testing.T) {
+	ts := newTestServer(t, server.New())
+	ctx := context.Background()
+	c := New(ts.URL + "/")
+
+	created, err := c.CreateSession(ctx, "SDK1", "alice")
+	if err != nil || created.ModeratorToken == "" {
+		t.Fatalf("CreateSession failed: %+v, %v", created, err)
+	}
+	moderator := c.WithToken(created.ModeratorToken)
+	if err := moderator.StartSession(ctx, "SDK1"); err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+
+	joined, err := c.Join(ctx, "SDK1", JoinRequest{Name: "bob", Transport: TransportREST})
+	if err != nil {
+		t.Fatalf("Join failed: %v", err)
+	}
+	bob := c.WithToken(joined.Token)
+
+	if err := moderator.AddStories(ctx, "SDK1", []Story{{Key: "PP-1", Title: "Login"}}); err != nil {
+		t.Fatalf("AddStories failed: %v", err)
+	}
+	steps := []func() error{
+		func() error { return moderator.NextStory(ctx, "SDK1") },
+		func() error { return moderator.Vote(ctx, "SDK1", "5") },
+		func() error { return bob.Vote(ctx, "SDK1", "8") },
+		func() error { return moderator.Reveal(ctx, "SDK1") },
+		func() error { return moderator.SetEstimate(ctx, "SDK1", "8") },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("Step %d failed: %v", i, err)
+		}
+	}
+
+	state, err := c.State(ctx, "SDK1")
+	if err != nil {
+		t.Fatalf("State failed: %v", err)
+	}
+	if len(state.Estimated) != 1 || state.Estimated[0].Estimate != "8" || len(state.Users) != 2 {
+		t.Errorf("Unexpected state %+v", state)
+	}
+
+	sessions, err := c.Sessions(ctx)
+	if err != nil || len(sessions) != 1 || sessions[0] != "SDK1" {
+		t.Errorf("Expected [SDK1], got %v (%v)", sessions, err)
+	}
+
+	if err := bob.Leave(ctx, "SDK1"); err != nil {
+		t.Errorf("Leave failed: %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ts := newTestServer(t, server.New())
+	ctx := context.Background()
+	c := New(ts.URL)
+	created, _ := c.CreateSession(ctx, "SDK1", "alice")
+	joined, _ := c.Join(ctx, "SDK1", JoinRequest{Name: "bob", Transport: TransportREST})
+
+	var apiErr *APIError
+	err := c.WithToken(joined.Token).Reveal(ctx, "SDK1")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden || apiErr.Code != "forbidden" {
+		t.Errorf("Expected a forbidden APIError, got %v", err)
+	}
+
+	err = c.WithToken(created.ModeratorToken).NextStory(ctx, "SDK1")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("Expected a conflict for an empty queue, got %v", err)
+	}
+
+	if err := c.Vote(ctx, "SDK1", "5"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %v", err)
+	}
+}
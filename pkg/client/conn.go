@@ -0,0 +1,430 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"planning-poker/internal/poker"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Message types, re-exported for matching Event.Message
+const (
+	MessageTypeVote           = poker.MessageTypeVote
+	MessageTypeReveal         = poker.MessageTypeReveal
+	MessageTypeNewRound       = poker.MessageTypeNewRound
+	MessageTypeSetStory       = poker.MessageTypeSetStory
+	MessageTypeUserJoined     = poker.MessageTypeUserJoined
+	MessageTypeUserLeft       = poker.MessageTypeUserLeft
+	MessageTypeSessionState   = poker.MessageTypeSessionState
+	MessageTypeStartSession   = poker.MessageTypeStartSession
+	MessageTypeWaitingRoom    = poker.MessageTypeWaitingRoom
+	MessageTypeStatePatch     = poker.MessageTypeStatePatch
+	MessageTypeSync           = poker.MessageTypeSync
+	MessageTypeAddStories     = poker.MessageTypeAddStories
+	MessageTypeNextStory      = poker.MessageTypeNextStory
+	MessageTypeSetEstimate    = poker.MessageTypeSetEstimate
+	MessageTypeEndSession     = poker.MessageTypeEndSession
+	MessageTypeAddWebhook     = poker.MessageTypeAddWebhook
+	MessageTypeRemoveWebhook  = poker.MessageTypeRemoveWebhook
+	MessageTypeServerShutdown = poker.MessageTypeServerShutdown
+)
+
+const (
+	writeTimeout             = 10 * time.Second
+	defaultMinReconnectDelay = time.Second
+	defaultMaxReconnectDelay = 30 * time.Second
+	eventBuffer              = 64
+)
+
+// ErrNotConnected is returned when sending while the connection is down
+// and has not been re-established yet
+var ErrNotConnected = errors.New("planning poker: not connected")
+
+// ErrClosed is returned when sending after Close
+var ErrClosed = errors.New("planning poker: connection closed")
+
+// EventKind says what an Event reports
+type EventKind string
+
+const (
+	EventState        EventKind = "state"        // A snapshot or patch changed State
+	EventWaitingRoom  EventKind = "waiting_room" // The session has not been started yet
+	EventShutdown     EventKind = "shutdown"     // The server is restarting; Conn reconnects after its hint
+	EventDisconnected EventKind = "disconnected" // The connection dropped with Err; Conn reconnects unless disabled
+	EventReconnected  EventKind = "reconnected"  // The connection is back; an EventState with a fresh snapshot follows
+)
+
+// Event is a change seen by a Conn
+type Event struct {
+	Kind    EventKind
+	Message Message      // The message that caused the event, if any
+	State   SessionState // The session after the event; a copy the receiver may keep
+	Patch   *StatePatch  // The patch applied, for state events caused by one
+	Err     error        // Why the connection dropped, for EventDisconnected
+}
+
+// DialOptions configures a Conn
+type DialOptions struct {
+	Session string
+	Name    string
+	Creator bool // Join as the session creator, who starts and moderates it
+
+	// Header is sent with the WebSocket handshake, for example an Origin
+	// the server allows
+	Header http.Header
+
+	// Reconnect delays grow from MinReconnectDelay to MaxReconnectDelay
+	// (defaults 1s and 30s) while the server cannot be reached. After a
+	// server_shutdown notice the server's hint is used instead.
+	MinReconnectDelay time.Duration
+	MaxReconnectDelay time.Duration
+	DisableReconnect  bool
+
+	// Logger receives connection problems; slog.Default() if nil
+	Logger *slog.Logger
+}
+
+// Conn is a participant connected to a session over a WebSocket. It keeps
+// the session state current, sends a sync request when it misses an update
+// and reconnects when the connection drops. Its methods are safe for
+// concurrent use.
+type Conn struct {
+	url    string
+	opts   DialOptions
+	dialer *websocket.Dialer
+	events chan Event
+
+	mu      sync.Mutex // Guards ws, tracker and writes
+	ws      *websocket.Conn
+	tracker tracker
+
+	ctx       context.Context // Canceled by Close
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	done      chan struct{} // Closed when the read loop has exited
+}
+
+// Dial joins a session over a WebSocket. It returns once connected; the
+// session's first snapshot follows as an EventState.
+func (c *Client) Dial(ctx context.Context, opts DialOptions) (*Conn, error) {
+	if opts.Session == "" || opts.Name == "" {
+		return nil, errors.New("planning poker: session and name are required")
+	}
+	if opts.MinReconnectDelay <= 0 {
+		opts.MinReconnectDelay = defaultMinReconnectDelay
+	}
+	if opts.MaxReconnectDelay < opts.MinReconnectDelay {
+		opts.MaxReconnectDelay = max(defaultMaxReconnectDelay, opts.MinReconnectDelay)
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	wsURL, err := c.wsURL(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &Conn{
+		url:    wsURL,
+		opts:   opts,
+		dialer: &websocket.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: requestTimeout},
+		events: make(chan Event, eventBuffer),
+		done:   make(chan struct{}),
+	}
+	ws, err := conn.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn.ctx, conn.cancel = context.WithCancel(context.Background())
+	conn.ws = ws
+
+	go conn.run(ws)
+	return conn, nil
+}
+
+// wsURL returns the WebSocket URL that joins a session
+func (c *Client) wsURL(opts DialOptions) (string, error) {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	default:
+		return "", errors.New("planning poker: server URL must start with http:// or https://")
+	}
+	u.Path += "/ws"
+
+	q := url.Values{"session": {opts.Session}, "user": {opts.Name}}
+	if opts.Creator {
+		q.Set("creator", "true")
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (c *Conn) dial(ctx context.Context) (*websocket.Conn, error) {
+	ws, resp, err := c.dialer.DialContext(ctx, c.url, c.opts.Header)
+	if err != nil {
+		if resp != nil {
+			return nil, &APIError{StatusCode: resp.StatusCode, Message: "WebSocket handshake failed"}
+		}
+		return nil, err
+	}
+	return ws, nil
+}
+
+// Events returns the channel of changes. It must be drained: the
+// connection stops reading while it is full. It is closed after Close, or
+// when the connection drops with reconnecting disabled.
+func (c *Conn) Events() <-chan Event {
+	return c.events
+}
+
+// State returns a copy of the current session state, and false before the
+// first snapshot has arrived
+func (c *Conn) State() (SessionState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tracker.state == nil {
+		return SessionState{}, false
+	}
+	return cloneState(c.tracker.state), true
+}
+
+// Close leaves the session and stops reconnecting
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.cancel()
+
+		c.mu.Lock()
+		if c.ws != nil {
+			c.ws.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			c.ws.Close()
+		}
+		c.mu.Unlock()
+	})
+	<-c.done
+	return nil
+}
+
+// Send sends a message to the session. The typed methods below cover every
+// message a participant may send.
+func (c *Conn) Send(msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sendLocked(msg)
+}
+
+func (c *Conn) sendLocked(msg Message) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	if c.ws == nil {
+		return ErrNotConnected
+	}
+	c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.ws.WriteJSON(msg)
+}
+
+func (c *Conn) send(t MessageType, data any) error {
+	msg := Message{Type: t}
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		msg.Data = encoded
+	}
+	return c.Send(msg)
+}
+
+// Vote votes in the current round
+func (c *Conn) Vote(vote string) error {
+	return c.send(MessageTypeVote, VoteRequest{Vote: vote})
+}
+
+// Reveal reveals the votes (moderator only)
+func (c *Conn) Reveal() error { return c.send(MessageTypeReveal, nil) }
+
+// NewRound clears the votes (moderator only)
+func (c *Conn) NewRound() error { return c.send(MessageTypeNewRound, nil) }
+
+// SetStory sets the current story and starts a new round (moderator only)
+func (c *Conn) SetStory(story string) error {
+	return c.send(MessageTypeSetStory, StoryRequest{Story: story})
+}
+
+// StartSession starts a waiting session (creator only)
+func (c *Conn) StartSession() error { return c.send(MessageTypeStartSession, nil) }
+
+// AddStories queues stories (moderator only)
+func (c *Conn) AddStories(stories []Story) error {
+	return c.send(MessageTypeAddStories, AddStoriesRequest{Stories: stories})
+}
+
+// NextStory makes the first queued story current (moderator only)
+func (c *Conn) NextStory() error { return c.send(MessageTypeNextStory, nil) }
+
+// SetEstimate finalizes the round once votes are revealed (moderator only)
+func (c *Conn) SetEstimate(estimate string) error {
+	return c.send(MessageTypeSetEstimate, EstimateRequest{Estimate: estimate})
+}
+
+// EndSession ends the session (moderator only)
+func (c *Conn) EndSession() error { return c.send(MessageTypeEndSession, nil) }
+
+// AddWebhook adds a webhook to the session (moderator only), giving it an
+// ID if it has none, and returns that ID
+func (c *Conn) AddWebhook(hook Webhook) (string, error) {
+	if hook.ID == "" {
+		hook.ID = uuid.New().String()
+	}
+	return hook.ID, c.send(MessageTypeAddWebhook, hook)
+}
+
+// RemoveWebhook removes a webhook from the session (moderator only)
+func (c *Conn) RemoveWebhook(id string) error {
+	return c.send(MessageTypeRemoveWebhook, map[string]string{"id": id})
+}
+
+// Sync asks for a full snapshot. Conn does this itself when it misses an
+// update.
+func (c *Conn) Sync() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sendLocked(c.tracker.syncMessage())
+}
+
+// run reads messages until Close, reconnecting whenever the connection drops
+func (c *Conn) run(ws *websocket.Conn) {
+	defer close(c.done)
+	defer close(c.events)
+
+	delay := c.opts.MinReconnectDelay
+	for {
+		err := c.read(ws)
+
+		c.mu.Lock()
+		c.ws = nil
+		hint := c.tracker.reconnectAfter
+		c.tracker.reconnectAfter = 0
+		c.mu.Unlock()
+		ws.Close()
+
+		if c.isClosed() {
+			return
+		}
+		c.opts.Logger.Warn("Planning poker connection lost", "session_id", c.opts.Session, "error", err)
+		if !c.emit(Event{Kind: EventDisconnected, Err: err}) || c.opts.DisableReconnect {
+			return
+		}
+
+		// After a shutdown notice the session continues on the other
+		// instances once the load balancer has noticed
+		wait := hint
+		if wait == 0 {
+			wait = delay
+		}
+		for {
+			if !c.sleep(jitter(wait)) {
+				return
+			}
+			next, err := c.dial(c.ctx)
+			if err == nil {
+				ws = next
+				break
+			}
+			c.opts.Logger.Debug("Planning poker reconnect failed", "session_id", c.opts.Session, "error", err)
+			delay = min(delay*2, c.opts.MaxReconnectDelay)
+			wait = delay
+		}
+
+		c.mu.Lock()
+		if c.isClosed() {
+			c.mu.Unlock()
+			ws.Close()
+			return
+		}
+		c.ws = ws
+		c.mu.Unlock()
+
+		delay = c.opts.MinReconnectDelay
+		if !c.emit(Event{Kind: EventReconnected}) {
+			return
+		}
+	}
+}
+
+// read handles messages from ws until it fails
+func (c *Conn) read(ws *websocket.Conn) error {
+	for {
+		var msg Message
+		if err := ws.ReadJSON(&msg); err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		event, sync := c.tracker.apply(msg)
+		if sync {
+			if err := c.sendLocked(c.tracker.syncMessage()); err != nil {
+				c.opts.Logger.Debug("Planning poker sync request failed", "session_id", c.opts.Session, "error", err)
+			}
+		}
+		if event != nil && c.tracker.state != nil {
+			event.State = cloneState(c.tracker.state)
+		}
+		c.mu.Unlock()
+
+		if event != nil && !c.emit(*event) {
+			return ErrClosed
+		}
+	}
+}
+
+// emit delivers an event, returning false if the Conn was closed instead
+func (c *Conn) emit(event Event) bool {
+	select {
+	case c.events <- event:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// sleep waits for d, returning false if the Conn was closed first
+func (c *Conn) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+func (c *Conn) isClosed() bool {
+	return c.ctx.Err() != nil
+}
+
+// jitter spreads reconnects over an extra quarter of d, so that everyone
+// does not reconnect at once
+func jitter(d time.Duration) time.Duration {
+	return d + rand.N(d/4+1)
+}
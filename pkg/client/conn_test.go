@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"planning-poker/internal/bus"
+	"planning-poker/internal/server"
+)
+
+func dial(t *testing.T, ts *testServer, name string, creator bool) *Conn {
+	t.Helper()
+	conn, err := New(ts.URL).Dial(context.Background(), DialOptions{
+		Session:           "SDK1",
+		Name:              name,
+		Creator:           creator,
+		MinReconnectDelay: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// waitFor reads events until one matches, failing the test after a while
+func waitFor(t *testing.T, conn *Conn, what string, match func(Event) bool) Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-conn.Events():
+			if !ok {
+				t.Fatalf("Events closed while waiting for %s", what)
+			}
+			if match(event) {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestConnRound(t *testing.T) {
+	ts := newTestServer(t, server.New())
+	alice := dial(t, ts, "alice", true)
+	waitFor(t, alice, "alice's snapshot", func(e Event) bool { return e.Kind == EventState })
+
+	bob := dial(t, ts, "bob", false)
+	waitFor(t, bob, "the waiting room", func(e Event) bool { return e.Kind == EventWaitingRoom })
+
+	if err := alice.StartSession(); err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	waitFor(t, bob, "the start", func(e Event) bool { return e.State.Status == StatusActive })
+
+	alice.SetStory("Login")
+	waitFor(t, bob, "the story", func(e Event) bool { return e.State.CurrentStory == "Login" })
+	alice.Vote("5")
+	bob.Vote("8")
+	waitFor(t, alice, "both votes", func(e Event) bool {
+		votes := 0
+		for _, user := range e.State.Users {
+			if user.Vote != nil {
+				votes++
+			}
+		}
+		return votes == 2
+	})
+	alice.Reveal()
+
+	event := waitFor(t, bob, "the reveal", func(e Event) bool { return e.State.VotesRevealed })
+	if event.Patch == nil || event.Patch.Op != MessageTypeReveal {
+		t.Errorf("Expected the reveal patch, got %+v", event.Patch)
+	}
+	votes := map[string]string{}
+	for _, user := range event.State.Users {
+		votes[user.Name] = *user.Vote
+	}
+	if votes["alice"] != "5" || votes["bob"] != "8" {
+		t.Errorf("Unexpected votes %v", votes)
+	}
+
+	if state, ok := alice.State(); !ok || state.CurrentStory != "Login" {
+		t.Errorf("Unexpected state %+v", state)
+	}
+}
+
+func TestConnReconnectsAfterShutdown(t *testing.T) {
+	shared := bus.NewLocal()
+	first := server.NewWithBus(nil, shared)
+	ts := newTestServer(t, first)
+
+	alice := dial(t, ts, "alice", true)
+	waitFor(t, alice, "alice's snapshot", func(e Event) bool { return e.Kind == EventState })
+
+	// The load balancer sends clients to another instance
+	ts.use(server.NewWithBus(nil, shared))
+	go first.Shutdown(context.Background())
+
+	waitFor(t, alice, "the shutdown notice", func(e Event) bool { return e.Kind == EventShutdown })
+	waitFor(t, alice, "the disconnect", func(e Event) bool { return e.Kind == EventDisconnected && e.Err != nil })
+	waitFor(t, alice, "the reconnect", func(e Event) bool { return e.Kind == EventReconnected })
+	waitFor(t, alice, "a fresh snapshot", func(e Event) bool {
+		return e.Kind == EventState && len(e.State.Users) == 1
+	})
+
+	if err := alice.StartSession(); err != nil {
+		t.Errorf("Expected to send after reconnecting, got %v", err)
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	ts := newTestServer(t, server.New())
+	conn := dial(t, ts, "alice", true)
+	conn.Close()
+
+	for range conn.Events() {
+		// Drain until closed
+	}
+	if err := conn.Vote("5"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+
+	if _, err := New(ts.URL).Dial(context.Background(), DialOptions{Session: "SDK1"}); err == nil {
+		t.Error("Expected Dial without a name to fail")
+	}
+}
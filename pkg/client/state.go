@@ -0,0 +1,155 @@
+package client
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// tracker keeps a session's state from the last snapshot and the patches
+// received since, as the web client does
+type tracker struct {
+	state         *SessionState
+	syncRequested bool
+
+	// reconnectAfter is the delay from the last server_shutdown notice
+	reconnectAfter time.Duration
+}
+
+// apply updates the state with a message. It returns the event to report,
+// if any, and whether a missed update calls for a sync request.
+func (t *tracker) apply(msg Message) (event *Event, sync bool) {
+	switch msg.Type {
+	case MessageTypeSessionState:
+		var state SessionState
+		if json.Unmarshal(msg.Data, &state) != nil {
+			return nil, false
+		}
+		t.state = &state
+		t.syncRequested = false
+		return &Event{Kind: EventState, Message: msg}, false
+
+	case MessageTypeWaitingRoom:
+		return &Event{Kind: EventWaitingRoom, Message: msg}, false
+
+	case MessageTypeServerShutdown:
+		var notice ShutdownNotice
+		json.Unmarshal(msg.Data, &notice)
+		t.reconnectAfter = time.Duration(notice.ReconnectAfterMs) * time.Millisecond
+		return &Event{Kind: EventShutdown, Message: msg}, false
+
+	case MessageTypeStatePatch:
+		var patch StatePatch
+		if json.Unmarshal(msg.Data, &patch) != nil || t.state == nil {
+			return nil, false
+		}
+		// Patches older than the snapshot are already reflected in it
+		if patch.Version <= t.state.Version {
+			return nil, false
+		}
+		if patch.Version != t.state.Version+1 {
+			if t.syncRequested {
+				return nil, false
+			}
+			t.syncRequested = true
+			return nil, true
+		}
+		applyPatch(t.state, patch)
+		return &Event{Kind: EventState, Message: msg, Patch: &patch}, false
+	}
+	return nil, false
+}
+
+// syncMessage asks for a snapshot newer than the current state
+func (t *tracker) syncMessage() Message {
+	var version uint64
+	if t.state != nil {
+		version = t.state.Version
+	}
+	data, _ := json.Marshal(map[string]uint64{"version": version})
+	return Message{Type: MessageTypeSync, Data: data}
+}
+
+// applyPatch applies the patch that follows s's version
+func applyPatch(s *SessionState, patch StatePatch) {
+	if s.Users == nil {
+		s.Users = map[string]*ParticipantView{}
+	}
+
+	switch patch.Op {
+	case MessageTypeUserJoined:
+		if patch.User != nil {
+			s.Users[patch.User.ID] = patch.User
+		}
+	case MessageTypeUserLeft:
+		delete(s.Users, patch.UserID)
+	case MessageTypeVote:
+		if user := s.Users[patch.UserID]; user != nil {
+			user.Vote = patch.Vote
+		}
+	case MessageTypeReveal:
+		s.VotesRevealed = true
+		for id, vote := range patch.Votes {
+			if user := s.Users[id]; user != nil {
+				user.Vote = vote
+			}
+		}
+	case MessageTypeSetStory:
+		s.CurrentStory = stringValue(patch.Story)
+		newRound(s)
+	case MessageTypeNewRound:
+		newRound(s)
+	case MessageTypeStartSession, MessageTypeEndSession:
+		s.Status = patch.Status
+	case MessageTypeNextStory:
+		s.CurrentStory = stringValue(patch.Story)
+		newRound(s)
+	}
+
+	for _, field := range patch.Changed {
+		switch field {
+		case PatchQueue:
+			s.Queue = patch.Queue
+		case PatchActiveStory:
+			s.ActiveStory = patch.ActiveStory
+		case PatchEstimated:
+			s.Estimated = patch.Estimated
+		}
+	}
+	s.Version = patch.Version
+}
+
+func newRound(s *SessionState) {
+	s.VotesRevealed = false
+	for _, user := range s.Users {
+		user.Vote = nil
+	}
+}
+
+// cloneState copies s deeply enough that later patches do not change it
+func cloneState(s *SessionState) SessionState {
+	clone := *s
+	clone.Users = make(map[string]*ParticipantView, len(s.Users))
+	for id, user := range s.Users {
+		view := *user
+		if user.Vote != nil {
+			vote := *user.Vote
+			view.Vote = &vote
+		}
+		clone.Users[id] = &view
+	}
+	clone.Queue = slices.Clone(s.Queue)
+	clone.Estimated = slices.Clone(s.Estimated)
+	if s.ActiveStory != nil {
+		story := *s.ActiveStory
+		clone.ActiveStory = &story
+	}
+	return clone
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func stateMessage(t *testing.T, state SessionState) Message {
+	t.Helper()
+	data, _ := json.Marshal(state)
+	return Message{Type: MessageTypeSessionState, Data: data}
+}
+
+func patchMessage(t *testing.T, patch StatePatch) Message {
+	t.Helper()
+	data, _ := json.Marshal(patch)
+	return Message{Type: MessageTypeStatePatch, Data: data}
+}
+
+func TestTrackerAppliesPatches(t *testing.T) {
+	var tr tracker
+	five, hidden := "5", "?"
+	tr.apply(stateMessage(t, SessionState{
+		Version: 2,
+		Status:  StatusActive,
+		Users:   map[string]*ParticipantView{"a": {ID: "a", Name: "alice"}},
+	}))
+
+	story := "Login"
+	patches := []StatePatch{
+		{Version: 2, Op: MessageTypeNewRound}, // Already in the snapshot
+		{Version: 3, Op: MessageTypeUserJoined, UserID: "b", User: &ParticipantView{ID: "b", Name: "bob"}},
+		{Version: 4, Op: MessageTypeSetStory, Story: &story},
+		{Version: 5, Op: MessageTypeVote, UserID: "a", Vote: &hidden},
+		{Version: 6, Op: MessageTypeReveal, Votes: map[string]*string{"a": &five, "b": nil}},
+	}
+	var events int
+	for _, patch := range patches {
+		event, sync := tr.apply(patchMessage(t, patch))
+		if sync {
+			t.Fatalf("Unexpected sync request at version %d", patch.Version)
+		}
+		if event != nil {
+			events++
+		}
+	}
+
+	s := tr.state
+	if events != 4 || s.Version != 6 || s.CurrentStory != "Login" || !s.VotesRevealed || len(s.Users) != 2 {
+		t.Fatalf("Unexpected state after %d events: %+v", events, s)
+	}
+	if vote := s.Users["a"].Vote; vote == nil || *vote != "5" {
+		t.Errorf("Expected alice's revealed vote, got %v", vote)
+	}
+
+	// Copies must not change with later patches
+	snapshot := cloneState(s)
+	tr.apply(patchMessage(t, StatePatch{Version: 7, Op: MessageTypeNewRound}))
+	if snapshot.Users["a"].Vote == nil || !snapshot.VotesRevealed {
+		t.Error("Expected the copy to keep the revealed votes")
+	}
+}
+
+func TestTrackerClearsStoryFields(t *testing.T) {
+	var tr tracker
+	login := Story{Key: "PP-1", Title: "Login"}
+	tr.apply(stateMessage(t, SessionState{Version: 1, Queue: []Story{login}}))
+
+	// An empty queue and a null active story are omitted from the JSON
+	tr.apply(patchMessage(t, StatePatch{Version: 2, Op: MessageTypeNextStory, Story: &login.Title,
+		Changed: []string{PatchQueue, PatchActiveStory}, ActiveStory: &login}))
+	tr.apply(patchMessage(t, StatePatch{Version: 3, Op: MessageTypeSetStory, Story: new(string), Changed: []string{PatchActiveStory}}))
+
+	if s := tr.state; len(s.Queue) != 0 || s.ActiveStory != nil || s.Version != 3 {
+		t.Errorf("Expected the queue and active story to be cleared, got %+v", s)
+	}
+}
+
+func TestTrackerRequestsSyncOnGap(t *testing.T) {
+	var tr tracker
+	tr.apply(stateMessage(t, SessionState{Version: 3}))
+
+	if _, sync := tr.apply(patchMessage(t, StatePatch{Version: 5, Op: MessageTypeNewRound})); !sync {
+		t.Fatal("Expected a sync request for a missing version")
+	}
+	if msg := tr.syncMessage(); string(msg.Data) != `{"version":3}` {
+		t.Errorf("Unexpected sync message %s", msg.Data)
+	}
+	if _, sync := tr.apply(patchMessage(t, StatePatch{Version: 6, Op: MessageTypeNewRound})); sync {
+		t.Error("Expected one sync request until the snapshot arrives")
+	}
+
+	tr.apply(stateMessage(t, SessionState{Version: 6}))
+	if event, sync := tr.apply(patchMessage(t, StatePatch{Version: 7, Op: MessageTypeNewRound})); sync || event == nil {
+		t.Error("Expected patches to apply again after the snapshot")
+	}
+}
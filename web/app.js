@@ -0,0 +1,793 @@
+let socket = null;
+let currentSession = null;
+let currentUser = null;
+let currentUserId = null;
+let isModerator = false;
+let myVote = null;
+let createdSessionId = null;
+let sessionState = null;
+let syncRequested = false;
+let eventSource = null;
+let participantToken = null;
+let polling = false;
+let reconnecting = false;
+
+// UI Tab Management
+function showJoinTab() {
+    document.getElementById('joinTab').classList.remove('hidden');
+    document.getElementById('createTab').classList.add('hidden');
+    document.getElementById('sessionCreated').classList.add('hidden');
+    document.getElementById('joinTabBtn').className = 'btn btn-primary';
+    document.getElementById('createTabBtn').className = 'btn btn-secondary';
+}
+
+function showCreateTab() {
+    document.getElementById('joinTab').classList.add('hidden');
+    document.getElementById('createTab').classList.remove('hidden');
+    document.getElementById('sessionCreated').classList.add('hidden');
+    document.getElementById('joinTabBtn').className = 'btn btn-secondary';
+    document.getElementById('createTabBtn').className = 'btn btn-primary';
+}
+
+// Generate random session ID
+function generateSessionId() {
+    const chars = 'ABCDEFGHIJKLMNPQRSTUVWXYZ123456789'; // Removed confusing chars like O, 0, I, L
+    let result = '';
+    for (let i = 0; i < 6; i++) {
+        result += chars.charAt(Math.floor(Math.random() * chars.length));
+    }
+    return result;
+}
+
+// Create new session
+async function createSession() {
+    const userName = document.getElementById('createUserName').value.trim();
+
+    if (!userName) {
+        alert('Please enter your name');
+        return;
+    }
+
+    // Generate session ID
+    createdSessionId = generateSessionId();
+    
+    try {
+        // Create session on server
+        const response = await fetch('/api/sessions', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({
+                sessionId: createdSessionId
+            })
+        });
+
+        if (!response.ok) {
+            throw new Error('Failed to create session');
+        }
+
+        // Show session created UI
+        const sessionUrl = `${window.location.origin}?session=${createdSessionId}`;
+        document.getElementById('sessionUrl').value = sessionUrl;
+        
+        document.getElementById('joinTab').classList.add('hidden');
+        document.getElementById('createTab').classList.add('hidden');
+        document.getElementById('sessionCreated').classList.remove('hidden');
+        
+        // Set the user name for when they start the session
+        currentUser = userName;
+        currentSession = createdSessionId;
+
+    } catch (error) {
+        console.error('Error creating session:', error);
+        alert('Failed to create session. Please try again.');
+    }
+}
+
+// Copy URL to clipboard
+function copyToClipboard() {
+    const urlField = document.getElementById('sessionUrl');
+    urlField.select();
+    urlField.setSelectionRange(0, 99999); // For mobile devices
+    
+    try {
+        document.execCommand('copy');
+        alert('Session URL copied to clipboard!');
+    } catch (err) {
+        console.error('Failed to copy: ', err);
+        alert('Failed to copy URL. Please copy it manually.');
+    }
+}
+
+// Start the session (for session creator)
+function startSession() {
+    // First connect to WebSocket, then start session
+    connectWebSocket();
+    
+    // Wait a moment for connection to establish, then start session
+    setTimeout(() => {
+        if (isConnected()) {
+            sendMessage('start_session');
+        }
+    }, 500);
+}
+
+// Check for URL parameters on page load
+window.addEventListener('load', function() {
+    const urlParams = new URLSearchParams(window.location.search);
+    const sessionParam = urlParams.get('session');
+    const userParam = urlParams.get('user');
+    
+    if (sessionParam) {
+        // Pre-fill session ID
+        document.getElementById('sessionId').value = sessionParam;
+
+        // Moderator links, such as those posted by the /poker chat command,
+        // make whoever opens them the session creator
+        if (urlParams.get('creator') === 'true') {
+            createdSessionId = sessionParam;
+        }
+        
+        if (userParam) {
+            // Pre-fill user name and show join tab
+            document.getElementById('userName').value = decodeURIComponent(userParam);
+            showJoinTab();
+            
+            // Auto-join after a short delay to let the UI update
+            setTimeout(() => {
+                joinSession();
+            }, 100);
+        } else {
+            // Show join tab and prompt for name
+            showJoinTab();
+            document.getElementById('userName').focus();
+        }
+    } else {
+        // No session in URL, show create tab by default
+        showCreateTab();
+    }
+});
+
+function joinSession() {
+    const sessionId = document.getElementById('sessionId').value.trim();
+    const userName = document.getElementById('userName').value.trim();
+
+    if (!sessionId || !userName) {
+        alert('Please enter both session ID and your name');
+        return;
+    }
+
+    currentSession = sessionId;
+    currentUser = userName;
+
+    document.getElementById('currentSessionId').textContent = sessionId;
+    document.getElementById('currentUserName').textContent = userName;
+
+    connectWebSocket();
+}
+
+function connectWebSocket() {
+    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+    const host = window.location.host;
+    const creatorParam = createdSessionId === currentSession ? '&creator=true' : '';
+    const wsUrl = `${protocol}//${host}/ws?session=${currentSession}&user=${encodeURIComponent(currentUser)}${creatorParam}`;
+    socket = new WebSocket(wsUrl);
+    let opened = false;
+
+    socket.onopen = function() {
+        opened = true;
+        showConnected();
+    };
+
+    socket.onclose = function() {
+        if (opened && !reconnecting) {
+            showDisconnected();
+        }
+    };
+
+    socket.onmessage = function(event) {
+        const message = JSON.parse(event.data);
+        handleMessage(message);
+    };
+
+    socket.onerror = function(error) {
+        console.error('WebSocket error:', error);
+        if (!opened) {
+            // Some proxies block WebSocket upgrades; try plain HTTP instead
+            console.log('WebSocket unavailable, falling back to Server-Sent Events');
+            socket = null;
+            connectEventSource();
+        }
+    };
+}
+
+function connectEventSource() {
+    const creatorParam = createdSessionId === currentSession ? '&creator=true' : '';
+    const url = `/api/sessions/${encodeURIComponent(currentSession)}/events?user=${encodeURIComponent(currentUser)}${creatorParam}`;
+    eventSource = new EventSource(url);
+    let connected = false;
+
+    eventSource.addEventListener('connected', function(event) {
+        connected = true;
+        participantToken = JSON.parse(event.data).token;
+        showConnected();
+    });
+
+    eventSource.onmessage = function(event) {
+        handleMessage(JSON.parse(event.data));
+    };
+
+    eventSource.onerror = function() {
+        if (!connected) {
+            // The stream never got through, so the proxy buffers responses
+            console.log('Event stream unavailable, falling back to long-polling');
+            eventSource.close();
+            eventSource = null;
+            connectLongPolling();
+        } else if (!reconnecting) {
+            showDisconnected();
+        }
+    };
+}
+
+async function connectLongPolling() {
+    const sessionPath = `/api/sessions/${encodeURIComponent(currentSession)}`;
+
+    try {
+        const response = await fetch(`${sessionPath}/participants`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({
+                name: currentUser,
+                creator: createdSessionId === currentSession
+            })
+        });
+        if (!response.ok) {
+            throw new Error('Failed to join session');
+        }
+        participantToken = (await response.json()).token;
+    } catch (error) {
+        console.error('Long-polling join failed:', error);
+        alert('Failed to connect to server');
+        return;
+    }
+
+    showConnected();
+    polling = true;
+    while (polling) {
+        try {
+            const response = await fetch(`${sessionPath}/poll`, {
+                headers: { 'X-Participant-Token': participantToken }
+            });
+            if (!response.ok) {
+                break;
+            }
+            const body = await response.json();
+            body.messages.forEach(handleMessage);
+        } catch (error) {
+            console.error('Poll failed:', error);
+            break;
+        }
+    }
+    polling = false;
+    if (!reconnecting) {
+        showDisconnected();
+    }
+}
+
+function isConnected() {
+    return (socket && socket.readyState === WebSocket.OPEN) || participantToken !== null;
+}
+
+function disconnect() {
+    if (socket) {
+        socket.close();
+        socket = null;
+    }
+    if (eventSource) {
+        eventSource.close();
+        eventSource = null;
+    }
+    if (polling) {
+        polling = false;
+        fetch(`/api/sessions/${encodeURIComponent(currentSession)}/participants`, {
+            method: 'DELETE',
+            headers: { 'X-Participant-Token': participantToken },
+            keepalive: true
+        });
+    }
+    participantToken = null;
+}
+
+// The server is going away: drop the connection without leaving the
+// session and rejoin after the hinted delay, with jitter so clients
+// do not all reconnect at once
+function reconnectAfterShutdown(notice) {
+    reconnecting = true;
+    document.getElementById('connectionStatus').textContent = 'Reconnecting...';
+    document.getElementById('connectionStatus').className = 'connection-status disconnected';
+
+    if (socket) {
+        socket.close();
+        socket = null;
+    }
+    if (eventSource) {
+        eventSource.close();
+        eventSource = null;
+    }
+    polling = false;
+    participantToken = null;
+
+    const delay = notice.reconnectAfterMs * (1 + Math.random());
+    setTimeout(() => {
+        reconnecting = false;
+        connectWebSocket();
+    }, delay);
+}
+
+function showConnected() {
+    document.getElementById('connectionStatus').textContent = 'Connected';
+    document.getElementById('connectionStatus').className = 'connection-status connected';
+    document.getElementById('joinForm').classList.add('hidden');
+    document.getElementById('app').classList.remove('hidden');
+}
+
+function showDisconnected() {
+    document.getElementById('connectionStatus').textContent = 'Disconnected';
+    document.getElementById('connectionStatus').className = 'connection-status disconnected';
+}
+
+function handleMessage(message) {
+    console.log('Received message:', message.type, message.data);
+    switch (message.type) {
+        case 'session_state':
+            console.log('Processing session_state with status:', message.data?.status);
+            sessionState = message.data;
+            syncRequested = false;
+            updateSessionState(sessionState);
+            break;
+        case 'state_patch':
+            applyStatePatch(message.data);
+            break;
+        case 'user_joined':
+            console.log('User joined:', message.data);
+            break;
+        case 'user_left':
+            console.log('User left:', message.data);
+            break;
+        case 'waiting_room':
+            console.log('Entering waiting room:', message.data);
+            showWaitingRoom(message.data);
+            break;
+        case 'start_session':
+            console.log('Session started:', message.data);
+            hideWaitingRoom();
+            // Request updated session state in case of any race conditions
+            setTimeout(() => {
+                if (isConnected()) {
+                    console.log('Requesting session state update after start');
+                }
+            }, 100);
+            break;
+        case 'server_shutdown':
+            console.log('Server shutting down:', message.data);
+            reconnectAfterShutdown(message.data);
+            break;
+    }
+}
+
+function applyStatePatch(patch) {
+    // Patches older than our snapshot are already reflected in it
+    if (!sessionState || patch.version <= sessionState.version) {
+        return;
+    }
+
+    // A missing version means we lost an update; ask for a full snapshot
+    if (patch.version !== sessionState.version + 1) {
+        if (!syncRequested) {
+            console.log('State version gap detected:', sessionState.version, '->', patch.version);
+            syncRequested = true;
+            sendMessage('sync', { version: sessionState.version });
+        }
+        return;
+    }
+
+    const users = sessionState.users = sessionState.users || {};
+    switch (patch.op) {
+        case 'user_joined':
+            users[patch.user.id] = patch.user;
+            break;
+        case 'user_left':
+            delete users[patch.userId];
+            break;
+        case 'vote':
+            if (users[patch.userId]) {
+                users[patch.userId].vote = patch.vote;
+            }
+            break;
+        case 'reveal':
+            sessionState.votesRevealed = true;
+            Object.entries(patch.votes || {}).forEach(([id, vote]) => {
+                if (users[id]) {
+                    users[id].vote = vote;
+                }
+            });
+            break;
+        case 'set_story':
+            sessionState.currentStory = patch.story || '';
+            // Setting a story also starts a new round
+        case 'new_round':
+            sessionState.votesRevealed = false;
+            Object.values(users).forEach(user => {
+                user.vote = null;
+            });
+            break;
+        case 'start_session':
+        case 'end_session':
+            sessionState.status = patch.status;
+            break;
+        case 'next_story':
+            sessionState.currentStory = patch.story || '';
+            sessionState.votesRevealed = false;
+            Object.values(users).forEach(user => {
+                user.vote = null;
+            });
+            break;
+    }
+
+    // Story fields listed in changed are replaced; absent means empty
+    (patch.changed || []).forEach(field => {
+        if (field === 'activeStory') {
+            sessionState.activeStory = patch.activeStory || null;
+        } else {
+            sessionState[field] = patch[field] || [];
+        }
+    });
+
+    sessionState.version = patch.version;
+    updateSessionState(sessionState);
+}
+
+function updateSessionState(state) {
+    console.log('updateSessionState called with:', state);
+    
+    // Check if we need to exit waiting room
+    const isInWaitingRoom = !document.getElementById('waitingRoom').classList.contains('hidden');
+    console.log('Current UI state - in waiting room:', isInWaitingRoom, 'session status:', state.status);
+    
+    if (state.status === 'active' && isInWaitingRoom) {
+        console.log('Session is now active, leaving waiting room');
+        hideWaitingRoom();
+    }
+    
+    // If we're in waiting room and session is still waiting, update waiting room info
+    if (isInWaitingRoom && state.status === 'waiting') {
+        console.log('Updating waiting room with participant info');
+        updateWaitingRoomParticipants(state);
+        return;
+    }
+    
+    // Only continue with full UI updates if we're in the main app
+    if (isInWaitingRoom) {
+        console.log('Still in waiting room, skipping full UI updates');
+        return;
+    }
+    
+    // Update story
+    document.getElementById('storyInput').value = state.currentStory || '';
+    updateStoryQueue(state);
+
+    // Find current user and check if they're moderator
+    let currentUserData = null;
+    Object.values(state.users || {}).forEach(user => {
+        if (user.name === currentUser) {
+            currentUserData = user;
+            currentUserId = user.id;
+            isModerator = user.isModerator;
+        }
+    });
+
+    // Show/hide moderator controls
+    const revealBtn = document.getElementById('revealBtn');
+    const newRoundBtn = document.getElementById('newRoundBtn');
+    const setStoryBtn = document.getElementById('setStoryBtn');
+    const shareBtn = document.getElementById('shareBtn');
+    const storyInput = document.getElementById('storyInput');
+    
+    // An ended session only shows its final state
+    const ended = state.status === 'ended';
+    const queued = (state.queue || []).length > 0;
+    document.getElementById('nextStoryBtn').style.display = isModerator && queued && !ended ? 'inline-block' : 'none';
+    document.getElementById('estimateControls').style.display = isModerator && state.votesRevealed && !ended ? 'inline' : 'none';
+    document.getElementById('endSessionBtn').style.display = isModerator && !ended ? 'inline-block' : 'none';
+
+    if (ended) {
+        revealBtn.style.display = 'none';
+        newRoundBtn.style.display = 'none';
+        setStoryBtn.style.display = 'none';
+        storyInput.disabled = true;
+        storyInput.placeholder = 'This session has ended';
+    } else if (isModerator) {
+        revealBtn.style.display = 'inline-block';
+        newRoundBtn.style.display = 'inline-block';
+        setStoryBtn.style.display = 'inline-block';
+        shareBtn.style.display = 'inline-block';
+        storyInput.disabled = false;
+        storyInput.placeholder = 'Enter the user story to estimate... (Moderator)';
+    } else {
+        revealBtn.style.display = 'none';
+        newRoundBtn.style.display = 'none';
+        setStoryBtn.style.display = 'none';
+        shareBtn.style.display = 'none';
+        storyInput.disabled = true;
+        storyInput.placeholder = 'Only the moderator can set stories';
+    }
+
+    // Update users
+    const usersGrid = document.getElementById('usersGrid');
+    usersGrid.innerHTML = '';
+
+    Object.values(state.users || {}).forEach(user => {
+        const userCard = document.createElement('div');
+        userCard.className = 'user-card';
+        
+        if (user.vote && user.vote !== '?') {
+            userCard.classList.add('has-voted');
+        }
+
+        if (user.isModerator) {
+            userCard.classList.add('moderator');
+        }
+
+        const voteDisplay = state.votesRevealed && user.vote ? user.vote : (user.vote ? '✓' : '');
+        const moderatorBadge = user.isModerator ? '<span class="moderator-badge">MODERATOR</span>' : '';
+
+        userCard.innerHTML = `
+            <div class="user-name">${user.name}${moderatorBadge}</div>
+            <div class="user-vote">${voteDisplay}</div>
+        `;
+
+        usersGrid.appendChild(userCard);
+    });
+}
+
+function sendMessage(type, data = {}) {
+    if (socket && socket.readyState === WebSocket.OPEN) {
+        socket.send(JSON.stringify({
+            type: type,
+            data: data
+        }));
+    } else if (participantToken) {
+        // Fallback transports send messages over plain HTTP
+        fetch(`/api/sessions/${encodeURIComponent(currentSession)}/messages`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'X-Participant-Token': participantToken
+            },
+            body: JSON.stringify({
+                type: type,
+                data: data
+            })
+        }).catch(error => console.error('Failed to send message:', error));
+    }
+}
+
+function updateStoryQueue(state) {
+    const queue = state.queue || [];
+    const list = document.getElementById('queueList');
+    list.innerHTML = '';
+    queue.forEach(story => {
+        const item = document.createElement('li');
+        item.textContent = story.key ? `${story.key}: ${story.title}` : story.title;
+        list.appendChild(item);
+    });
+    document.getElementById('storyQueue').classList.toggle('hidden', queue.length === 0);
+
+    const estimate = state.activeStory && state.activeStory.estimate;
+    document.getElementById('estimateInput').placeholder = estimate ? `Agreed: ${estimate}` : 'Agreed estimate';
+}
+
+function vote(value) {
+    // Update UI
+    document.querySelectorAll('.voting-card').forEach(card => {
+        card.classList.toggle('selected', card.dataset.value === value);
+    });
+    myVote = value;
+
+    // Send vote to server
+    sendMessage('vote', { vote: value });
+}
+
+function revealVotes() {
+    if (!isModerator) {
+        alert('Only the moderator can reveal votes');
+        return;
+    }
+    sendMessage('reveal');
+}
+
+function newRound() {
+    if (!isModerator) {
+        alert('Only the moderator can start a new round');
+        return;
+    }
+    
+    // Clear UI
+    document.querySelectorAll('.voting-card').forEach(card => {
+        card.classList.remove('selected');
+    });
+    myVote = null;
+
+    sendMessage('new_round');
+}
+
+function nextStory() {
+    if (!isModerator) {
+        return;
+    }
+    sendMessage('next_story');
+}
+
+function setEstimate() {
+    const input = document.getElementById('estimateInput');
+    const estimate = input.value.trim();
+    if (!isModerator || !estimate) {
+        return;
+    }
+    sendMessage('set_estimate', { estimate: estimate });
+    input.value = '';
+}
+
+function endSession() {
+    if (!isModerator || !confirm('End the session for everyone?')) {
+        return;
+    }
+    sendMessage('end_session');
+}
+
+function setStory() {
+    if (!isModerator) {
+        alert('Only the moderator can set stories');
+        return;
+    }
+    
+    const story = document.getElementById('storyInput').value.trim();
+    if (story) {
+        sendMessage('set_story', { story: story });
+    }
+}
+
+function shareSession() {
+    if (!currentSession) {
+        alert('No active session to share');
+        return;
+    }
+    
+    const shareUrl = `${window.location.origin}${window.location.pathname}?session=${currentSession}`;
+    
+    // Try to use the modern Clipboard API first
+    if (navigator.clipboard && window.isSecureContext) {
+        navigator.clipboard.writeText(shareUrl).then(() => {
+            alert('Session URL copied to clipboard!\n\nShare this URL with your team members so they can join.');
+        }).catch(err => {
+            console.error('Failed to copy: ', err);
+            fallbackCopyToClipboard(shareUrl);
+        });
+    } else {
+        fallbackCopyToClipboard(shareUrl);
+    }
+}
+
+function fallbackCopyToClipboard(text) {
+    // Fallback for older browsers or non-secure contexts
+    const textArea = document.createElement('textarea');
+    textArea.value = text;
+    textArea.style.position = 'fixed';
+    textArea.style.left = '-999999px';
+    textArea.style.top = '-999999px';
+    document.body.appendChild(textArea);
+    textArea.focus();
+    textArea.select();
+    
+    try {
+        document.execCommand('copy');
+        alert('Session URL copied to clipboard!\n\nShare this URL with your team members so they can join.');
+    } catch (err) {
+        console.error('Failed to copy: ', err);
+        prompt('Copy this URL to share with your team:', text);
+    }
+    
+    document.body.removeChild(textArea);
+}
+
+function leaveSession() {
+    disconnect();
+    
+    document.getElementById('joinForm').classList.remove('hidden');
+    document.getElementById('app').classList.add('hidden');
+    
+    // Reset form
+    document.getElementById('sessionId').value = '';
+    document.getElementById('userName').value = '';
+}
+
+// Waiting Room Management
+function showWaitingRoom(data) {
+    document.getElementById('joinForm').classList.add('hidden');
+    document.getElementById('app').classList.add('hidden');
+    document.getElementById('waitingRoom').classList.remove('hidden');
+    
+    document.getElementById('waitingSessionId').textContent = data.sessionId || currentSession;
+    document.getElementById('waitingUserName').textContent = currentUser;
+    document.getElementById('waitingMessage').textContent = data.message || 'Waiting for the session creator to start the session...';
+}
+
+function hideWaitingRoom() {
+    document.getElementById('waitingRoom').classList.add('hidden');
+    document.getElementById('app').classList.remove('hidden');
+    
+    // Ensure session info is populated
+    document.getElementById('currentSessionId').textContent = currentSession;
+    document.getElementById('currentUserName').textContent = currentUser;
+}
+
+function leaveWaitingRoom() {
+    disconnect();
+    
+    document.getElementById('waitingRoom').classList.add('hidden');
+    document.getElementById('joinForm').classList.remove('hidden');
+    
+    // Reset form
+    document.getElementById('sessionId').value = '';
+    document.getElementById('userName').value = '';
+}
+
+function startSessionAsCreator() {
+    if (!isModerator || !createdSessionId) {
+        alert('Only the session creator can start the session');
+        return;
+    }
+    
+    sendMessage('start_session');
+}
+
+// Handle page unload
+window.addEventListener('beforeunload', function() {
+    disconnect();
+});
+
+function updateWaitingRoomParticipants(state) {
+    const participantsDiv = document.getElementById('waitingParticipants');
+    if (!participantsDiv) return;
+    
+    const users = Object.values(state.users || {});
+    if (users.length === 0) {
+        participantsDiv.innerHTML = '<em>No participants yet</em>';
+        return;
+    }
+    
+    participantsDiv.innerHTML = users.map(user => {
+        const badge = user.isModerator ? '<span style="background: #28a745; color: white; padding: 2px 6px; border-radius: 3px; font-size: 11px; margin-left: 5px;">MODERATOR</span>' : '';
+        return `<div style="margin-bottom: 5px;">👤 ${user.name}${badge}</div>`;
+    }).join('');
+}
+
+// Elements name their click handler in data-action, with an optional
+// data-value argument. Inline onclick attributes would need 'unsafe-inline'
+// in the Content-Security-Policy.
+const actions = {
+    showJoinTab, showCreateTab, createSession, joinSession, copyToClipboard,
+    leaveWaitingRoom, startSession, shareSession, setStory, vote,
+    revealVotes, newRound, nextStory, setEstimate, endSession, leaveSession
+};
+
+document.addEventListener('click', function(event) {
+    const target = event.target.closest('[data-action]');
+    if (target) {
+        actions[target.dataset.action](target.dataset.value);
+    }
+});
@@ -0,0 +1,10 @@
+// Package web holds the browser client, embedded into the server binary so
+// it can run from any directory.
+package web
+
+import "embed"
+
+// FS contains the static files served at /
+//
+//go:embed index.html app.js
+var FS embed.FS